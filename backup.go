@@ -0,0 +1,57 @@
+package resource
+
+import (
+	"io"
+)
+
+// maxPendingRestoreWrites is the number of pending writes Restore allows before it waits for them to finish.
+const maxPendingRestoreWrites = 256
+
+// Backup writes a binary snapshot of all data with a version greater than since to w.
+// It returns the version to pass as since to the next call for an incremental backup. Use 0 for a full backup.
+// Unlike ExportCollectionManifest, the snapshot can only be read by Restore.
+func (d *Datastore) Backup(w io.Writer, since uint64) (uint64, error) {
+	var version uint64
+	err := d.guard(func() error {
+		var err error
+		version, err = d.db.Backup(w, since)
+		return err
+	})
+	return version, err
+}
+
+// Restore loads a snapshot written by Backup. Existing data with the same keys is overwritten.
+// It should not be called while other goroutines use Datastore.
+func (d *Datastore) Restore(r io.Reader) error {
+	defer d.cache.purge()
+	return d.guard(func() error {
+		return d.db.Load(r, maxPendingRestoreWrites)
+	})
+}
+
+// Clone copies all data into a new Datastore at dstPath and returns it opened with the same Options.
+// Later changes to either Datastore don't affect the other.
+func (d *Datastore) Clone(dstPath string) (*Datastore, error) {
+	dst, err := NewDatastoreWithOptions(dstPath, d.options)
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.guard(func() error {
+		r, w := io.Pipe()
+		go func() {
+			_, err := d.db.Backup(w, 0)
+			w.CloseWithError(err)
+		}()
+
+		err := dst.db.Load(r, maxPendingRestoreWrites)
+		// Unblock Backup if Load stops early
+		r.CloseWithError(err)
+		return err
+	})
+	if err != nil {
+		dst.Close()
+		return nil, err
+	}
+	return dst, nil
+}
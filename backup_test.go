@@ -0,0 +1,120 @@
+package resource
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupRestore(t *testing.T) {
+	srcDbPath := filepath.Join(testdataDir, "backup_src.db")
+	dstDbPath := filepath.Join(testdataDir, "backup_dst.db")
+	_ = os.RemoveAll(srcDbPath)
+	_ = os.RemoveAll(dstDbPath)
+	defer os.RemoveAll(srcDbPath)
+	defer os.RemoveAll(dstDbPath)
+
+	src, err := NewDatastore(srcDbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer src.Close()
+
+	err = src.CreateOrUpdateItem(&Item{CID: "QmBackup1", Name: "Backup 1", Tags: []Tag{{"backup"}}})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+
+	var full bytes.Buffer
+	since, err := src.Backup(&full, 0)
+	if err != nil {
+		t.Fatalf("Unable to backup. Error: %s", err)
+	}
+
+	err = src.CreateOrUpdateItem(&Item{CID: "QmBackup2", Name: "Backup 2"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+
+	var incremental bytes.Buffer
+	_, err = src.Backup(&incremental, since)
+	if err != nil {
+		t.Fatalf("Unable to backup. Error: %s", err)
+	}
+
+	dst, err := NewDatastore(dstDbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer dst.Close()
+
+	err = dst.Restore(&full)
+	if err != nil {
+		t.Fatalf("Unable to restore. Error: %s", err)
+	}
+	item, err := dst.ReadItem("QmBackup1")
+	if err != nil {
+		t.Errorf("Unable to read item. Error: %s", err)
+	}
+	if item != nil && (item.Name != "Backup 1" || len(item.Tags) != 1) {
+		t.Errorf("Wrong restored item: %+v", item)
+	}
+	_, err = dst.ReadItem("QmBackup2")
+	if err != ErrCIDNotFound {
+		t.Errorf("QmBackup2 should not be in the full backup. Actual %v", err)
+	}
+
+	err = dst.Restore(&incremental)
+	if err != nil {
+		t.Fatalf("Unable to restore. Error: %s", err)
+	}
+	_, err = dst.ReadItem("QmBackup2")
+	if err != nil {
+		t.Errorf("QmBackup2 should be restored from the incremental backup. Error: %v", err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	cloneDbPath := filepath.Join(testdataDir, "clone.db")
+	_ = os.RemoveAll(cloneDbPath)
+	defer os.RemoveAll(cloneDbPath)
+
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmClone1", Name: "Clone 1", Tags: []Tag{{"clone"}}})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+
+	clone, err := ds.Clone(cloneDbPath)
+	if err != nil {
+		t.Fatalf("Unable to clone Datastore. Error: %s", err)
+	}
+	defer clone.Close()
+
+	item, err := clone.ReadItem("QmClone1")
+	if err != nil {
+		t.Fatalf("Unable to read item. Error: %s", err)
+	}
+	if item.Name != "Clone 1" || len(item.Tags) != 1 {
+		t.Errorf("Wrong cloned item: %+v", item)
+	}
+
+	// The clone is independent
+	err = clone.CreateOrUpdateItem(&Item{CID: "QmClone1", Name: "Clone 1 Changed"})
+	if err != nil {
+		t.Errorf("Unable to update item. Error: %s", err)
+	}
+	item, err = ds.ReadItem("QmClone1")
+	if err != nil {
+		t.Fatalf("Unable to read item. Error: %s", err)
+	}
+	if item.Name != "Clone 1" {
+		t.Errorf("Changing the clone should not change the source. Actual %s", item.Name)
+	}
+}
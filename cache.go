@@ -0,0 +1,150 @@
+package resource
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache is a size-bounded LRU cache safe for concurrent use. A nil *lruCache is a disabled cache.
+//
+// To avoid caching stale values, readers take the generation before reading from database and pass it to add.
+// Every invalidation bumps the generation, so a value read before an invalidation is never cached after it.
+type lruCache struct {
+	mu      sync.Mutex
+	size    int
+	gen     uint64
+	ll      *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+func newLRUCache(size int) *lruCache {
+	if size <= 0 {
+		return nil
+	}
+	return &lruCache{size: size, ll: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *lruCache) get(key string) (interface{}, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*lruEntry).value, true
+}
+
+// generation returns the current generation, to be passed to add.
+func (c *lruCache) generation() uint64 {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// add caches a value unless the cache was invalidated since gen.
+func (c *lruCache) add(key string, value interface{}, gen uint64) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+
+	if e, ok := c.entries[key]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*lruEntry).value = value
+		return
+	}
+
+	c.entries[key] = c.ll.PushFront(&lruEntry{key: key, value: value})
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// remove invalidates keys.
+func (c *lruCache) remove(keys ...string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for _, key := range keys {
+		if e, ok := c.entries[key]; ok {
+			c.ll.Remove(e)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// purge invalidates all keys.
+func (c *lruCache) purge() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.ll.Init()
+	c.entries = make(map[string]*list.Element)
+}
+
+func collectionCacheKey(ipns string) string {
+	return dbKey{"collection", ipns}.String()
+}
+
+func itemCacheKey(cid string) string {
+	return dbKey{"item", cid}.String()
+}
+
+func itemCacheKeys(cids []string) []string {
+	keys := make([]string, len(cids))
+	for i, cid := range cids {
+		keys[i] = itemCacheKey(cid)
+	}
+	return keys
+}
+
+// copyCollection returns a copy of a cached Collection, so callers can't modify the cache.
+func copyCollection(c *Collection) *Collection {
+	cc := *c
+	return &cc
+}
+
+// copyItem returns a copy of a cached Item, so callers can't modify the cache.
+func copyItem(i *Item) *Item {
+	ic := *i
+	if i.Tags != nil {
+		ic.Tags = make([]Tag, len(i.Tags))
+		for k, t := range i.Tags {
+			ic.Tags[k] = append(Tag(nil), t...)
+		}
+	}
+	return &ic
+}
@@ -0,0 +1,172 @@
+package resource
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache(2)
+
+	c.add("a", 1, c.generation())
+	c.add("b", 2, c.generation())
+	// "a" is now the most recently used
+	if _, ok := c.get("a"); !ok {
+		t.Error("a should be cached.")
+	}
+	c.add("c", 3, c.generation())
+	if _, ok := c.get("b"); ok {
+		t.Error("b should be evicted.")
+	}
+	if v, ok := c.get("c"); !ok || v.(int) != 3 {
+		t.Error("c should be cached.")
+	}
+
+	// A value read before an invalidation is not cached
+	gen := c.generation()
+	c.remove("d")
+	c.add("d", 4, gen)
+	if _, ok := c.get("d"); ok {
+		t.Error("Stale d should not be cached.")
+	}
+
+	c.purge()
+	if _, ok := c.get("a"); ok {
+		t.Error("a should be purged.")
+	}
+
+	// A nil cache is disabled
+	var nc *lruCache
+	nc.add("a", 1, nc.generation())
+	if _, ok := nc.get("a"); ok {
+		t.Error("Disabled cache should not cache anything.")
+	}
+}
+
+func TestReadItemCache(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	item := &Item{CID: "QmCacheItem1", Name: "Cache Item", Tags: []Tag{{"cache", "a"}}}
+	err = ds.CreateOrUpdateItem(item)
+	if err != nil {
+		t.Fatalf("Unable to create item. Error: %s", err)
+	}
+
+	i, err := ds.ReadItem(item.CID)
+	if err != nil {
+		t.Fatalf("Unable to read item. Error: %s", err)
+	}
+	// Modifying the returned item doesn't affect the cache
+	i.Name = "Modified"
+	i.Tags[0][1] = "modified"
+	i, err = ds.ReadItem(item.CID)
+	if err != nil {
+		t.Fatalf("Unable to read item. Error: %s", err)
+	}
+	if i.Name != item.Name || !reflect.DeepEqual(i.Tags, item.Tags) {
+		t.Errorf("Cached item is modified: %v", i)
+	}
+
+	err = ds.AddItemTag(item.CID, Tag{"cache", "b"})
+	if err != nil {
+		t.Fatalf("Unable to add tag. Error: %s", err)
+	}
+	i, err = ds.ReadItem(item.CID)
+	if err != nil {
+		t.Fatalf("Unable to read item. Error: %s", err)
+	}
+	if len(i.Tags) != 2 {
+		t.Errorf("Item should have 2 tags after AddItemTag, got %v", i.Tags)
+	}
+
+	err = ds.Update(func(tx *Tx) error {
+		return tx.CreateOrUpdateItem(&Item{CID: item.CID, Name: "Cache Item Tx"})
+	})
+	if err != nil {
+		t.Fatalf("Unable to run transaction. Error: %s", err)
+	}
+	i, err = ds.ReadItem(item.CID)
+	if err != nil {
+		t.Fatalf("Unable to read item. Error: %s", err)
+	}
+	if i.Name != "Cache Item Tx" {
+		t.Errorf("Item name should be updated by Update, got %s", i.Name)
+	}
+
+	err = ds.DelItem(item.CID)
+	if err != nil {
+		t.Fatalf("Unable to delete item. Error: %s", err)
+	}
+	_, err = ds.ReadItem(item.CID)
+	if err != ErrCIDNotFound {
+		t.Errorf("Deleted item should not be read from cache. Error: %v", err)
+	}
+}
+
+func TestReadCollectionCacheConcurrent(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	c := &Collection{IPNSAddress: "cache.com", Name: "Cache"}
+	err = ds.CreateOrUpdateCollection(c)
+	if err != nil {
+		t.Fatalf("Unable to create collection. Error: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 50; k++ {
+				_, err := ds.ReadCollection(c.IPNSAddress)
+				if err != nil {
+					t.Errorf("Unable to read collection. Error: %s", err)
+					return
+				}
+			}
+		}()
+	}
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: c.IPNSAddress, Name: "Cache Updated"})
+	if err != nil {
+		t.Errorf("Unable to update collection. Error: %s", err)
+	}
+	wg.Wait()
+
+	rc, err := ds.ReadCollection(c.IPNSAddress)
+	if err != nil {
+		t.Fatalf("Unable to read collection. Error: %s", err)
+	}
+	if rc.Name != "Cache Updated" {
+		t.Errorf("Collection name should be updated, got %s", rc.Name)
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	ds, err := NewDatastoreWithOptions(dbPath, DefaultOptions().WithCacheSize(0))
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	item := &Item{CID: "QmCacheItem2", Name: "No Cache Item"}
+	err = ds.CreateOrUpdateItem(item)
+	if err != nil {
+		t.Fatalf("Unable to create item. Error: %s", err)
+	}
+	i, err := ds.ReadItem(item.CID)
+	if err != nil {
+		t.Fatalf("Unable to read item. Error: %s", err)
+	}
+	if i.Name != item.Name {
+		t.Errorf("Wrong item name %s", i.Name)
+	}
+}
@@ -2,7 +2,11 @@ package resource
 
 import (
 	"errors"
+	"fmt"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"encoding/binary"
 
@@ -36,8 +40,57 @@ var (
 
 	// ErrCantDelRootFolder is returned when trying to delete a root folder.
 	ErrCantDelRootFolder = errors.New("Root folder can't be deleted")
+
+	// ErrFolderCycle is returned when an operation would make a folder its own descendant.
+	ErrFolderCycle = errors.New("Folder can't be its own descendant")
+
+	// ErrStopIteration can be returned by the callback of Iterate* methods to stop iterating without an error.
+	ErrStopIteration = errors.New("Stop iteration")
+
+	// ErrInvalidTag is returned when a tag is empty.
+	ErrInvalidTag = errors.New("Invalid tag")
+
+	// ErrInvalidFolderPath is returned when a folder path is malformed.
+	ErrInvalidFolderPath = errors.New("Invalid folder path")
+
+	// ErrFolderNotLinked is returned when a folder isn't linked to the parent folder.
+	ErrFolderNotLinked = errors.New("Folder is not linked to the parent folder")
+
+	// ErrFolderExists is returned when the destination folder already exists.
+	ErrFolderExists = errors.New("Folder already exists")
+
+	// ErrCantMoveRootFolder is returned when trying to move a root folder.
+	ErrCantMoveRootFolder = errors.New("Root folder can't be moved")
+
+	// ErrUnsupportedManifestVersion is returned when importing a manifest of an unknown version.
+	ErrUnsupportedManifestVersion = errors.New("Unsupported manifest version")
+
+	// ErrInvalidManifest is returned when a manifest misses required fields.
+	ErrInvalidManifest = errors.New("Invalid manifest")
+
+	// ErrItemExists is returned when inserting an item whose CID already exists.
+	ErrItemExists = errors.New("Item already exists")
+
+	// ErrInvalidOrder is returned when a new order isn't a permutation of the ordered list.
+	ErrInvalidOrder = errors.New("Invalid order")
+
+	// ErrClosed is returned when using a closed Datastore.
+	ErrClosed = errors.New("Datastore is closed")
+
+	// ErrInvalidLimit is returned when a limit isn't positive.
+	ErrInvalidLimit = errors.New("Invalid limit")
 )
 
+// CIDsNotFoundError is returned by bulk operations when some CIDs are not found in Datastore.
+// The other CIDs are still processed.
+type CIDsNotFoundError struct {
+	CIDs []string
+}
+
+func (e *CIDsNotFoundError) Error() string {
+	return "CIDs not found: " + strings.Join(e.CIDs, ", ")
+}
+
 type FilterFlag int
 
 const (
@@ -48,20 +101,61 @@ const (
 
 const dbKeySep string = "::"
 
+// dbKey is a key of Datastore made of parts joined by dbKeySep.
+// In a part, "\" is escaped as "\\", and ":" as "\:" if it's the first char or next to another ":", so dbKeySep
+// never appears in an escaped part. Only a part ending with a single ":" leaves an unescaped ":" next to dbKeySep, so an
+// odd run of unescaped ":" is that ":" followed by separators. Parts with only single inner ":", like tag strings, are
+// not changed by escaping.
 type dbKey []string
 
 func newDbKeyFromStr(str string) dbKey {
-	parts := strings.Split(str, dbKeySep)
-	for i := 0; i < len(parts); i++ {
-		parts[i] = strings.ReplaceAll(parts[i], "\\:\\:", dbKeySep)
+	var parts []string
+	var part strings.Builder
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		switch {
+		case c == '\\' && i+1 < len(str):
+			i++
+			part.WriteByte(str[i])
+		case c == ':':
+			// A run of unescaped ":" is an optional trailing ":" of the part and separators around empty parts
+			n := len(str[i:]) - len(strings.TrimLeft(str[i:], ":"))
+			if n%2 == 1 {
+				part.WriteByte(':')
+			}
+			for j := 0; j < n/2; j++ {
+				parts = append(parts, part.String())
+				part.Reset()
+			}
+			i += n - 1
+		default:
+			part.WriteByte(c)
+		}
+	}
+	return append(parts, part.String())
+}
+
+// escapeDbKeyPart escapes a part of dbKey. See dbKey.
+func escapeDbKeyPart(part string) string {
+	var b strings.Builder
+	for i := 0; i < len(part); i++ {
+		c := part[i]
+		switch {
+		case c == '\\':
+			b.WriteString("\\\\")
+		case c == ':' && (i == 0 || part[i-1] == ':' || i+1 < len(part) && part[i+1] == ':'):
+			b.WriteString("\\:")
+		default:
+			b.WriteByte(c)
+		}
 	}
-	return parts
+	return b.String()
 }
 
 func (k dbKey) String() string {
 	var escaped []string
 	for _, keyPart := range k {
-		escaped = append(escaped, strings.ReplaceAll(keyPart, dbKeySep, "\\:\\:"))
+		escaped = append(escaped, escapeDbKeyPart(keyPart))
 	}
 
 	return strings.Join(escaped, dbKeySep)
@@ -75,6 +169,24 @@ func (k dbKey) IsEmpty() bool {
 	return len(k) == 0
 }
 
+// key returns the bytes of a key of the Datastore. If the Datastore has a namespace, the key is prefixed with
+// ns::[namespace], so Datastores with different namespaces in one Badger DB don't collide.
+func (d *Datastore) key(k dbKey) []byte {
+	if d.namespace == "" {
+		return k.Bytes()
+	}
+	return append(dbKey{"ns", d.namespace}, k...).Bytes()
+}
+
+// parseKey parses a key read from Badger, dropping the namespace prefix added by key.
+func (d *Datastore) parseKey(b []byte) dbKey {
+	k := newDbKeyFromStr(string(b))
+	if d.namespace == "" {
+		return k
+	}
+	return k[2:]
+}
+
 // Datastore is a store for saving resource collections data. Including collections and their resource items.
 // For now it is a struct using BadgerDB. Later on it will be refactored as an interface with multiple database implements.
 // Key-Values:
@@ -85,51 +197,227 @@ func (k dbKey) IsEmpty() bool {
 // collection::[ipns]::name
 // collection::[ipns]::description
 // collection::[ipns]::ismine
+// collection::[ipns]::updated_at = [unixNano] # Last time the collection, its folders or its items changed
+// collection::[ipns]::published
+// collection::[ipns]::published_cid
 // collection_item::[ipns]::[cid] = [cid]
 // folders::[ipns]::[folderPath] = [folderPath] # The folderPath of root folder is ""
 // folder::[ipns]::[folderPath]::children = [listOfChildFolderNames]
+// folder::[ipns]::[folderPath]::links = [listOfLinkedParentFolderPaths]
 // folder_item::[ipns]::[folderPath]::[cid] = [cid]
+// folder_order::[ipns]::[folderPath] = [listOfItemCIDs] # Manual order of items in the folder set by SetFolderItemOrder
 // items::[cid] = [cid]
 // item::[cid]::name
+// item::[cid]::preview
+// item::[cid]::size
+// item::[cid]::content_type
+// item::[cid]::created_at = [unixNano]
+// item::[cid]::views = [viewCount]
 // item_collection::[cid]::[ipns] = [ipns]
 // item_tag::[cid]::[tagStr] = [tagStr]
 // item_folder::[cid]::[ipns]::[folderPath] = [folderPath]
+// recent::[createdAtHex]::[cid] = [cid] # createdAtHex is the big endian unix nano in hex, so keys sort by time
+// type_item::[contentType]::[cid] = [cid]
 // tags::[tagStr] = [tagStr]
 // tag::[tagStr].count = [itemCount]
+// tag::[tagStr]::last_used = [unixNano] # Last time the tag is added to an item
 // tag_item::[tagStr]::[cid] = [cid]
+//
+// If Options.Namespace is set, every key is prefixed with ns::[namespace].
 type Datastore struct {
-	db *badger.DB
+	db               *badger.DB
+	cache            *lruCache
+	normalizeTags    bool
+	nativeDropPrefix bool
+	namespace        string
+	// ownsDB is true if db is opened by the Datastore, so Close closes it
+	ownsDB bool
+	// options are the Options the Datastore is created with
+	options Options
+	// mu is held for reading by operations and for writing by Close, so Close waits for running operations.
+	mu        sync.RWMutex
+	closeOnce sync.Once
+	closeErr  error
+	// closed is set by Close. It's guarded by mu.
+	closed bool
 }
 
-// NewDatastore creates a new Datastore.
+// NewDatastore creates a new Datastore with DefaultOptions.
 func NewDatastore(dbPath string) (*Datastore, error) {
+	return NewDatastoreWithOptions(dbPath, DefaultOptions())
+}
+
+// NewDatastoreWithOptions creates a new Datastore with options.
+func NewDatastoreWithOptions(dbPath string, options Options) (*Datastore, error) {
 	if dbPath == "" {
 		panic("Invalid dbPath")
 	}
 
-	opts := badger.DefaultOptions(dbPath)
+	opts := badger.DefaultOptions(dbPath).WithSyncWrites(options.SyncWrites).WithLogger(options.Logger)
 	db, err := badger.Open(opts)
 	if err != nil {
 		return nil, err
 	}
-	return &Datastore{db: db}, nil
+	d := newDatastore(db, options)
+	d.ownsDB = true
+	return d, nil
+}
+
+// NewDatastoreWithDB creates a new Datastore with options on a Badger DB which is already open. Several Datastores
+// with different Options.Namespace can share one Badger DB this way. Close of the Datastore leaves the Badger DB open,
+// and it must not be closed before the Datastore. SyncWrites and Logger of options are ignored, as they are set when
+// the Badger DB is opened.
+func NewDatastoreWithDB(db *badger.DB, options Options) *Datastore {
+	if db == nil {
+		panic("Invalid db")
+	}
+	return newDatastore(db, options)
+}
+
+func newDatastore(db *badger.DB, options Options) *Datastore {
+	return &Datastore{
+		db:               db,
+		cache:            newLRUCache(options.CacheSize),
+		normalizeTags:    options.NormalizeTags,
+		nativeDropPrefix: options.NativeDropPrefix,
+		namespace:        options.Namespace,
+		options:          options,
+	}
+}
+
+// normalizeTag returns t.Normalized() if NormalizeTags is enabled in Options.
+func (d *Datastore) normalizeTag(t Tag) Tag {
+	if !d.normalizeTags {
+		return t
+	}
+	return t.Normalized()
 }
 
-// Close Datastore
+// Close Datastore. It waits for running operations to finish, and operations called after it return ErrClosed.
+// A Badger DB passed to NewDatastoreWithDB is left open.
+// It's safe to call Close more than once and from multiple goroutines. Later calls return the result of the first one.
 func (d *Datastore) Close() error {
-	return d.db.Close()
+	d.closeOnce.Do(func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		d.closed = true
+		d.cache.purge()
+		if d.ownsDB {
+			d.closeErr = d.db.Close()
+		}
+	})
+	return d.closeErr
+}
+
+// guard runs fn unless Datastore is closed, and keeps Close from closing the Badger DB until fn returns.
+// ErrClosed is returned if Datastore is closed. fn must not call Close or other guarded methods of Datastore, or it
+// may deadlock with a concurrent Close.
+func (d *Datastore) guard(fn func() error) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return ErrClosed
+	}
+	return fn()
+}
+
+// view runs fn in a read-only transaction like badger.DB.View. ErrClosed is returned if Datastore is closed.
+func (d *Datastore) view(fn func(txn *badger.Txn) error) error {
+	return d.guard(func() error {
+		return d.db.View(fn)
+	})
+}
+
+// Ping checks if Datastore is open and can serve a read. ErrClosed is returned if it's closed.
+func (d *Datastore) Ping() error {
+	return d.view(func(txn *badger.Txn) error {
+		d.hasPrefixInTxn(txn, dbKey{"collections_all", ""})
+		return nil
+	})
+}
+
+// maxConflictRetries is the number of times update retries a transaction which conflicts with a concurrent one.
+const maxConflictRetries = 50
+
+// update runs fn in a read-write transaction like badger.DB.Update, but retries the whole transaction if it
+// conflicts with a concurrent transaction. fn may run more than once, so it must not keep state between runs.
+// ErrClosed is returned if Datastore is closed.
+func (d *Datastore) update(fn func(txn *badger.Txn) error) error {
+	return d.guard(func() error {
+		var err error
+		for i := 0; i < maxConflictRetries; i++ {
+			err = d.db.Update(fn)
+			if err != badger.ErrConflict {
+				return err
+			}
+			// Back off a little so the conflicting transactions don't collide again
+			time.Sleep(time.Duration(i) * time.Millisecond)
+		}
+		return err
+	})
+}
+
+// DB returns the underlying Badger DB.
+// It's an escape hatch for maintenance tools and is not part of the stable API. The key layout may change between versions.
+// Writes made through it bypass the read cache, so reopen Datastore after modifying data with it.
+// It's the live handle used by Datastore, so callers must not Close it. Close Datastore instead.
+func (d *Datastore) DB() *badger.DB {
+	return d.db
+}
+
+// DebugScan returns at most limit keys starting with the raw prefix, in key order. The prefix and the keys don't include
+// the namespace of the Datastore. The prefix is matched against the stored, escaped keys, while the keys are returned
+// with their parts unescaped and joined by "::" for display. ErrInvalidLimit is returned if limit isn't positive.
+// It's meant for diagnosing index problems in admin tools only. It's unstable and its output may change between versions.
+func (d *Datastore) DebugScan(prefix string, limit int) ([]string, error) {
+	if limit <= 0 {
+		return nil, ErrInvalidLimit
+	}
+
+	var keys []string
+	err := d.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		p := append(d.key(dbKey{""}), prefix...)
+		for it.Seek(p); it.ValidForPrefix(p) && len(keys) < limit; it.Next() {
+			keys = append(keys, strings.Join(d.parseKey(it.Item().Key()), dbKeySep))
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// DropAll deletes all data in Datastore. Datastore stays open and usable after it.
+// If the Datastore has a namespace, only the data in the namespace is deleted. Otherwise the whole Badger DB is
+// deleted, including the data of all namespaces.
+func (d *Datastore) DropAll() error {
+	defer d.cache.purge()
+	return d.guard(func() error {
+		if d.namespace != "" {
+			return d.db.DropPrefix(d.key(dbKey{""}))
+		}
+		return d.db.DropAll()
+	})
 }
 
 func (d *Datastore) checkIPNS(ipns string) error {
+	return d.view(func(txn *badger.Txn) error {
+		return d.checkIPNSInTxn(txn, ipns)
+	})
+}
+
+func (d *Datastore) checkIPNSInTxn(txn *badger.Txn, ipns string) error {
 	if ipns == "" {
 		panic("Invalid ipns.")
 	}
 
-	err := d.db.View(func(txn *badger.Txn) error {
-		k := dbKey{"collections_all", ipns}
-		_, err := txn.Get(k.Bytes())
-		return err
-	})
+	k := dbKey{"collections_all", ipns}
+	_, err := txn.Get(d.key(k))
 	if err == badger.ErrKeyNotFound {
 		return ErrIPNSNotFound
 	}
@@ -137,236 +425,592 @@ func (d *Datastore) checkIPNS(ipns string) error {
 }
 
 func (d *Datastore) checkCID(cid string) error {
+	return d.view(func(txn *badger.Txn) error {
+		return d.checkCIDInTxn(txn, cid)
+	})
+}
+
+func (d *Datastore) checkCIDInTxn(txn *badger.Txn, cid string) error {
 	if cid == "" {
 		panic("Invalid cid.")
 	}
 
-	err := d.db.View(func(txn *badger.Txn) error {
-		k := dbKey{"items", cid}
-		_, err := txn.Get(k.Bytes())
-		return err
-	})
+	k := dbKey{"items", cid}
+	_, err := txn.Get(d.key(k))
 	if err == badger.ErrKeyNotFound {
 		return ErrCIDNotFound
 	}
 	return err
 }
 
+// CollectionExists checks if a Collection exists in Datastore.
+func (d *Datastore) CollectionExists(ipns string) (bool, error) {
+	err := d.checkIPNS(ipns)
+	if err == ErrIPNSNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ItemExists checks if an Item exists in Datastore.
+func (d *Datastore) ItemExists(cid string) (bool, error) {
+	err := d.checkCID(cid)
+	if err == ErrCIDNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 // CreateOrUpdateCollection update collection information
 func (d *Datastore) CreateOrUpdateCollection(c *Collection) error {
+	err := d.update(func(txn *badger.Txn) error {
+		return d.createOrUpdateCollectionInTxn(txn, c)
+	})
+	d.cache.remove(collectionCacheKey(c.IPNSAddress))
+
+	return err
+}
+
+func (d *Datastore) createOrUpdateCollectionInTxn(txn *badger.Txn, c *Collection) error {
 	if c.Name == "" || c.IPNSAddress == "" {
 		panic("Invalid parameters.")
 	}
 
-	// TODO: IPNS Address validate
-
-	err := d.db.Update(func(txn *badger.Txn) error {
-
-		p := dbKey{"collections_all", c.IPNSAddress}
-		err := txn.Set(p.Bytes(), []byte(c.IPNSAddress))
-		if err != nil {
-			return err
-		}
+	return d.writeCollectionInTxn(txn, c)
+}
 
-		p = dbKey{"collection", c.IPNSAddress}
+// CreateEmptyCollection creates a draft collection with an empty name and only the root folder.
+// The name can be set later by SetCollectionName. An existing collection is left untouched.
+func (d *Datastore) CreateEmptyCollection(ipns string) error {
+	if ipns == "" {
+		panic("Invalid parameters.")
+	}
 
-		err = txn.Set(append(p, "name").Bytes(), []byte(c.Name))
-		if err != nil {
-			return err
-		}
-		err = txn.Set(append(p, "description").Bytes(), []byte(c.Description))
-		if err != nil {
-			return err
-		}
-		var ismine string
-		if c.IsMine {
-			ismine = "1"
-			// collections_mine::[ipns] = [ipns]
-			err = txn.Set(dbKey{"collections_mine", c.IPNSAddress}.Bytes(), []byte(c.IPNSAddress))
-			if err != nil {
-				return err
-			}
-		} else {
-			ismine = "0"
-			// collections_others::[ipns] = [ipns]
-			err = txn.Set(dbKey{"collections_others", c.IPNSAddress}.Bytes(), []byte(c.IPNSAddress))
-			if err != nil {
-				return err
-			}
-		}
-		// collection::[ipns]::ismine
-		err = txn.Set(append(p, "ismine").Bytes(), []byte(ismine))
-		if err != nil {
-			return err
+	err := d.update(func(txn *badger.Txn) error {
+		err := d.checkIPNSInTxn(txn, ipns)
+		if err == nil {
+			return nil
 		}
-
-		// Create root folder
-		err = d.createOrUpdateFolderInTxn(txn, &Folder{IPNSAddress: c.IPNSAddress})
-		if err != nil {
+		if err != ErrIPNSNotFound {
 			return err
 		}
-
-		return nil
+		return d.writeCollectionInTxn(txn, &Collection{IPNSAddress: ipns})
 	})
+	d.cache.remove(collectionCacheKey(ipns))
 
 	return err
 }
 
-// ReadCollection reads Collection data from database.
-func (d *Datastore) ReadCollection(ipns string) (*Collection, error) {
-	err := d.checkIPNS(ipns)
+// writeCollectionInTxn writes all fields of a collection and creates its root folder. Fields are not validated.
+func (d *Datastore) writeCollectionInTxn(txn *badger.Txn, c *Collection) error {
+	// TODO: IPNS Address validate
+
+	p := dbKey{"collections_all", c.IPNSAddress}
+	err := txn.Set(d.key(p), []byte(c.IPNSAddress))
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var c *Collection
-	err = d.db.View(func(txn *badger.Txn) error {
-		p := dbKey{"collection", ipns}
+	p = dbKey{"collection", c.IPNSAddress}
 
-		item, err := txn.Get(append(p, "name").Bytes())
+	err = txn.Set(d.key(append(p, "name")), []byte(c.Name))
+	if err != nil {
+		return err
+	}
+	err = txn.Set(d.key(append(p, "description")), []byte(c.Description))
+	if err != nil {
+		return err
+	}
+	err = d.setCollectionMineInTxn(txn, c.IPNSAddress, c.IsMine)
+	if err != nil {
+		return err
+	}
+	// Zero publish state means it's not set by the caller, so the stored one is kept
+	if c.Published || c.PublishedCID != "" {
+		err = d.setCollectionPublishedInTxn(txn, c.IPNSAddress, c.Published, c.PublishedCID)
 		if err != nil {
 			return err
 		}
-		n, err := item.ValueCopy(nil)
+	}
+
+	// Create root folder
+	err = d.createOrUpdateFolderInTxn(txn, &Folder{IPNSAddress: c.IPNSAddress})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// SetCollectionMine sets IsMine of a collection. Other fields of the collection and its folders are untouched.
+func (d *Datastore) SetCollectionMine(ipns string, mine bool) error {
+	err := d.update(func(txn *badger.Txn) error {
+		err := d.checkIPNSInTxn(txn, ipns)
 		if err != nil {
 			return err
 		}
-		item, err = txn.Get(append(p, "description").Bytes())
+		err = d.setCollectionMineInTxn(txn, ipns, mine)
 		if err != nil {
 			return err
 		}
-		d, err := item.ValueCopy(n)
+		return d.touchCollectionInTxn(txn, ipns)
+	})
+	d.cache.remove(collectionCacheKey(ipns))
+	return err
+}
+
+// setCollectionMineInTxn sets collection::[ipns]::ismine and moves the collection between collections_mine and collections_others.
+func (d *Datastore) setCollectionMineInTxn(txn *badger.Txn, ipns string, mine bool) error {
+	var ismine string
+	var err error
+	if mine {
+		ismine = "1"
+		// collections_mine::[ipns] = [ipns]
+		err = txn.Set(d.key(dbKey{"collections_mine", ipns}), []byte(ipns))
 		if err != nil {
 			return err
 		}
-		item, err = txn.Get(append(p, "ismine").Bytes())
+		err = txn.Delete(d.key(dbKey{"collections_others", ipns}))
 		if err != nil {
 			return err
 		}
-		ismine := false
-		err = item.Value(func(val []byte) error {
-			s := string(val)
-			if s == "1" {
-				ismine = true
-			}
-			return nil
-		})
+	} else {
+		ismine = "0"
+		// collections_others::[ipns] = [ipns]
+		err = txn.Set(d.key(dbKey{"collections_others", ipns}), []byte(ipns))
 		if err != nil {
 			return err
 		}
-
-		c = &Collection{IPNSAddress: ipns, Name: string(n), Description: string(d), IsMine: ismine}
-
-		return nil
-	})
-
-	return c, err
-}
-
-func (d *Datastore) dropPrefix(txn *badger.Txn, prefix dbKey) error {
-	if prefix.IsEmpty() {
-		panic("Empty prefix.")
-	}
-
-	opts := badger.DefaultIteratorOptions
-	opts.PrefetchValues = false
-	it := txn.NewIterator(opts)
-	defer it.Close()
-
-	var dst []byte
-	for it.Seek(prefix.Bytes()); it.ValidForPrefix(prefix.Bytes()); it.Next() {
-		item := it.Item()
-		err := txn.Delete(item.KeyCopy(dst))
+		err = txn.Delete(d.key(dbKey{"collections_mine", ipns}))
 		if err != nil {
 			return err
 		}
 	}
-
-	return nil
+	// collection::[ipns]::ismine
+	return txn.Set(d.key(dbKey{"collection", ipns, "ismine"}), []byte(ismine))
 }
 
-// DelCollection deletes a collection from datastore.
-// Deleting a collection won't delete items that belongs to the collection.
-func (d *Datastore) DelCollection(ipns string) error {
-	err := d.checkIPNS(ipns)
-	if err != nil {
-		return err
+// SetCollectionName sets Name of a collection. Other fields of the collection and its folders are untouched.
+func (d *Datastore) SetCollectionName(ipns, name string) error {
+	if name == "" {
+		panic("Invalid parameters.")
 	}
+	return d.setCollectionField(ipns, "name", name)
+}
 
-	err = d.db.Update(func(txn *badger.Txn) error {
+// SetCollectionDescription sets Description of a collection. Other fields of the collection and its folders are untouched.
+func (d *Datastore) SetCollectionDescription(ipns, desc string) error {
+	return d.setCollectionField(ipns, "description", desc)
+}
 
-		items, err := d.ReadCollectionItems(ipns)
+// setCollectionField sets collection::[ipns]::[field] of an existing collection.
+func (d *Datastore) setCollectionField(ipns, field, value string) error {
+	err := d.update(func(txn *badger.Txn) error {
+		err := d.checkIPNSInTxn(txn, ipns)
 		if err != nil {
 			return err
 		}
-
-		k := dbKey{"collections_all", ipns}
-		err = txn.Delete(k.Bytes())
+		err = txn.Set(d.key(dbKey{"collection", ipns, field}), []byte(value))
 		if err != nil {
 			return err
 		}
+		return d.touchCollectionInTxn(txn, ipns)
+	})
+	d.cache.remove(collectionCacheKey(ipns))
+	return err
+}
 
-		k = dbKey{"collections_mine", ipns}
-		err = txn.Delete(k.Bytes())
+// SetCollectionPublished records that a collection is published to IPNS with the manifest root rootCID.
+// An empty rootCID clears the publish state, e.g. after the IPNS record is withdrawn.
+// It doesn't bump UpdatedAt, so the publisher can compare UpdatedAt with the time of its last publish.
+func (d *Datastore) SetCollectionPublished(ipns, rootCID string) error {
+	err := d.update(func(txn *badger.Txn) error {
+		err := d.checkIPNSInTxn(txn, ipns)
 		if err != nil {
 			return err
 		}
+		return d.setCollectionPublishedInTxn(txn, ipns, rootCID != "", rootCID)
+	})
+	d.cache.remove(collectionCacheKey(ipns))
+	return err
+}
 
-		k = dbKey{"collections_others", ipns}
-		err = txn.Delete(k.Bytes())
-		if err != nil {
-			return err
-		}
+// setCollectionPublishedInTxn sets collection::[ipns]::published and collection::[ipns]::published_cid.
+func (d *Datastore) setCollectionPublishedInTxn(txn *badger.Txn, ipns string, published bool, rootCID string) error {
+	p := dbKey{"collection", ipns}
 
-		prefix := dbKey{"collection", ipns}
-		err = d.dropPrefix(txn, prefix)
-		if err != nil {
-			return err
-		}
+	v := "0"
+	if published {
+		v = "1"
+	}
+	err := txn.Set(d.key(append(p, "published")), []byte(v))
+	if err != nil {
+		return err
+	}
+	return txn.Set(d.key(append(p, "published_cid")), []byte(rootCID))
+}
 
-		prefix = dbKey{"collection_item", ipns}
-		err = d.dropPrefix(txn, prefix)
-		if err != nil {
+// ReadCollection reads Collection data from database.
+// Collections are cached if the cache is enabled in Options.
+func (d *Datastore) ReadCollection(ipns string) (*Collection, error) {
+	key := collectionCacheKey(ipns)
+	if v, ok := d.cache.get(key); ok {
+		// UpdatedAt changes with items and folders, which don't invalidate the cached collection
+		c := copyCollection(v.(*Collection))
+		err := d.view(func(txn *badger.Txn) error {
+			var err error
+			c.UpdatedAt, err = d.readCollectionUpdatedAtInTxn(txn, ipns)
 			return err
-		}
-
-		prefix = dbKey{"folders", ipns}
-		err = d.dropPrefix(txn, prefix)
+		})
 		if err != nil {
-			return err
+			return nil, err
 		}
+		return c, nil
+	}
+	gen := d.cache.generation()
 
-		prefix = dbKey{"folder", ipns}
-		err = d.dropPrefix(txn, prefix)
-		if err != nil {
-			return err
-		}
+	var c *Collection
+	err := d.view(func(txn *badger.Txn) error {
+		var err error
+		c, err = d.readCollectionInTxn(txn, ipns)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		// Delete item-folder / item-collection relationship
-		for _, v := range items {
-			p := dbKey{"item_folder", v, ipns}
-			err = d.dropPrefix(txn, p)
-			if err != nil {
-				return err
-			}
+	d.cache.add(key, copyCollection(c), gen)
+	return c, nil
+}
 
-			k = dbKey{"item_collection", v, ipns}
-			err = txn.Delete(k.Bytes())
+// ReadCollections reads many collections in one read transaction, in the order of ipnsList.
+// If skipMissing is true, collections not found are left out, otherwise ErrIPNSNotFound is returned.
+func (d *Datastore) ReadCollections(ipnsList []string, skipMissing bool) ([]*Collection, error) {
+	cs := []*Collection{}
+	err := d.view(func(txn *badger.Txn) error {
+		for _, ipns := range ipnsList {
+			c, err := d.readCollectionInTxn(txn, ipns)
+			if err == ErrIPNSNotFound && skipMissing {
+				continue
+			}
 			if err != nil {
 				return err
 			}
+			cs = append(cs, c)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cs, nil
+}
 
+func (d *Datastore) readCollectionInTxn(txn *badger.Txn, ipns string) (*Collection, error) {
+	err := d.checkIPNSInTxn(txn, ipns)
+	if err != nil {
+		return nil, err
+	}
+
+	p := dbKey{"collection", ipns}
+
+	item, err := txn.Get(d.key(append(p, "name")))
+	if err != nil {
+		return nil, err
+	}
+	n, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	item, err = txn.Get(d.key(append(p, "description")))
+	if err != nil {
+		return nil, err
+	}
+	desc, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	item, err = txn.Get(d.key(append(p, "ismine")))
+	if err != nil {
+		return nil, err
+	}
+	ismine := false
+	err = item.Value(func(val []byte) error {
+		s := string(val)
+		if s == "1" {
+			ismine = true
+		}
 		return nil
 	})
-	return err
+	if err != nil {
+		return nil, err
+	}
+
+	updatedAt, err := d.readCollectionUpdatedAtInTxn(txn, ipns)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Collection{IPNSAddress: ipns, Name: string(n), Description: string(desc), IsMine: ismine, UpdatedAt: updatedAt}
+
+	// Collections saved before publish state was added have no published keys
+	item, err = txn.Get(d.key(append(p, "published")))
+	if err != nil && err != badger.ErrKeyNotFound {
+		return nil, err
+	}
+	if item != nil {
+		err = item.Value(func(val []byte) error {
+			c.Published = string(val) == "1"
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	item, err = txn.Get(d.key(append(p, "published_cid")))
+	if err != nil && err != badger.ErrKeyNotFound {
+		return nil, err
+	}
+	if item != nil {
+		pcid, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		c.PublishedCID = string(pcid)
+	}
+
+	return c, nil
+}
+
+// readCollectionUpdatedAtInTxn reads collection::[ipns]::updated_at. Zero time is returned if it's never set.
+func (d *Datastore) readCollectionUpdatedAtInTxn(txn *badger.Txn, ipns string) (time.Time, error) {
+	item, err := txn.Get(d.key(dbKey{"collection", ipns, "updated_at"}))
+	if err == badger.ErrKeyNotFound {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var updatedAt time.Time
+	err = item.Value(func(val []byte) error {
+		updatedAt = time.Unix(0, int64(binary.BigEndian.Uint64(val)))
+		return nil
+	})
+	return updatedAt, err
+}
+
+// touchCollectionInTxn sets collection::[ipns]::updated_at to now.
+func (d *Datastore) touchCollectionInTxn(txn *badger.Txn, ipns string) error {
+	uBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(uBytes, uint64(time.Now().UnixNano()))
+	return txn.Set(d.key(dbKey{"collection", ipns, "updated_at"}), uBytes)
+}
+
+// touchItemCollectionsInTxn touches all collections having the item.
+func (d *Datastore) touchItemCollectionsInTxn(txn *badger.Txn, cid string) error {
+	// item_collection::[cid]::[ipns]
+	for _, k := range d.readKeysInTxn(txn, dbKey{"item_collection", cid, ""}) {
+		err := d.touchCollectionInTxn(txn, k[2])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Datastore) dropPrefix(txn *badger.Txn, prefix dbKey) error {
+	if prefix.IsEmpty() {
+		panic("Empty prefix.")
+	}
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var dst []byte
+	for it.Seek(d.key(prefix)); it.ValidForPrefix(d.key(prefix)); it.Next() {
+		item := it.Item()
+		err := txn.Delete(item.KeyCopy(dst))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dropPrefixesNative drops keys with the prefixes by badger.DB.DropPrefix, which isn't bounded by the transaction size.
+// It blocks all writes of Datastore while it runs and it's not atomic with any transaction.
+func (d *Datastore) dropPrefixesNative(prefixes []dbKey) error {
+	for _, p := range prefixes {
+		if p.IsEmpty() {
+			panic("Empty prefix.")
+		}
+		err := d.guard(func() error {
+			return d.db.DropPrefix(d.key(p))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DelCollection deletes a collection from datastore.
+// Deleting a collection won't delete items that belongs to the collection.
+// If NativeDropPrefix is enabled in Options, keys of the collection's folders are dropped after the transaction. See Options.
+// A collection too large to delete in one transaction is first removed from the collection lists, then its keys are
+// deleted in several transactions.
+func (d *Datastore) DelCollection(ipns string) error {
+	defer d.cache.remove(collectionCacheKey(ipns))
+
+	err := d.checkIPNS(ipns)
+	if err != nil {
+		return err
+	}
+
+	// Keys which only belong to the collection
+	prefixes := []dbKey{
+		{"collection", ipns, ""},
+		{"collection_item", ipns, ""},
+		{"folders", ipns, ""},
+		{"folder", ipns, ""},
+		{"folder_item", ipns, ""},
+		{"folder_order", ipns, ""},
+	}
+
+	err = d.update(func(txn *badger.Txn) error {
+		// Read before collection_item keys are dropped
+		links := d.readCollectionItemLinksInTxn(txn, ipns)
+
+		err := d.delCollectionIndexInTxn(txn, ipns)
+		if err != nil {
+			return err
+		}
+
+		if !d.nativeDropPrefix {
+			for _, p := range prefixes {
+				err = d.dropPrefix(txn, p)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// Delete item-folder / item-collection relationship
+		for _, k := range links {
+			err = txn.Delete(d.key(k))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err == badger.ErrTxnTooBig {
+		return d.delCollectionChunked(ipns, prefixes)
+	}
+	if err != nil || !d.nativeDropPrefix {
+		return err
+	}
+
+	return d.dropPrefixesNative(prefixes)
+}
+
+// delCollectionChunked deletes a collection like DelCollection, but not atomically. The collection is removed from
+// the collection lists first, so it's gone even if deleting the rest of its keys fails.
+func (d *Datastore) delCollectionChunked(ipns string, prefixes []dbKey) error {
+	err := d.update(func(txn *badger.Txn) error {
+		return d.delCollectionIndexInTxn(txn, ipns)
+	})
+	if err != nil {
+		return err
+	}
+
+	var keys []dbKey
+	err = d.view(func(txn *badger.Txn) error {
+		if !d.nativeDropPrefix {
+			for _, p := range prefixes {
+				keys = append(keys, d.readKeysInTxn(txn, p)...)
+			}
+		}
+		keys = append(keys, d.readCollectionItemLinksInTxn(txn, ipns)...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = d.deleteKeysChunked(keys)
+	if err != nil || !d.nativeDropPrefix {
+		return err
+	}
+	return d.dropPrefixesNative(prefixes)
+}
+
+// delCollectionIndexInTxn removes a collection from collections_all, collections_mine and collections_others.
+func (d *Datastore) delCollectionIndexInTxn(txn *badger.Txn, ipns string) error {
+	for _, p := range []string{"collections_all", "collections_mine", "collections_others"} {
+		err := txn.Delete(d.key(dbKey{p, ipns}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCollectionItemLinksInTxn returns the item_folder and item_collection keys of all items in a collection.
+func (d *Datastore) readCollectionItemLinksInTxn(txn *badger.Txn, ipns string) []dbKey {
+	var keys []dbKey
+	// collection_item::[ipns]::[cid]
+	for _, k := range d.readKeysInTxn(txn, dbKey{"collection_item", ipns, ""}) {
+		cid := k[2]
+		// item_folder::[cid]::[ipns]::[folderPath]
+		keys = append(keys, d.readKeysInTxn(txn, dbKey{"item_folder", cid, ipns, ""})...)
+		// item_collection::[cid]::[ipns]
+		keys = append(keys, dbKey{"item_collection", cid, ipns})
+	}
+	return keys
+}
+
+// deleteKeysChunked deletes keys in as many transactions as needed, committing whenever a transaction gets too big.
+func (d *Datastore) deleteKeysChunked(keys []dbKey) error {
+	return d.guard(func() error {
+		txn := d.db.NewTransaction(true)
+		defer func() { txn.Discard() }()
+
+		for _, k := range keys {
+			err := txn.Delete(d.key(k))
+			if err == badger.ErrTxnTooBig {
+				err = txn.Commit()
+				if err != nil {
+					return err
+				}
+				txn = d.db.NewTransaction(true)
+				err = txn.Delete(d.key(k))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return txn.Commit()
+	})
 }
 
 // ListCollections list collections
 func (d *Datastore) ListCollections(mineFlag, emptyFlag FilterFlag) ([]*Collection, error) {
 	keys := make(map[string]bool)
 
-	err := d.db.View(func(txn *badger.Txn) error {
+	err := d.view(func(txn *badger.Txn) error {
 		var p dbKey
 		switch mineFlag {
 		case FilterNone:
@@ -382,10 +1026,9 @@ func (d *Datastore) ListCollections(mineFlag, emptyFlag FilterFlag) ([]*Collecti
 		it := txn.NewIterator(opts)
 		defer it.Close()
 
-		for it.Seek(p.Bytes()); it.ValidForPrefix(p.Bytes()); it.Next() {
+		for it.Seek(d.key(p)); it.ValidForPrefix(d.key(p)); it.Next() {
 			item := it.Item()
-			keyStr := string(item.Key())
-			key := newDbKeyFromStr(keyStr)
+			key := d.parseKey(item.Key())
 
 			keys[key[1]] = true
 		}
@@ -426,762 +1069,2591 @@ func (d *Datastore) ListCollections(mineFlag, emptyFlag FilterFlag) ([]*Collecti
 	return cs, nil
 }
 
+// ListMyCollections lists collections which are mine, using the collections_mine index.
+func (d *Datastore) ListMyCollections() ([]*Collection, error) {
+	var cs []*Collection
+	err := d.view(func(txn *badger.Txn) error {
+		// collections_mine::[ipns]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"collections_mine", ""}) {
+			c, err := d.readCollectionInTxn(txn, k[1])
+			if err != nil {
+				return err
+			}
+			cs = append(cs, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
 // CreateOrUpdateItem update collection information
 func (d *Datastore) CreateOrUpdateItem(i *Item) error {
+	err := d.update(func(txn *badger.Txn) error {
+		return d.createOrUpdateItemInTxn(txn, i)
+	})
+	d.cache.remove(itemCacheKey(i.CID))
+	return err
+}
+
+func (d *Datastore) createOrUpdateItemInTxn(txn *badger.Txn, i *Item) error {
 	if i.CID == "" || i.Name == "" {
 		panic("Invalid parameters.")
 	}
 
-	iOld, _ := d.ReadItem(i.CID)
-
-	err := d.db.Update(func(txn *badger.Txn) error {
+	iOld, err := d.readItemInTxn(txn, i.CID)
+	if err != nil && err != ErrCIDNotFound {
+		return err
+	}
 
-		k := dbKey{"items", i.CID}
-		err := txn.Set(k.Bytes(), []byte(i.CID))
-		if err != nil {
-			return err
-		}
+	return d.writeItemInTxn(txn, i, iOld)
+}
 
-		k = dbKey{"item", i.CID, "name"}
-		err = txn.Set(k.Bytes(), []byte(i.Name))
-		if err != nil {
-			return err
+// InsertItemsFresh creates many new items in one transaction. Unlike CreateOrUpdateItem, existing items are not read
+// to diff their tags, so it's faster for importing items for the first time.
+// If any CID already exists, ErrItemExists is returned and no item is created.
+func (d *Datastore) InsertItemsFresh(items []*Item) error {
+	var cids []string
+	for _, i := range items {
+		if i.CID == "" || i.Name == "" {
+			panic("Invalid parameters.")
 		}
-
-		if iOld != nil {
-			// Delete old item_tag::[cid]::[tagStr]
-			k = dbKey{"item_tag", i.CID}
-			err = d.dropPrefix(txn, k)
-			if err != nil {
-				return err
+		cids = append(cids, i.CID)
+	}
+	defer d.cache.remove(itemCacheKeys(cids)...)
+
+	return d.update(func(txn *badger.Txn) error {
+		for _, i := range items {
+			// Items written earlier in the transaction are found too
+			err := d.checkCIDInTxn(txn, i.CID)
+			if err == nil {
+				return ErrItemExists
 			}
-
-			// Delete old tag_item::[tagStr]::[cid]
-			for _, t := range iOld.Tags {
-				tagKey := dbKey{"tag_item", t.String(), i.CID}.Bytes()
-				err = txn.Delete(tagKey)
-				if err != nil {
-					return err
-				}
-
-				err = d.updateTagItemCount(txn, t, -1)
-				if err != nil {
-					return err
-				}
+			if err != ErrCIDNotFound {
+				return err
 			}
-		}
 
-		// Set new tags
-		for _, t := range i.Tags {
-			err = d.addItemTagInTxn(txn, i.CID, t)
+			err = d.writeItemInTxn(txn, i, nil)
 			if err != nil {
 				return err
 			}
 		}
-
 		return nil
 	})
-	return err
 }
 
-// ReadItem reads Item from database
-func (d *Datastore) ReadItem(cid string) (*Item, error) {
-	err := d.checkCID(cid)
+// writeItemInTxn writes an item. iOld is the item before writing, or nil if the item is new.
+func (d *Datastore) writeItemInTxn(txn *badger.Txn, i *Item, iOld *Item) error {
+	k := dbKey{"items", i.CID}
+	err := txn.Set(d.key(k), []byte(i.CID))
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var i *Item
-	err = d.db.View(func(txn *badger.Txn) error {
-		k := dbKey{"item", cid, "name"}
+	if iOld == nil {
+		createdAt := i.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = time.Now()
+		}
 
-		// Name
-		item, err := txn.Get(k.Bytes())
+		cBytes := make([]byte, 8)
+		binary.BigEndian.PutUint64(cBytes, uint64(createdAt.UnixNano()))
+		err = txn.Set(d.key(dbKey{"item", i.CID, "created_at"}), cBytes)
 		if err != nil {
 			return err
 		}
-		n, err := item.ValueCopy(nil)
-
-		// Tags
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-		it := txn.NewIterator(opts)
-		defer it.Close()
 
-		pTag := dbKey{"item_tag", cid}
-		var tags []Tag
-		for it.Seek(pTag.Bytes()); it.ValidForPrefix(pTag.Bytes()); it.Next() {
-			item := it.Item()
-			kTag := newDbKeyFromStr(string(item.Key()))
-			tags = append(tags, NewTagFromStr(kTag[len(kTag)-1]))
+		err = txn.Set(d.key(recentKey(createdAt, i.CID)), []byte(i.CID))
+		if err != nil {
+			return err
 		}
+	}
 
-		i = &Item{CID: cid, Name: string(n), Tags: tags}
+	k = dbKey{"item", i.CID, "name"}
+	err = txn.Set(d.key(k), []byte(i.Name))
+	if err != nil {
+		return err
+	}
 
-		return nil
-	})
-	return i, err
-}
+	k = dbKey{"item", i.CID, "preview"}
+	if i.PreviewCID != "" {
+		err = txn.Set(d.key(k), []byte(i.PreviewCID))
+	} else {
+		err = txn.Delete(d.key(k))
+	}
+	if err != nil {
+		return err
+	}
 
-// DelItem deletes an item by its CID.
-func (d *Datastore) DelItem(cid string) error {
-	item, err := d.ReadItem(cid)
+	k = dbKey{"item", i.CID, "size"}
+	if i.Size > 0 {
+		sBytes := make([]byte, 8)
+		binary.BigEndian.PutUint64(sBytes, uint64(i.Size))
+		err = txn.Set(d.key(k), sBytes)
+	} else {
+		err = txn.Delete(d.key(k))
+	}
 	if err != nil {
 		return err
 	}
 
-	err = d.db.Update(func(txn *badger.Txn) error {
-		// Remove Tag-Item relationship
-		for _, t := range item.Tags {
-			tagKey := dbKey{"tag_item", t.String(), cid}.Bytes()
-			err := txn.Delete(tagKey)
-			if err != nil {
-				return err
-			}
-			// Reduce tag::[tagStr] count
-			err = d.updateTagItemCount(txn, t, -1)
-			if err != nil {
-				return err
-			}
+	err = d.setItemContentTypeInTxn(txn, i.CID, i.ContentType)
+	if err != nil {
+		return err
+	}
+
+	if iOld != nil {
+		// Delete old item_tag::[cid]::[tagStr]
+		k = dbKey{"item_tag", i.CID, ""}
+		err = d.dropPrefix(txn, k)
+		if err != nil {
+			return err
 		}
 
-		// Remove Items from all Collections
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-		it := txn.NewIterator(opts)
-		p := dbKey{"collection_item"}
-		for it.Seek(p.Bytes()); it.ValidForPrefix(p.Bytes()); it.Next() {
-			item := it.Item()
-			k := newDbKeyFromStr(string(item.Key()))
-			if k[2] == cid {
-				err := txn.Delete(k.Bytes())
-				if err != nil {
-					return err
-				}
+		// Delete old tag_item::[tagStr]::[cid]
+		for _, t := range iOld.Tags {
+			tagKey := d.key(dbKey{"tag_item", t.String(), i.CID})
+			err = txn.Delete(tagKey)
+			if err != nil {
+				return err
 			}
-		}
-		it.Close()
 
-		// Remove item from all folders
-		it = txn.NewIterator(opts)
-		p = dbKey{"folder_item"}
-		for it.Seek(p.Bytes()); it.ValidForPrefix(p.Bytes()); it.Next() {
-			item := it.Item()
-			k := newDbKeyFromStr(string(item.Key()))
-			if k[3] == cid {
-				err := txn.Delete(k.Bytes())
-				if err != nil {
-					return err
-				}
+			err = d.updateTagItemCount(txn, t, -1)
+			if err != nil {
+				return err
 			}
 		}
-		it.Close()
+	}
 
-		p = dbKey{"items", item.CID}
-		err = d.dropPrefix(txn, p)
+	// Set new tags
+	for _, t := range i.Tags {
+		err = d.addItemTagInTxn(txn, i.CID, t)
 		if err != nil {
 			return err
 		}
+	}
 
-		p = dbKey{"item", item.CID}
-		err = d.dropPrefix(txn, p)
-		if err != nil {
-			return err
-		}
+	return d.touchItemCollectionsInTxn(txn, i.CID)
+}
 
-		p = dbKey{"item_collection", item.CID}
-		err = d.dropPrefix(txn, p)
-		if err != nil {
-			return err
-		}
+// ReadItem reads Item from database
+// Items are cached if the cache is enabled in Options.
+func (d *Datastore) ReadItem(cid string) (*Item, error) {
+	key := itemCacheKey(cid)
+	if v, ok := d.cache.get(key); ok {
+		return copyItem(v.(*Item)), nil
+	}
+	gen := d.cache.generation()
 
-		p = dbKey{"item_tag", item.CID}
-		err = d.dropPrefix(txn, p)
+	var i *Item
+	err := d.view(func(txn *badger.Txn) error {
+		var err error
+		i, err = d.readItemInTxn(txn, cid)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d.cache.add(key, copyItem(i), gen)
+	return i, nil
+}
+
+// ReadItemName reads only the name of an item, without reading its tags. ErrCIDNotFound is returned if the item doesn't
+// exist.
+func (d *Datastore) ReadItemName(cid string) (string, error) {
+	if v, ok := d.cache.get(itemCacheKey(cid)); ok {
+		return v.(*Item).Name, nil
+	}
+
+	var name string
+	err := d.view(func(txn *badger.Txn) error {
+		// item::[cid]::name
+		item, err := txn.Get(d.key(dbKey{"item", cid, "name"}))
 		if err != nil {
+			if err == badger.ErrKeyNotFound {
+				return ErrCIDNotFound
+			}
 			return err
 		}
-
-		p = dbKey{"item_folder", item.CID}
-		err = d.dropPrefix(txn, p)
-		return err
+		return item.Value(func(val []byte) error {
+			name = string(val)
+			return nil
+		})
 	})
-	return err
+	return name, err
 }
 
-func (d *Datastore) addItemTagInTxn(txn *badger.Txn, cid string, t Tag) error {
-	if cid == "" || t.IsEmpty() {
-		panic("Invalid parameters.")
+func (d *Datastore) readItemInTxn(txn *badger.Txn, cid string) (*Item, error) {
+	err := d.checkCIDInTxn(txn, cid)
+	if err != nil {
+		return nil, err
 	}
 
-	tagExist := false
+	k := dbKey{"item", cid, "name"}
 
-	itemTagKey := dbKey{"item_tag", cid, t.String()}.Bytes()
-	// Check existence of the item tag
-	_, err := txn.Get(itemTagKey)
-	if err != badger.ErrKeyNotFound {
-		tagExist = true
+	// Name
+	item, err := txn.Get(d.key(k))
+	if err != nil {
+		return nil, err
 	}
-	err = txn.Set(itemTagKey, []byte(t.String()))
+	n, err := item.ValueCopy(nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	tagItemKey := dbKey{"tag_item", t.String(), cid}.Bytes()
-	_, err = txn.Get(tagItemKey)
-	if (err != badger.ErrKeyNotFound && tagExist == false) ||
-		(err == badger.ErrKeyNotFound && tagExist == true) {
-		panic("Database integrity error. Maybe you have duplicate tags for an item?")
+	// Preview
+	var preview []byte
+	item, err = txn.Get(d.key(dbKey{"item", cid, "preview"}))
+	if err != nil && err != badger.ErrKeyNotFound {
+		return nil, err
 	}
-	err = txn.Set(tagItemKey, []byte(cid))
-	if err != nil {
-		return err
+	if item != nil {
+		preview, err = item.ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	if tagExist == false {
+	// Size
+	var size int64
+	item, err = txn.Get(d.key(dbKey{"item", cid, "size"}))
+	if err != nil && err != badger.ErrKeyNotFound {
+		return nil, err
+	}
+	if item != nil {
+		err = item.Value(func(val []byte) error {
+			size = int64(binary.BigEndian.Uint64(val))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		tagsKey := dbKey{"tags", t.String()}.Bytes()
-		err = txn.Set(tagsKey, []byte(t.String()))
+	// Content type
+	var contentType []byte
+	item, err = txn.Get(d.key(dbKey{"item", cid, "content_type"}))
+	if err != nil && err != badger.ErrKeyNotFound {
+		return nil, err
+	}
+	if item != nil {
+		contentType, err = item.ValueCopy(nil)
 		if err != nil {
-			return err
+			return nil, err
 		}
+	}
 
-		err = d.updateTagItemCount(txn, t, 1)
+	// Created at
+	var createdAt time.Time
+	item, err = txn.Get(d.key(dbKey{"item", cid, "created_at"}))
+	if err != nil && err != badger.ErrKeyNotFound {
+		return nil, err
+	}
+	if item != nil {
+		err = item.Value(func(val []byte) error {
+			createdAt = time.Unix(0, int64(binary.BigEndian.Uint64(val)))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Tags
+	var tags []Tag
+	for _, tagStr := range d.readItemTagStrsInTxn(txn, cid) {
+		tags = append(tags, NewTagFromStr(tagStr))
+	}
+
+	return &Item{
+		CID:         cid,
+		Name:        string(n),
+		Tags:        tags,
+		PreviewCID:  string(preview),
+		Size:        size,
+		ContentType: string(contentType),
+		CreatedAt:   createdAt,
+	}, nil
+}
+
+// ReadItemTagStrings returns the tags of an item as strings, like Tag.String().
+func (d *Datastore) ReadItemTagStrings(cid string) ([]string, error) {
+	var tagStrs []string
+	err := d.view(func(txn *badger.Txn) error {
+		err := d.checkCIDInTxn(txn, cid)
 		if err != nil {
 			return err
 		}
+		tagStrs = d.readItemTagStrsInTxn(txn, cid)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return tagStrs, nil
 }
 
-// updateTagItemCount update count of a tag
-func (d *Datastore) updateTagItemCount(txn *badger.Txn, t Tag, diff int) error {
-	if t.IsEmpty() || diff == 0 {
-		panic("Invalid parameters.")
+// readItemTagStrsInTxn returns the tag strings of an item.
+func (d *Datastore) readItemTagStrsInTxn(txn *badger.Txn, cid string) []string {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	// item_tag::[cid]::[tagStr]
+	pTag := dbKey{"item_tag", cid, ""}
+	var tagStrs []string
+	for it.Seek(d.key(pTag)); it.ValidForPrefix(d.key(pTag)); it.Next() {
+		kTag := d.parseKey(it.Item().Key())
+		tagStrs = append(tagStrs, kTag[len(kTag)-1])
 	}
+	return tagStrs
+}
 
-	tagKey := dbKey{"tag", t.String(), "count"}.Bytes()
-	item, err := txn.Get(tagKey)
-	var c int
-	cBytes := make([]byte, 4)
-	if err != nil {
-		if err == badger.ErrKeyNotFound {
-			c = 1
-		} else {
+// SetItemContentType sets ContentType of an item. An empty ct clears it.
+func (d *Datastore) SetItemContentType(cid, ct string) error {
+	err := d.update(func(txn *badger.Txn) error {
+		err := d.checkCIDInTxn(txn, cid)
+		if err != nil {
 			return err
 		}
-	} else {
-		cBytes, err = item.ValueCopy(cBytes)
+
+		err = d.setItemContentTypeInTxn(txn, cid, ct)
 		if err != nil {
 			return err
 		}
+		return d.touchItemCollectionsInTxn(txn, cid)
+	})
+	d.cache.remove(itemCacheKey(cid))
+	return err
+}
 
-		c = int(binary.BigEndian.Uint32(cBytes)) + diff
-
-		if c < 0 {
-			return ErrNegativeTagItemCount
-		}
-	}
-	binary.BigEndian.PutUint32(cBytes, uint32(c))
-	err = txn.Set(tagKey, cBytes)
-	if err != nil {
+// setItemContentTypeInTxn sets item::[cid]::content_type and moves the item to the new type_item index.
+func (d *Datastore) setItemContentTypeInTxn(txn *badger.Txn, cid, ct string) error {
+	k := dbKey{"item", cid, "content_type"}
+	item, err := txn.Get(d.key(k))
+	if err != nil && err != badger.ErrKeyNotFound {
 		return err
 	}
-
-	// No item is referring this tag, delete it
-	if c == 0 {
-		p := dbKey{"tags", t.String()}
-		err = d.dropPrefix(txn, p)
+	if item != nil {
+		old, err := item.ValueCopy(nil)
 		if err != nil {
 			return err
 		}
-		p = dbKey{"tag", t.String()}
-		err = d.dropPrefix(txn, p)
+		err = txn.Delete(d.key(dbKey{"type_item", string(old), cid}))
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
-}
-
-// AddItemTag adds a Tag to an Item. If the tag doesn't exist in database, it will be created.
-func (d *Datastore) AddItemTag(cid string, t Tag) error {
-	if t.IsEmpty() || cid == "" {
-		panic("Invalid parameters.")
+	if ct == "" {
+		return txn.Delete(d.key(k))
 	}
 
-	err := d.checkCID(cid)
+	err = txn.Set(d.key(k), []byte(ct))
 	if err != nil {
 		return err
 	}
-
-	err = d.db.Update(func(txn *badger.Txn) error {
-		return d.addItemTagInTxn(txn, cid, t)
-	})
-	return err
+	// type_item::[contentType]::[cid]
+	return txn.Set(d.key(dbKey{"type_item", ct, cid}), []byte(cid))
 }
 
-// RemoveItemTag removes a Tag from an Item.
-func (d *Datastore) RemoveItemTag(cid string, t Tag) error {
-	if t.IsEmpty() || cid == "" {
-		panic("Invalid parameters.")
-	}
-
-	err := d.checkCID(cid)
-	if err != nil {
-		return err
-	}
-
-	err = d.db.Update(func(txn *badger.Txn) error {
-		itemTagKey := dbKey{"item_tag", cid, t.String()}.Bytes()
-		err := txn.Delete(itemTagKey)
-		if err != nil {
-			return err
-		}
+// recentKey returns the key of an item in the recent index.
+func recentKey(createdAt time.Time, cid string) dbKey {
+	return dbKey{"recent", fmt.Sprintf("%016x", uint64(createdAt.UnixNano())), cid}
+}
 
-		tagKey := dbKey{"tag_item", t.String(), cid}.Bytes()
-		err = txn.Delete(tagKey)
+// IncrementItemViews adds 1 to the view count of an item. Concurrent increments conflict on commit and are retried.
+func (d *Datastore) IncrementItemViews(cid string) error {
+	return d.update(func(txn *badger.Txn) error {
+		err := d.checkCIDInTxn(txn, cid)
 		if err != nil {
 			return err
 		}
 
-		// Reduce tag::[tagStr] count
-		err = d.updateTagItemCount(txn, t, -1)
+		views, err := d.readItemViewsInTxn(txn, cid)
 		if err != nil {
 			return err
 		}
-
-		return nil
+		vBytes := make([]byte, 8)
+		binary.BigEndian.PutUint64(vBytes, views+1)
+		return txn.Set(d.key(dbKey{"item", cid, "views"}), vBytes)
 	})
-	return err
 }
 
-// HasTag checks if an Item has a Tag.
-func (d *Datastore) HasTag(cid string, t Tag) (bool, error) {
-	if t.IsEmpty() || cid == "" {
+// ReadMostViewed returns at most limit viewed items, ordered by view count and then by CID.
+// Items never viewed are not included.
+func (d *Datastore) ReadMostViewed(limit int) ([]*Item, error) {
+	if limit <= 0 {
 		panic("Invalid parameters.")
 	}
 
-	item, err := d.ReadItem(cid)
-	if err != nil {
-		return false, err
-	}
-
-	exists := false
-	for _, tag := range item.Tags {
-		if tag.Equals(t) {
-			exists = true
-			break
+	views := make(map[string]uint64)
+	var cids []string
+	items := []*Item{}
+	err := d.view(func(txn *badger.Txn) error {
+		// items::[cid]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"items", ""}) {
+			v, err := d.readItemViewsInTxn(txn, k[1])
+			if err != nil {
+				return err
+			}
+			if v > 0 {
+				views[k[1]] = v
+				cids = append(cids, k[1])
+			}
 		}
-	}
 
-	return exists, nil
-}
-
-// AddItemToCollection adds an Item to a Collection.
-func (d *Datastore) AddItemToCollection(cid string, ipns string) error {
-	// Check if the item is already in the collection
-	exists, err := d.IsItemInCollection(cid, ipns)
-	if err != nil {
-		return err
-	}
-	if exists {
-		return ErrItemInCollection
-	}
-
-	err = d.db.Update(func(txn *badger.Txn) error {
-		kColl := dbKey{"collection_item", ipns, cid}
-		err := txn.Set(kColl.Bytes(), []byte(cid))
-		if err != nil {
-			return err
+		sort.Slice(cids, func(i, j int) bool {
+			if views[cids[i]] != views[cids[j]] {
+				return views[cids[i]] > views[cids[j]]
+			}
+			return cids[i] < cids[j]
+		})
+		if len(cids) > limit {
+			cids = cids[:limit]
 		}
 
-		kItem := dbKey{"item_collection", cid, ipns}
-		err = txn.Set(kItem.Bytes(), []byte(ipns))
-		if err != nil {
-			return err
+		for _, cid := range cids {
+			item, err := d.readItemInTxn(txn, cid)
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
 		}
-
 		return nil
 	})
-
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Add item to root folder
-	err = d.AddItemToFolder(cid, &Folder{IPNSAddress: ipns})
-	return err
+	return items, nil
 }
 
-// RemoveItemFromCollection removes an Item from a Collection.
-func (d *Datastore) RemoveItemFromCollection(cid string, ipns string) error {
-	err := d.checkCID(cid)
-	if err != nil {
-		return err
+// readItemViewsInTxn returns the view count of an item, 0 if it's never viewed.
+func (d *Datastore) readItemViewsInTxn(txn *badger.Txn, cid string) (uint64, error) {
+	item, err := txn.Get(d.key(dbKey{"item", cid, "views"}))
+	if err == badger.ErrKeyNotFound {
+		return 0, nil
 	}
-
-	err = d.checkIPNS(ipns)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	err = d.db.Update(func(txn *badger.Txn) error {
-		return d.removeItemFromCollectionInTxn(txn, cid, ipns)
+	var views uint64
+	err = item.Value(func(val []byte) error {
+		views = binary.BigEndian.Uint64(val)
+		return nil
 	})
-	return err
-
+	return views, err
 }
 
-func (d *Datastore) removeItemFromCollectionInTxn(txn *badger.Txn, cid string, ipns string) error {
-	// Remove item from folders of collection
-	var paths []string
-	p := dbKey{"item_folder", cid, ipns}
-	opts := badger.DefaultIteratorOptions
-	opts.PrefetchValues = false
-	it := txn.NewIterator(opts)
-
-	for it.Seek(p.Bytes()); it.ValidForPrefix(p.Bytes()); it.Next() {
-		item := it.Item()
-		keyStr := string(item.Key())
-		key := newDbKeyFromStr(keyStr)
+// ReadRecentItems returns items ordered by creation time, newest first. If limit > 0, at most limit items are returned.
+// Items created before creation times were recorded are not included.
+func (d *Datastore) ReadRecentItems(limit int) ([]*Item, error) {
+	items := []*Item{}
+	err := d.view(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		p := d.key(dbKey{"recent", ""})
+		// Seek to the last key with the prefix
+		for it.Seek(append(p, 0xff)); it.ValidForPrefix(p); it.Next() {
+			if limit > 0 && len(items) >= limit {
+				break
+			}
+
+			k := d.parseKey(it.Item().Key())
+			item, err := d.readItemInTxn(txn, k[2])
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// DelItem deletes an item by its CID.
+func (d *Datastore) DelItem(cid string) error {
+	defer d.cache.remove(itemCacheKey(cid))
+
+	return d.update(func(txn *badger.Txn) error {
+		item, err := d.readItemInTxn(txn, cid)
+		if err != nil {
+			return err
+		}
+
+		return d.delItemInTxn(txn, item)
+	})
+}
+
+// DelItems deletes many items in one transaction and returns the number of deleted items.
+// If some CIDs don't exist, the other items are still deleted and a *CIDsNotFoundError is returned.
+func (d *Datastore) DelItems(cids []string) (int, error) {
+	defer d.cache.remove(itemCacheKeys(cids)...)
+
+	var missing []string
+	var deleted map[string]bool
+	err := d.update(func(txn *badger.Txn) error {
+		missing = nil
+		deleted = make(map[string]bool)
+		for _, cid := range cids {
+			if deleted[cid] {
+				continue
+			}
+
+			item, err := d.readItemInTxn(txn, cid)
+			if err == ErrCIDNotFound {
+				missing = append(missing, cid)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			err = d.delItemInTxn(txn, item)
+			if err != nil {
+				return err
+			}
+			deleted[cid] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(missing) > 0 {
+		return len(deleted), &CIDsNotFoundError{CIDs: missing}
+	}
+	return len(deleted), nil
+}
+
+// delItemInTxn deletes an item and all its relationships with tags, collections and folders.
+// Collections and folders of the item are found by the reverse indexes, so no full index scan is needed.
+func (d *Datastore) delItemInTxn(txn *badger.Txn, item *Item) error {
+	err := d.touchItemCollectionsInTxn(txn, item.CID)
+	if err != nil {
+		return err
+	}
+
+	// Remove Tag-Item relationship
+	for _, t := range item.Tags {
+		tagKey := d.key(dbKey{"tag_item", t.String(), item.CID})
+		err := txn.Delete(tagKey)
+		if err != nil {
+			return err
+		}
+		// Reduce tag::[tagStr] count
+		err = d.updateTagItemCount(txn, t, -1)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Remove item from all collections
+	// item_collection::[cid]::[ipns] -> collection_item::[ipns]::[cid]
+	for _, k := range d.readKeysInTxn(txn, dbKey{"item_collection", item.CID, ""}) {
+		err := txn.Delete(d.key(dbKey{"collection_item", k[2], item.CID}))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Remove item from all folders
+	// item_folder::[cid]::[ipns]::[folderPath] -> folder_item::[ipns]::[folderPath]::[cid]
+	for _, k := range d.readKeysInTxn(txn, dbKey{"item_folder", item.CID, ""}) {
+		err := txn.Delete(d.key(dbKey{"folder_item", k[2], k[3], item.CID}))
+		if err != nil {
+			return err
+		}
+		err = d.removeFromPathListInTxn(txn, dbKey{"folder_order", k[2], k[3]}, item.CID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = txn.Delete(d.key(dbKey{"items", item.CID}))
+	if err != nil {
+		return err
+	}
+
+	if !item.CreatedAt.IsZero() {
+		err = txn.Delete(d.key(recentKey(item.CreatedAt, item.CID)))
+		if err != nil {
+			return err
+		}
+	}
+
+	if item.ContentType != "" {
+		err = txn.Delete(d.key(dbKey{"type_item", item.ContentType, item.CID}))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, p := range []string{"item", "item_collection", "item_tag", "item_folder"} {
+		err = d.dropPrefix(txn, dbKey{p, item.CID, ""})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Datastore) addItemTagInTxn(txn *badger.Txn, cid string, t Tag) error {
+	if cid == "" || t.IsEmpty() {
+		panic("Invalid parameters.")
+	}
+	t = d.normalizeTag(t)
+
+	tagExist := false
+
+	itemTagKey := d.key(dbKey{"item_tag", cid, t.String()})
+	// Check existence of the item tag
+	_, err := txn.Get(itemTagKey)
+	if err == nil {
+		tagExist = true
+	} else if err != badger.ErrKeyNotFound {
+		return err
+	}
+	err = txn.Set(itemTagKey, []byte(t.String()))
+	if err != nil {
+		return err
+	}
+
+	// Both index keys are written even if only one of them exists, which restores a half broken index.
+	// Concurrent transactions tagging the same item conflict on commit and are retried by update.
+	tagItemKey := d.key(dbKey{"tag_item", t.String(), cid})
+	err = txn.Set(tagItemKey, []byte(cid))
+	if err != nil {
+		return err
+	}
+
+	luBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(luBytes, uint64(time.Now().UnixNano()))
+	err = txn.Set(d.key(dbKey{"tag", t.String(), "last_used"}), luBytes)
+	if err != nil {
+		return err
+	}
+
+	if tagExist == false {
+
+		tagsKey := d.key(dbKey{"tags", t.String()})
+		err = txn.Set(tagsKey, []byte(t.String()))
+		if err != nil {
+			return err
+		}
+
+		err = d.updateTagItemCount(txn, t, 1)
+		if err != nil {
+			return err
+		}
+
+		err = d.touchItemCollectionsInTxn(txn, cid)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateTagItemCount update count of a tag
+func (d *Datastore) updateTagItemCount(txn *badger.Txn, t Tag, diff int) error {
+	if t.IsEmpty() || diff == 0 {
+		panic("Invalid parameters.")
+	}
+
+	tagKey := d.key(dbKey{"tag", t.String(), "count"})
+	item, err := txn.Get(tagKey)
+	var c int
+	cBytes := make([]byte, 4)
+	if err != nil {
+		if err != badger.ErrKeyNotFound {
+			return err
+		}
+		c = diff
+		if c < 0 {
+			return ErrNegativeTagItemCount
+		}
+	} else {
+		cBytes, err = item.ValueCopy(cBytes)
+		if err != nil {
+			return err
+		}
+
+		c = int(binary.BigEndian.Uint32(cBytes)) + diff
+
+		if c < 0 {
+			return ErrNegativeTagItemCount
+		}
+	}
+	binary.BigEndian.PutUint32(cBytes, uint32(c))
+	err = txn.Set(tagKey, cBytes)
+	if err != nil {
+		return err
+	}
+
+	// No item is referring this tag, delete it
+	if c == 0 {
+		err = txn.Delete(d.key(dbKey{"tags", t.String()}))
+		if err != nil {
+			return err
+		}
+		p := dbKey{"tag", t.String(), ""}
+		err = d.dropPrefix(txn, p)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddItemTag adds a Tag to an Item. If the tag doesn't exist in database, it will be created.
+func (d *Datastore) AddItemTag(cid string, t Tag) error {
+	if t.IsEmpty() || cid == "" {
+		panic("Invalid parameters.")
+	}
+
+	err := d.update(func(txn *badger.Txn) error {
+		err := d.checkCIDInTxn(txn, cid)
+		if err != nil {
+			return err
+		}
+		return d.addItemTagInTxn(txn, cid, t)
+	})
+	d.cache.remove(itemCacheKey(cid))
+	return err
+}
+
+// AddTagToItems adds a Tag to many Items in one transaction. Items already having the tag are skipped.
+// If some CIDs don't exist, the tag is still added to the other items and a *CIDsNotFoundError is returned.
+func (d *Datastore) AddTagToItems(cids []string, t Tag) error {
+	if t.IsEmpty() {
+		panic("Invalid parameters.")
+	}
+
+	var missing []string
+	err := d.update(func(txn *badger.Txn) error {
+		missing = nil
+		for _, cid := range cids {
+			err := d.checkCIDInTxn(txn, cid)
+			if err == ErrCIDNotFound {
+				missing = append(missing, cid)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			err = d.addItemTagInTxn(txn, cid, t)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	d.cache.remove(itemCacheKeys(cids)...)
+	if err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return &CIDsNotFoundError{CIDs: missing}
+	}
+	return nil
+}
+
+// RemoveItemTag removes a Tag from an Item. It's a no-op if the item doesn't have the tag.
+func (d *Datastore) RemoveItemTag(cid string, t Tag) error {
+	err := d.update(func(txn *badger.Txn) error {
+		return d.removeItemTagInTxn(txn, cid, t)
+	})
+	d.cache.remove(itemCacheKey(cid))
+	return err
+}
+
+// SetItemTags replaces the tags of an Item. Only tags which are added or removed are written, and other fields of the
+// item are untouched.
+func (d *Datastore) SetItemTags(cid string, tags []Tag) error {
+	want := make(map[string]Tag)
+	for _, t := range tags {
+		if t.IsEmpty() {
+			panic("Invalid parameters.")
+		}
+		t = d.normalizeTag(t)
+		want[t.String()] = t
+	}
+
+	err := d.update(func(txn *badger.Txn) error {
+		err := d.checkCIDInTxn(txn, cid)
+		if err != nil {
+			return err
+		}
+
+		current := make(map[string]bool)
+		for _, tagStr := range d.readItemTagStrsInTxn(txn, cid) {
+			current[tagStr] = true
+			if _, ok := want[tagStr]; ok {
+				continue
+			}
+			err = d.removeItemTagInTxn(txn, cid, NewTagFromStr(tagStr))
+			if err != nil {
+				return err
+			}
+		}
+
+		for tagStr, t := range want {
+			if current[tagStr] {
+				continue
+			}
+			err = d.addItemTagInTxn(txn, cid, t)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	d.cache.remove(itemCacheKey(cid))
+	return err
+}
+
+// CopyItemTags adds all tags of an Item to another Item. Tags the other item already has are skipped.
+func (d *Datastore) CopyItemTags(fromCID, toCID string) error {
+	err := d.update(func(txn *badger.Txn) error {
+		from, err := d.readItemInTxn(txn, fromCID)
+		if err != nil {
+			return err
+		}
+		err = d.checkCIDInTxn(txn, toCID)
+		if err != nil {
+			return err
+		}
+
+		for _, t := range from.Tags {
+			err = d.addItemTagInTxn(txn, toCID, t)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	d.cache.remove(itemCacheKey(toCID))
+	return err
+}
+
+// RemoveTagFromItems removes a Tag from many Items in one transaction. Items not having the tag are skipped.
+func (d *Datastore) RemoveTagFromItems(cids []string, t Tag) error {
+	if t.IsEmpty() {
+		panic("Invalid parameters.")
+	}
+
+	err := d.update(func(txn *badger.Txn) error {
+		for _, cid := range cids {
+			_, ok, err := d.readStoredItemTagInTxn(txn, cid, t)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+
+			err = d.removeItemTagInTxn(txn, cid, t)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	d.cache.remove(itemCacheKeys(cids)...)
+	return err
+}
+
+// DeleteTag removes a tag from all items and deletes the tag. It's a no-op if the tag doesn't exist.
+// A tag on too many items to delete in one transaction is first removed from the tag list, then its keys are deleted
+// in several transactions, without updating the updated_at of collections.
+func (d *Datastore) DeleteTag(t Tag) error {
+	if t.IsEmpty() {
+		panic("Invalid parameters.")
+	}
+	t = d.normalizeTag(t)
+
+	var cids []string
+	err := d.update(func(txn *badger.Txn) error {
+		cids = nil
+		for cid := range d.readTagItemsInTxn(txn, t) {
+			cids = append(cids, cid)
+		}
+		for _, cid := range cids {
+			for _, k := range []dbKey{{"item_tag", cid, t.String()}, {"tag_item", t.String(), cid}} {
+				err := txn.Delete(d.key(k))
+				if err != nil {
+					return err
+				}
+			}
+			err := d.touchItemCollectionsInTxn(txn, cid)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := txn.Delete(d.key(dbKey{"tags", t.String()}))
+		if err != nil {
+			return err
+		}
+		// tag::[tagStr]::count and tag::[tagStr]::last_used
+		return d.dropPrefix(txn, dbKey{"tag", t.String(), ""})
+	})
+	if err == badger.ErrTxnTooBig {
+		err = d.deleteTagChunked(t)
+	}
+	if err != nil {
+		return err
+	}
+
+	d.cache.remove(itemCacheKeys(cids)...)
+	return nil
+}
+
+// deleteTagChunked deletes a tag like DeleteTag, but not atomically. The tag is removed from the tag list first, so
+// it's gone even if deleting the rest of its keys fails.
+func (d *Datastore) deleteTagChunked(t Tag) error {
+	// Items losing the tag can't be tracked here
+	defer d.cache.purge()
+
+	err := d.update(func(txn *badger.Txn) error {
+		return txn.Delete(d.key(dbKey{"tags", t.String()}))
+	})
+	if err != nil {
+		return err
+	}
+
+	var keys []dbKey
+	err = d.view(func(txn *badger.Txn) error {
+		for cid := range d.readTagItemsInTxn(txn, t) {
+			keys = append(keys, dbKey{"item_tag", cid, t.String()}, dbKey{"tag_item", t.String(), cid})
+		}
+		keys = append(keys, d.readKeysInTxn(txn, dbKey{"tag", t.String(), ""})...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return d.deleteKeysChunked(keys)
+}
+
+func (d *Datastore) removeItemTagInTxn(txn *badger.Txn, cid string, t Tag) error {
+	if t.IsEmpty() || cid == "" {
+		panic("Invalid parameters.")
+	}
+	err := d.checkCIDInTxn(txn, cid)
+	if err != nil {
+		return err
+	}
+
+	t, ok, err := d.readStoredItemTagInTxn(txn, cid, t)
+	if err != nil || !ok {
+		return err
+	}
+
+	itemTagKey := d.key(dbKey{"item_tag", cid, t.String()})
+	err = txn.Delete(itemTagKey)
+	if err != nil {
+		return err
+	}
+
+	tagKey := d.key(dbKey{"tag_item", t.String(), cid})
+	err = txn.Delete(tagKey)
+	if err != nil {
+		return err
+	}
+
+	// Reduce tag::[tagStr] count
+	err = d.updateTagItemCount(txn, t, -1)
+	if err != nil {
+		return err
+	}
+
+	return d.touchItemCollectionsInTxn(txn, cid)
+}
+
+// readStoredItemTagInTxn returns the tag of an item as it's stored, which matches t. Tags stored before NormalizeTags
+// was enabled may not be normalized, so they are matched by their normalized form. false is returned if the item
+// doesn't have the tag.
+func (d *Datastore) readStoredItemTagInTxn(txn *badger.Txn, cid string, t Tag) (Tag, bool, error) {
+	t = d.normalizeTag(t)
+	_, err := txn.Get(d.key(dbKey{"item_tag", cid, t.String()}))
+	if err == nil {
+		return t, true, nil
+	}
+	if err != badger.ErrKeyNotFound {
+		return nil, false, err
+	}
+	if !d.normalizeTags {
+		return nil, false, nil
+	}
+
+	for _, tagStr := range d.readItemTagStrsInTxn(txn, cid) {
+		stored := NewTagFromStr(tagStr)
+		if stored.Normalized().Equals(t) {
+			return stored, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// HasTag checks if an Item has a Tag.
+func (d *Datastore) HasTag(cid string, t Tag) (bool, error) {
+	if t.IsEmpty() || cid == "" {
+		panic("Invalid parameters.")
+	}
+
+	item, err := d.ReadItem(cid)
+	if err != nil {
+		return false, err
+	}
+
+	t = d.normalizeTag(t)
+	exists := false
+	for _, tag := range item.Tags {
+		if tag.Equals(t) {
+			exists = true
+			break
+		}
+	}
+
+	return exists, nil
+}
+
+// AddItemToCollection adds an Item to a Collection.
+func (d *Datastore) AddItemToCollection(cid string, ipns string) error {
+	err := d.update(func(txn *badger.Txn) error {
+		return d.addItemToCollectionInTxn(txn, cid, ipns, true)
+	})
+	return err
+}
+
+// AddItemToCollectionOpts adds an Item to a Collection. If addToRoot is false, the item isn't added to the root folder.
+func (d *Datastore) AddItemToCollectionOpts(cid, ipns string, addToRoot bool) error {
+	err := d.update(func(txn *badger.Txn) error {
+		return d.addItemToCollectionInTxn(txn, cid, ipns, addToRoot)
+	})
+	return err
+}
+
+func (d *Datastore) addItemToCollectionInTxn(txn *badger.Txn, cid string, ipns string, addToRoot bool) error {
+	// Check if the item is already in the collection
+	exists, err := d.isItemInCollectionInTxn(txn, cid, ipns)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrItemInCollection
+	}
+
+	kColl := dbKey{"collection_item", ipns, cid}
+	err = txn.Set(d.key(kColl), []byte(cid))
+	if err != nil {
+		return err
+	}
+
+	kItem := dbKey{"item_collection", cid, ipns}
+	err = txn.Set(d.key(kItem), []byte(ipns))
+	if err != nil {
+		return err
+	}
+
+	err = d.touchCollectionInTxn(txn, ipns)
+	if err != nil {
+		return err
+	}
+
+	if !addToRoot {
+		return nil
+	}
+
+	// Add item to root folder
+	return d.addItemToFolderInTxn(txn, cid, &Folder{IPNSAddress: ipns})
+}
+
+// RemoveItemFromCollection removes an Item from a Collection.
+func (d *Datastore) RemoveItemFromCollection(cid string, ipns string) error {
+	err := d.update(func(txn *badger.Txn) error {
+		err := d.checkCIDInTxn(txn, cid)
+		if err != nil {
+			return err
+		}
+
+		err = d.checkIPNSInTxn(txn, ipns)
+		if err != nil {
+			return err
+		}
+
+		return d.removeItemFromCollectionInTxn(txn, cid, ipns)
+	})
+	return err
+
+}
+
+func (d *Datastore) removeItemFromCollectionInTxn(txn *badger.Txn, cid string, ipns string) error {
+	// Remove item from folders of collection
+	var paths []string
+	p := dbKey{"item_folder", cid, ipns, ""}
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+
+	for it.Seek(d.key(p)); it.ValidForPrefix(d.key(p)); it.Next() {
+		item := it.Item()
+		key := d.parseKey(item.Key())
+
+		paths = append(paths, key[3])
+	}
+	it.Close()
+
+	// drop item_folder::[cid]::[ipns]::[folderPath] = [folderPath]
+	err := d.dropPrefix(txn, p)
+	if err != nil {
+		return err
+	}
+
+	var k dbKey
+
+	// folder_item::[ipns]::[folderPath]::[cid] = [cid]
+	for _, v := range paths {
+		k = dbKey{"folder_item", ipns, v, cid}
+		err = txn.Delete(d.key(k))
+		if err != nil {
+			return err
+		}
+		err = d.removeFromPathListInTxn(txn, dbKey{"folder_order", ipns, v}, cid)
+		if err != nil {
+			return err
+		}
+	}
+
+	k = dbKey{"collection_item", ipns, cid}
+	err = txn.Delete(d.key(k))
+	if err != nil {
+		return err
+	}
+
+	k = dbKey{"item_collection", cid, ipns}
+	err = txn.Delete(d.key(k))
+	if err != nil {
+		return err
+	}
+
+	return d.touchCollectionInTxn(txn, ipns)
+}
+
+// IsItemInCollection checks if an Item belongs to a Collection.
+func (d *Datastore) IsItemInCollection(cid string, ipns string) (bool, error) {
+	var exist bool
+	err := d.view(func(txn *badger.Txn) error {
+		var err error
+		exist, err = d.isItemInCollectionInTxn(txn, cid, ipns)
+		return err
+	})
+
+	return exist, err
+}
+
+func (d *Datastore) isItemInCollectionInTxn(txn *badger.Txn, cid string, ipns string) (bool, error) {
+	err := d.checkCIDInTxn(txn, cid)
+	if err != nil {
+		return false, err
+	}
+
+	err = d.checkIPNSInTxn(txn, ipns)
+	if err != nil {
+		return false, err
+	}
+
+	kColl := dbKey{"item_collection", cid, ipns}
+	_, err = txn.Get(d.key(kColl))
+	if err == nil {
+		return true, nil
+	} else if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	return false, err
+}
+
+// SearchTags searches all available tags with prefix
+func (d *Datastore) SearchTags(prefix string) ([]Tag, error) {
+	if prefix == "" {
+		panic("Invalid prefix.")
+	}
+	prefix = d.normalizeTag(NewTagFromStr(prefix)).String()
+
+	keys := make(map[string]bool)
+
+	err := d.view(func(txn *badger.Txn) error {
+		p := dbKey{"tags", prefix}
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(d.key(p)); it.ValidForPrefix(d.key(p)); it.Next() {
+			item := it.Item()
+			key := d.parseKey(item.Key())
+
+			keys[key[1]] = true
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []Tag
+	for k := range keys {
+		tags = append(tags, NewTagFromStr(k))
+	}
+
+	return tags, nil
+}
+
+// SearchTagsRecent searches tags with prefix like SearchTags, ordered by the last time they are added to an item.
+// Tags without a last used time come last in alphabetical order. If limit > 0, at most limit tags are returned.
+func (d *Datastore) SearchTagsRecent(prefix string, limit int) ([]Tag, error) {
+	if prefix == "" {
+		panic("Invalid prefix.")
+	}
+	prefix = d.normalizeTag(NewTagFromStr(prefix)).String()
+
+	var tagStrs []string
+	lastUsed := make(map[string]uint64)
+	err := d.view(func(txn *badger.Txn) error {
+		for _, k := range d.readKeysInTxn(txn, dbKey{"tags", prefix}) {
+			tagStr := k[1]
+			tagStrs = append(tagStrs, tagStr)
+
+			item, err := txn.Get(d.key(dbKey{"tag", tagStr, "last_used"}))
+			if err == badger.ErrKeyNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			err = item.Value(func(val []byte) error {
+				lastUsed[tagStr] = binary.BigEndian.Uint64(val)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tagStrs, func(i, j int) bool {
+		a, b := tagStrs[i], tagStrs[j]
+		if lastUsed[a] != lastUsed[b] {
+			return lastUsed[a] > lastUsed[b]
+		}
+		return a < b
+	})
+	if limit > 0 && len(tagStrs) > limit {
+		tagStrs = tagStrs[:limit]
+	}
+
+	tags := make([]Tag, len(tagStrs))
+	for i, tagStr := range tagStrs {
+		tags[i] = NewTagFromStr(tagStr)
+	}
+	return tags, nil
+}
+
+// TagCountsByPrefix returns item counts of all tags with prefix, keyed by tagStr. An empty prefix returns all tags.
+func (d *Datastore) TagCountsByPrefix(prefix string) (map[string]uint, error) {
+	if prefix != "" {
+		prefix = d.normalizeTag(NewTagFromStr(prefix)).String()
+	}
+
+	counts := make(map[string]uint)
+	err := d.view(func(txn *badger.Txn) error {
+		// tags::[tagStr]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"tags", prefix}) {
+			c, err := d.readTagItemCountInTxn(txn, NewTagFromStr(k[1]))
+			if err != nil {
+				return err
+			}
+			counts[k[1]] = c
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// ReadTagItemCount returns []uint that are item counts of []Tag
+func (d *Datastore) ReadTagItemCount(tags []Tag) ([]uint, error) {
+	if len(tags) == 0 {
+		panic("Invalid tags.")
+	}
+
+	var counts []uint
+
+	err := d.view(func(txn *badger.Txn) error {
+		for _, t := range tags {
+			if t.IsEmpty() {
+				panic("Invalid tag.")
+			}
+
+			c, err := d.readTagItemCountInTxn(txn, t)
+			if err != nil {
+				return err
+			}
+			counts = append(counts, c)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// TagCounts returns item counts of tags, keyed by tag string. Empty tags are skipped and unknown tags count 0.
+func (d *Datastore) TagCounts(tags []Tag) (map[string]uint, error) {
+	counts := make(map[string]uint)
+	err := d.view(func(txn *badger.Txn) error {
+		for _, t := range tags {
+			if t.IsEmpty() {
+				continue
+			}
+
+			c, err := d.readTagItemCountInTxn(txn, t)
+			if err != nil {
+				return err
+			}
+			counts[t.String()] = c
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// readTagItemCountInTxn reads tag::[tagStr]::count. If a tag is not found in db, it counts 0.
+func (d *Datastore) readTagItemCountInTxn(txn *badger.Txn, t Tag) (uint, error) {
+	k := dbKey{"tag", d.normalizeTag(t).String(), "count"}
+	item, err := txn.Get(d.key(k))
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var c uint
+	err = item.Value(func(val []byte) error {
+		c = uint(binary.BigEndian.Uint32(val))
+		return nil
+	})
+	return c, err
+}
+
+// CreateOrUpdateFolder creates a new folder or updates a folder.
+// The path is normalized by NormalizeFolderPath, like paths given to other folder methods. folder itself isn't changed.
+func (d *Datastore) CreateOrUpdateFolder(folder *Folder) error {
+	if folder.IPNSAddress == "" {
+		panic("Invalid folder.")
+	}
+
+	err := d.update(func(txn *badger.Txn) error {
+		err := d.checkIPNSInTxn(txn, folder.IPNSAddress)
+		if err != nil {
+			return err
+		}
+		return d.createOrUpdateFolderInTxn(txn, folder)
+	})
+
+	return err
+}
+
+// CreateFolderAll creates a folder along with all its missing ancestors, like "mkdir -p".
+// Existing folders along the path are left untouched.
+func (d *Datastore) CreateFolderAll(ipns, path string) error {
+	path, err := NormalizeFolderPath(path)
+	if err != nil {
+		return err
+	}
+
+	err = d.update(func(txn *badger.Txn) error {
+		err := d.checkIPNSInTxn(txn, ipns)
+		if err != nil {
+			return err
+		}
+
+		parts := strings.Split(path, "/")
+		for i := 1; i <= len(parts); i++ {
+			folder := &Folder{IPNSAddress: ipns, Path: strings.Join(parts[:i], "/")}
+			exists, err := d.isFolderPathExistsInTxn(txn, ipns, folder.Path)
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+
+			err = d.createOrUpdateFolderInTxn(txn, folder)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return err
+}
+
+func (d *Datastore) createOrUpdateFolderInTxn(txn *badger.Txn, folder *Folder) error {
+	folder, err := normalizeFolder(folder)
+	if err != nil {
+		return err
+	}
+
+	k := dbKey{"folders", folder.IPNSAddress, folder.Path}
+	err = txn.Set(d.key(k), []byte(folder.Path))
+	if err != nil {
+		return err
+	}
+
+	err = d.touchCollectionInTxn(txn, folder.IPNSAddress)
+	if err != nil {
+		return err
+	}
+
+	parentPath := folder.ParentPath()
+	isRootFolder := folder.Path == ""
+
+	if !isRootFolder {
+		// Make sure parent exists. For a top-level folder the parent is root, which is created if missing.
+		err = d.assertParentInTxn(txn, folder)
+		if err != nil {
+			return err
+		}
+
+		// Add this folder to parent's children list
+		// Parent's Children key: folder::[ipns]::[folderPath]::children
+		pck := dbKey{"folder", folder.IPNSAddress, parentPath, "children"}
+		item, err := txn.Get(d.key(pck))
+		var children []string
+		if err != nil && err != badger.ErrKeyNotFound {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if item != nil {
+			v, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			// Read children
+			buf = *bytes.NewBuffer(v)
+			dec := gob.NewDecoder(&buf)
+			err = dec.Decode(&children)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Updating an existing folder leaves the children list as is
+		for _, child := range children {
+			if child == folder.Path {
+				return nil
+			}
+		}
+
+		// Add folder to children
+		children = append(children, folder.Path)
+
+		// Save back
+		buf.Reset()
+		enc := gob.NewEncoder(&buf)
+		err = enc.Encode(children)
+		if err != nil {
+			return err
+		}
+
+		err = txn.Set(d.key(pck), buf.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReadFolder reads a folder from Datastore. ItemCount and ChildCount of the folder are populated.
+func (d *Datastore) ReadFolder(ipns, path string) (*Folder, error) {
+	if ipns == "" {
+		panic("Invalid parameters.")
+	}
+
+	path, err := NormalizeFolderPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// path can be "" as a root folder
+
+	var folder *Folder
+	err = d.view(func(txn *badger.Txn) error {
+		exists, err := d.isFolderPathExistsInTxn(txn, ipns, path)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrFolderNotExists
+		}
+
+		folder, err = d.readFolderInTxn(txn, ipns, path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return folder, nil
+}
+
+// readFolderInTxn reads an existing folder with ItemCount and ChildCount populated.
+func (d *Datastore) readFolderInTxn(txn *badger.Txn, ipns, path string) (*Folder, error) {
+	folder := &Folder{Path: path, IPNSAddress: ipns}
+
+	// folder_item::[ipns]::[folderPath]::[cid]
+	folder.ItemCount = d.countKeysInTxn(txn, dbKey{"folder_item", ipns, path, ""})
+
+	children, err := d.readPathListInTxn(txn, dbKey{"folder", ipns, path, "children"})
+	if err != nil {
+		return nil, err
+	}
+	folder.ChildCount = len(children)
+
+	return folder, nil
+}
+
+// IsFolderPathExists checkes if a folder exists.
+func (d *Datastore) IsFolderPathExists(ipns, path string) (bool, error) {
+
+	path, err := NormalizeFolderPath(path)
+	if err != nil {
+		return false, err
+	}
+
+	exists := false
+
+	err = d.view(func(txn *badger.Txn) error {
+		var err error
+		exists, err = d.isFolderPathExistsInTxn(txn, ipns, path)
+		return err
+	})
+
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+func (d *Datastore) isFolderPathExistsInTxn(txn *badger.Txn, ipns, path string) (bool, error) {
+
+	err := d.checkIPNSInTxn(txn, ipns)
+	if err != nil {
+		return false, err
+	}
+
+	exists := false
+
+	k := dbKey{"folders", ipns, path}
+
+	_, err = txn.Get(d.key(k))
+	if err != nil {
+		if err != badger.ErrKeyNotFound {
+			return false, err
+		}
+	} else {
+		exists = true
+	}
+
+	return exists, nil
+}
+
+// assertParentInTxn checks if parent of a non-root folder exists. If not, an error will be returned.
+// If parent is root, it will create the root folder.
+func (d *Datastore) assertParentInTxn(txn *badger.Txn, folder *Folder) error {
+	if folder.Path == "" {
+		panic("Root folder has no parent.")
+	}
+
+	parentPath := folder.ParentPath()
+	exists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, parentPath)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	if parentPath != "" {
+		return ErrParentFolderNotExists
+	}
+
+	// Create root folder if not exists in Datastore
+	root := &Folder{IPNSAddress: folder.IPNSAddress}
+	return d.createOrUpdateFolderInTxn(txn, root)
+}
+
+// AddItemToFolder adds an item to a folder. The item is added to the collection of the folder if it's not in it yet,
+// without being added to the root folder.
+func (d *Datastore) AddItemToFolder(cid string, folder *Folder) error {
+	folder, err := normalizeFolder(folder)
+	if err != nil {
+		return err
+	}
+
+	err = d.update(func(txn *badger.Txn) error {
+		return d.addItemToFolderInCollectionInTxn(txn, cid, folder)
+	})
+
+	return err
+}
+
+// addItemToFolderInCollectionInTxn adds an item to a folder and makes sure the item is in the collection of the folder.
+func (d *Datastore) addItemToFolderInCollectionInTxn(txn *badger.Txn, cid string, folder *Folder) error {
+	err := d.addItemToFolderInTxn(txn, cid, folder)
+	if err != nil {
+		return err
+	}
+	return d.ensureItemInCollectionInTxn(txn, cid, folder.IPNSAddress)
+}
+
+func (d *Datastore) addItemToFolderInTxn(txn *badger.Txn, cid string, folder *Folder) error {
+	err := d.checkCIDInTxn(txn, cid)
+	if err != nil {
+		return err
+	}
+
+	exists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, folder.Path)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrFolderNotExists
+	}
+
+	// item_folder::[cid]::[ipns]::[folderPath] = [folderPath]
+	k := dbKey{"item_folder", cid, folder.IPNSAddress, folder.Path}
+	err = txn.Set(d.key(k), []byte(folder.Path))
+	if err != nil {
+		return err
+	}
+
+	// folder_item::[ipns]::[folderPath]::[cid] = [cid]
+	k = dbKey{"folder_item", folder.IPNSAddress, folder.Path, cid}
+	err = txn.Set(d.key(k), []byte(cid))
+	if err != nil {
+		return err
+	}
+
+	return d.touchCollectionInTxn(txn, folder.IPNSAddress)
+}
+
+// RemoveItemFromFolder removes item from a folder
+func (d *Datastore) RemoveItemFromFolder(cid string, folder *Folder) error {
+	folder, err := normalizeFolder(folder)
+	if err != nil {
+		return err
+	}
+
+	err = d.update(func(txn *badger.Txn) error {
+		return d.removeItemFromFolderInTxn(txn, cid, folder)
+	})
+
+	return err
+}
+
+func (d *Datastore) removeItemFromFolderInTxn(txn *badger.Txn, cid string, folder *Folder) error {
+	err := d.checkCIDInTxn(txn, cid)
+	if err != nil {
+		return err
+	}
+
+	// item_folder::[cid]::[ipns]::[folderPath] = [folderPath]
+	k := dbKey{"item_folder", cid, folder.IPNSAddress, folder.Path}
+	_, err = txn.Get(d.key(k))
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return ErrItemNotInFolder
+		}
+		return err
+	}
+
+	err = txn.Delete(d.key(k))
+	if err != nil {
+		return err
+	}
+
+	// folder_item::[ipns]::[folderPath]::[cid] = [cid]
+	k = dbKey{"folder_item", folder.IPNSAddress, folder.Path, cid}
+	err = txn.Delete(d.key(k))
+	if err != nil {
+		return err
+	}
+
+	err = d.removeFromPathListInTxn(txn, dbKey{"folder_order", folder.IPNSAddress, folder.Path}, cid)
+	if err != nil {
+		return err
+	}
+
+	return d.touchCollectionInTxn(txn, folder.IPNSAddress)
+}
+
+// IsItemInFolder checks if an item is in a folder
+func (d *Datastore) IsItemInFolder(cid string, folder *Folder) (bool, error) {
+	folder, err := normalizeFolder(folder)
+	if err != nil {
+		return false, err
+	}
+
+	var inFolder bool
+	err = d.view(func(txn *badger.Txn) error {
+		var err error
+		inFolder, err = d.isItemInFolderInTxn(txn, cid, folder)
+		return err
+	})
+
+	return inFolder, err
+}
+
+func (d *Datastore) isItemInFolderInTxn(txn *badger.Txn, cid string, folder *Folder) (bool, error) {
+	err := d.checkCIDInTxn(txn, cid)
+	if err != nil {
+		return false, err
+	}
+
+	exists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, folder.Path)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, ErrFolderNotExists
+	}
+
+	var inFolder bool
+	k := dbKey{"item_folder", cid, folder.IPNSAddress, folder.Path}
+	_, err = txn.Get(d.key(k))
+
+	if err == nil {
+		inFolder = true
+	} else if err == badger.ErrKeyNotFound {
+		err = nil
+	}
+
+	return inFolder, err
+}
+
+// ReadFolderItems returns all items' CID in a folder
+func (d *Datastore) ReadFolderItems(folder *Folder) ([]string, error) {
+	folder, err := normalizeFolder(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := d.IsFolderPathExists(folder.IPNSAddress, folder.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrFolderNotExists
+	}
 
-		paths = append(paths, key[3])
+	var items []string
+	err = d.view(func(txn *badger.Txn) error {
+		var err error
+		items, err = d.readFolderItemsInTxn(txn, folder)
+		return err
+	})
+
+	return items, err
+}
+
+// readFolderItemsInTxn reads CIDs of items in a folder in the order set by SetFolderItemOrder.
+func (d *Datastore) readFolderItemsInTxn(txn *badger.Txn, folder *Folder) ([]string, error) {
+	var items []string
+	// folder_item::[ipns]::[folderPath]::[cid]
+	for _, k := range d.readKeysInTxn(txn, dbKey{"folder_item", folder.IPNSAddress, folder.Path, ""}) {
+		items = append(items, k[3])
 	}
-	it.Close()
 
-	// drop item_folder::[cid]::[ipns]::[folderPath] = [folderPath]
-	err := d.dropPrefix(txn, p)
+	order, err := d.readPathListInTxn(txn, dbKey{"folder_order", folder.IPNSAddress, folder.Path})
+	if err != nil {
+		return nil, err
+	}
+	return applyItemOrder(items, order), nil
+}
+
+// applyItemOrder sorts cids by order. CIDs not in order keep their order after the ordered ones, and CIDs of order
+// not in cids are ignored.
+func applyItemOrder(cids, order []string) []string {
+	if len(order) == 0 {
+		return cids
+	}
+
+	in := make(map[string]bool)
+	for _, cid := range cids {
+		in[cid] = true
+	}
+
+	sorted := make([]string, 0, len(cids))
+	ordered := make(map[string]bool)
+	for _, cid := range order {
+		if in[cid] && !ordered[cid] {
+			sorted = append(sorted, cid)
+			ordered[cid] = true
+		}
+	}
+	for _, cid := range cids {
+		if !ordered[cid] {
+			sorted = append(sorted, cid)
+		}
+	}
+	return sorted
+}
+
+// SetFolderItemOrder sets the order ReadFolderItems returns items of a folder in. Items not in cids come after them in
+// CID order. An empty cids clears the order.
+// ErrItemNotInFolder is returned if an item isn't in the folder, and ErrInvalidOrder if a CID is listed twice.
+func (d *Datastore) SetFolderItemOrder(folder *Folder, cids []string) error {
+	folder, err := normalizeFolder(folder)
 	if err != nil {
 		return err
 	}
 
-	var k dbKey
+	return d.update(func(txn *badger.Txn) error {
+		exists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, folder.Path)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrFolderNotExists
+		}
 
-	// folder_item::[ipns]::[folderPath]::[cid] = [cid]
-	for _, v := range paths {
-		k = dbKey{"folder_item", ipns, v, cid}
-		err = txn.Delete(k.Bytes())
+		seen := make(map[string]bool)
+		for _, cid := range cids {
+			if seen[cid] {
+				return ErrInvalidOrder
+			}
+			seen[cid] = true
+
+			in, err := d.isItemInFolderInTxn(txn, cid, folder)
+			if err != nil {
+				return err
+			}
+			if !in {
+				return ErrItemNotInFolder
+			}
+		}
+
+		// folder_order::[ipns]::[folderPath]
+		k := dbKey{"folder_order", folder.IPNSAddress, folder.Path}
+		if len(cids) == 0 {
+			err = txn.Delete(d.key(k))
+		} else {
+			err = d.writePathListInTxn(txn, k, cids)
+		}
 		if err != nil {
 			return err
 		}
-	}
+		return d.touchCollectionInTxn(txn, folder.IPNSAddress)
+	})
+}
 
-	k = dbKey{"collection_item", ipns, cid}
-	err = txn.Delete(k.Bytes())
+// MoveItemToTop moves an item to the front of the order ReadFolderItems returns items of a folder in.
+// ErrItemNotInFolder is returned if the item isn't in the folder. See SetFolderItemOrder.
+func (d *Datastore) MoveItemToTop(folder *Folder, cid string) error {
+	return d.moveItemInFolderOrder(folder, cid, true)
+}
+
+// MoveItemToBottom moves an item to the back of the order ReadFolderItems returns items of a folder in.
+// Items which weren't in the manual order are added to it, so they stay before the item.
+// ErrItemNotInFolder is returned if the item isn't in the folder. See SetFolderItemOrder.
+func (d *Datastore) MoveItemToBottom(folder *Folder, cid string) error {
+	return d.moveItemInFolderOrder(folder, cid, false)
+}
+
+// moveItemInFolderOrder moves an item to the front of the folder's order if top is true, and to the back otherwise.
+func (d *Datastore) moveItemInFolderOrder(folder *Folder, cid string, top bool) error {
+	folder, err := normalizeFolder(folder)
 	if err != nil {
 		return err
 	}
 
-	k = dbKey{"item_collection", cid, ipns}
-	err = txn.Delete(k.Bytes())
+	return d.update(func(txn *badger.Txn) error {
+		exists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, folder.Path)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrFolderNotExists
+		}
+		in, err := d.isItemInFolderInTxn(txn, cid, folder)
+		if err != nil {
+			return err
+		}
+		if !in {
+			return ErrItemNotInFolder
+		}
+
+		items, err := d.readFolderItemsInTxn(txn, folder)
+		if err != nil {
+			return err
+		}
+		var cids []string
+		for _, c := range items {
+			if c != cid {
+				cids = append(cids, c)
+			}
+		}
+		if top {
+			cids = append([]string{cid}, cids...)
+		} else {
+			cids = append(cids, cid)
+		}
+
+		// folder_order::[ipns]::[folderPath]
+		err = d.writePathListInTxn(txn, dbKey{"folder_order", folder.IPNSAddress, folder.Path}, cids)
+		if err != nil {
+			return err
+		}
+		return d.touchCollectionInTxn(txn, folder.IPNSAddress)
+	})
+}
+
+// SwapFolderItems swaps the positions of two items in the order ReadFolderItems returns items of a folder in.
+// Items which weren't in the manual order are added to it, so they keep their positions.
+// ErrItemNotInFolder is returned if either item isn't in the folder. See SetFolderItemOrder.
+func (d *Datastore) SwapFolderItems(folder *Folder, cidA, cidB string) error {
+	folder, err := normalizeFolder(folder)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return d.update(func(txn *badger.Txn) error {
+		exists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, folder.Path)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrFolderNotExists
+		}
+
+		cids, err := d.readFolderItemsInTxn(txn, folder)
+		if err != nil {
+			return err
+		}
+		a, b := -1, -1
+		for i, cid := range cids {
+			switch cid {
+			case cidA:
+				a = i
+			case cidB:
+				b = i
+			}
+		}
+		if a == -1 || (b == -1 && cidA != cidB) {
+			return ErrItemNotInFolder
+		}
+		if cidA == cidB {
+			return nil
+		}
+		cids[a], cids[b] = cids[b], cids[a]
+
+		// folder_order::[ipns]::[folderPath]
+		err = d.writePathListInTxn(txn, dbKey{"folder_order", folder.IPNSAddress, folder.Path}, cids)
+		if err != nil {
+			return err
+		}
+		return d.touchCollectionInTxn(txn, folder.IPNSAddress)
+	})
 }
 
-// IsItemInCollection checks if an Item belongs to a Collection.
-func (d *Datastore) IsItemInCollection(cid string, ipns string) (bool, error) {
-	err := d.checkCID(cid)
+// CountFolderItems returns the number of items in a folder without reading their CIDs.
+func (d *Datastore) CountFolderItems(folder *Folder) (int, error) {
+	folder, err := normalizeFolder(folder)
 	if err != nil {
-		return false, err
+		return 0, err
 	}
 
-	err = d.checkIPNS(ipns)
+	var n int
+	err = d.view(func(txn *badger.Txn) error {
+		exists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, folder.Path)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrFolderNotExists
+		}
+
+		// folder_item::[ipns]::[folderPath]::[cid]
+		n = d.countKeysInTxn(txn, dbKey{"folder_item", folder.IPNSAddress, folder.Path, ""})
+		return nil
+	})
+	return n, err
+}
+
+// ReadCollectionItems returns all items' CID in a collection
+func (d *Datastore) ReadCollectionItems(ipns string) ([]string, error) {
+	err := d.checkIPNS(ipns)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	var exist bool
-	err = d.db.View(func(txn *badger.Txn) error {
-		kColl := dbKey{"item_collection", cid, ipns}
-		_, err := txn.Get(kColl.Bytes())
+	var items []string
+	err = d.view(func(txn *badger.Txn) error {
+		p := dbKey{"collection_item", ipns, ""}
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
 
-		if err == nil {
-			exist = true
-		} else if err == badger.ErrKeyNotFound {
-			err = nil
+		for it.Seek(d.key(p)); it.ValidForPrefix(d.key(p)); it.Next() {
+			item := it.Item()
+			key := d.parseKey(item.Key())
+
+			items = append(items, key[2])
 		}
-		return err
+
+		return nil
 	})
 
-	return exist, err
+	return items, err
 }
 
-// SearchTags searches all available tags with prefix
-func (d *Datastore) SearchTags(prefix string) ([]Tag, error) {
-	if prefix == "" {
-		panic("Invalid prefix.")
+// ReadCollectionStats returns the item count, the total item size and the tag histogram of a collection.
+func (d *Datastore) ReadCollectionStats(ipns string) (*CollectionStats, error) {
+	stats := &CollectionStats{TagCounts: make(map[string]uint)}
+	err := d.view(func(txn *badger.Txn) error {
+		err := d.checkIPNSInTxn(txn, ipns)
+		if err != nil {
+			return err
+		}
+
+		// collection_item::[ipns]::[cid]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"collection_item", ipns, ""}) {
+			item, err := d.readItemInTxn(txn, k[2])
+			if err != nil {
+				return err
+			}
+
+			stats.ItemCount++
+			stats.TotalSize += item.Size
+			for _, t := range item.Tags {
+				stats.TagCounts[t.String()]++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	keys := make(map[string]bool)
+	return stats, nil
+}
+
+// ReadCollectionItemsPaged returns at most limit CIDs of items in a collection after afterCID, sorted by CID,
+// and the cursor of the next page. An empty afterCID starts from the beginning. The cursor is "" after the last page.
+func (d *Datastore) ReadCollectionItemsPaged(ipns, afterCID string, limit int) ([]string, string, error) {
+	if limit <= 0 {
+		panic("Invalid parameters.")
+	}
+
+	cids := []string{}
+	next := ""
+	err := d.view(func(txn *badger.Txn) error {
+		err := d.checkIPNSInTxn(txn, ipns)
+		if err != nil {
+			return err
+		}
 
-	err := d.db.View(func(txn *badger.Txn) error {
-		p := dbKey{"tags", prefix}
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
 		it := txn.NewIterator(opts)
 		defer it.Close()
 
-		for it.Seek(p.Bytes()); it.ValidForPrefix(p.Bytes()); it.Next() {
-			item := it.Item()
-			keyStr := string(item.Key())
-			key := newDbKeyFromStr(keyStr)
-
-			keys[key[1]] = true
+		// collection_item::[ipns]::[cid]
+		p := d.key(dbKey{"collection_item", ipns, ""})
+		for it.Seek(d.key(dbKey{"collection_item", ipns, afterCID})); it.ValidForPrefix(p); it.Next() {
+			cid := d.parseKey(it.Item().Key())[2]
+			if cid == afterCID {
+				continue
+			}
+			if len(cids) == limit {
+				// There are more items
+				next = cids[len(cids)-1]
+				break
+			}
+			cids = append(cids, cid)
 		}
-
 		return nil
 	})
+	if err != nil {
+		return nil, "", err
+	}
 
+	return cids, next, nil
+}
+
+// ReadFolderChildren returns all children (sub-folders) in a folder.
+// Children include folders linked by LinkFolder, whose paths are not under the path of the folder.
+func (d *Datastore) ReadFolderChildren(folder *Folder) ([]string, error) {
+	folder, err := normalizeFolder(folder)
 	if err != nil {
 		return nil, err
 	}
 
-	var tags []Tag
-	for k := range keys {
-		tags = append(tags, NewTagFromStr(k))
+	exists, err := d.IsFolderPathExists(folder.IPNSAddress, folder.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrFolderNotExists
+	}
+
+	var children []string
+	err = d.view(func(txn *badger.Txn) error {
+		var err error
+		children, err = d.readPathListInTxn(txn, dbKey{"folder", folder.IPNSAddress, folder.Path, "children"})
+		return err
+	})
+
+	return children, err
+}
+
+// FolderAncestors returns the ancestors of a folder from the root folder to its parent, for breadcrumbs.
+// The root folder has no ancestors. ErrFolderNotExists is returned if the folder or any of its ancestors doesn't exist.
+func (d *Datastore) FolderAncestors(ipns, path string) ([]*Folder, error) {
+	if ipns == "" {
+		panic("Invalid parameters.")
+	}
+
+	path, err := NormalizeFolderPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ancestors []*Folder
+	err = d.view(func(txn *badger.Txn) error {
+		f := &Folder{IPNSAddress: ipns, Path: path}
+		for {
+			exists, err := d.isFolderPathExistsInTxn(txn, ipns, f.Path)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return ErrFolderNotExists
+			}
+			if f.Path == "" {
+				return nil
+			}
+
+			f = &Folder{IPNSAddress: ipns, Path: f.ParentPath()}
+			ancestors = append([]*Folder{f}, ancestors...)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ancestors, nil
+}
+
+// ResolveFolder returns a folder with ItemCount and ChildCount populated, and its ancestors from the root folder to its
+// parent, all verified against the store. ErrFolderNotExists is returned if the folder doesn't exist and
+// ErrParentFolderNotExists if any of its ancestors doesn't.
+func (d *Datastore) ResolveFolder(ipns, path string) (*Folder, []*Folder, error) {
+	if ipns == "" {
+		panic("Invalid parameters.")
+	}
+
+	path, err := NormalizeFolderPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var folder *Folder
+	var ancestors []*Folder
+	err = d.view(func(txn *badger.Txn) error {
+		exists, err := d.isFolderPathExistsInTxn(txn, ipns, path)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrFolderNotExists
+		}
+		folder, err = d.readFolderInTxn(txn, ipns, path)
+		if err != nil {
+			return err
+		}
+
+		f := folder
+		for f.Path != "" {
+			parentPath := f.ParentPath()
+			exists, err := d.isFolderPathExistsInTxn(txn, ipns, parentPath)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return ErrParentFolderNotExists
+			}
+			f, err = d.readFolderInTxn(txn, ipns, parentPath)
+			if err != nil {
+				return err
+			}
+			ancestors = append([]*Folder{f}, ancestors...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return tags, nil
+	return folder, ancestors, nil
 }
 
-// ReadTagItemCount returns []uint that are item counts of []Tag
-func (d *Datastore) ReadTagItemCount(tags []Tag) ([]uint, error) {
-	if len(tags) == 0 {
-		panic("Invalid tags.")
+// ReadFolderChildrenFull returns all children of a folder like ReadFolderChildren, as folders with ItemCount and
+// ChildCount populated.
+func (d *Datastore) ReadFolderChildrenFull(folder *Folder) ([]*Folder, error) {
+	folder, err := normalizeFolder(folder)
+	if err != nil {
+		return nil, err
 	}
 
-	var counts []uint
-
-	err := d.db.View(func(txn *badger.Txn) error {
-		for _, t := range tags {
-			if t.IsEmpty() {
-				panic("Invalid tag.")
-			}
+	var children []*Folder
+	err = d.view(func(txn *badger.Txn) error {
+		exists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, folder.Path)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrFolderNotExists
+		}
 
-			k := dbKey{"tag", t.String(), "count"}
-			item, err := txn.Get(k.Bytes())
-			var c uint
+		paths, err := d.readPathListInTxn(txn, dbKey{"folder", folder.IPNSAddress, folder.Path, "children"})
+		if err != nil {
+			return err
+		}
+		for _, p := range paths {
+			child, err := d.readFolderInTxn(txn, folder.IPNSAddress, p)
 			if err != nil {
-				// If a tag is not found in db, count 0 for it
-				if err != badger.ErrKeyNotFound {
-					return err
-				}
-			} else {
-				err := item.Value(func(val []byte) error {
-					c = uint(binary.BigEndian.Uint32(val))
-					return nil
-				})
-				if err != nil {
-					return err
-				}
+				return err
 			}
-			counts = append(counts, c)
+			children = append(children, child)
 		}
-
 		return nil
 	})
-
 	if err != nil {
 		return nil, err
 	}
 
-	return counts, nil
+	return children, nil
 }
 
-// CreateOrUpdateFolder creates a new folder or updates a folder
-func (d *Datastore) CreateOrUpdateFolder(folder *Folder) error {
-	if folder.IPNSAddress == "" {
-		panic("Invalid folder.")
-	}
-
-	err := d.checkIPNS(folder.IPNSAddress)
+// ReorderFolderChildren saves the order of the children of a folder, which ReadFolderChildren returns them in.
+// ErrInvalidOrder is returned if orderedChildPaths isn't a permutation of the children.
+func (d *Datastore) ReorderFolderChildren(folder *Folder, orderedChildPaths []string) error {
+	folder, err := normalizeFolder(folder)
 	if err != nil {
 		return err
 	}
 
-	err = d.db.Update(func(txn *badger.Txn) error {
-		return d.createOrUpdateFolderInTxn(txn, folder)
-	})
+	return d.update(func(txn *badger.Txn) error {
+		exists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, folder.Path)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrFolderNotExists
+		}
 
-	return err
+		k := dbKey{"folder", folder.IPNSAddress, folder.Path, "children"}
+		children, err := d.readPathListInTxn(txn, k)
+		if err != nil {
+			return err
+		}
+		if !isPermutation(children, orderedChildPaths) {
+			return ErrInvalidOrder
+		}
+
+		err = d.writePathListInTxn(txn, k, orderedChildPaths)
+		if err != nil {
+			return err
+		}
+		return d.touchCollectionInTxn(txn, folder.IPNSAddress)
+	})
 }
 
-func (d *Datastore) createOrUpdateFolderInTxn(txn *badger.Txn, folder *Folder) error {
-	k := dbKey{"folders", folder.IPNSAddress, folder.Path}
-	err := txn.Set(k.Bytes(), []byte(folder.Path))
-	if err != nil {
-		return err
+// isPermutation checks if b has the same strings as a, in any order.
+func isPermutation(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
 	}
-
-	isRoot := false
-
-	parentPath := folder.ParentPath()
-	if folder.Path == "" && parentPath == "" {
-		isRoot = true
+	counts := make(map[string]int)
+	for _, s := range a {
+		counts[s]++
+	}
+	for _, s := range b {
+		counts[s]--
+		if counts[s] < 0 {
+			return false
+		}
 	}
+	return true
+}
 
-	if !isRoot {
-		// Make sure parent exists
-		err = d.assertParentInTxn(txn, folder)
+// ItemLocations returns all folders the item is in across all collections, sorted by IPNS address and path.
+// ErrCIDNotFound is returned if the item doesn't exist.
+func (d *Datastore) ItemLocations(cid string) ([]ItemLocation, error) {
+	locs := []ItemLocation{}
+	err := d.view(func(txn *badger.Txn) error {
+		err := d.checkCIDInTxn(txn, cid)
 		if err != nil {
 			return err
 		}
 
-		// Add this folder to parent's children list
-		// Parent's Children key: folder::[ipns]::[folderPath]::children
-		pck := dbKey{"folder", folder.IPNSAddress, parentPath, "children"}
-		item, err := txn.Get(pck.Bytes())
-		var children []string
-		if err != nil && err != badger.ErrKeyNotFound {
-			return err
+		// item_folder::[cid]::[ipns]::[folderPath]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"item_folder", cid, ""}) {
+			locs = append(locs, ItemLocation{IPNS: k[2], Path: k[3]})
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		var buf bytes.Buffer
-		if item != nil {
-			v, err := item.ValueCopy(nil)
-			if err != nil {
-				return err
-			}
-
-			// Read children
-			buf = *bytes.NewBuffer(v)
-			dec := gob.NewDecoder(&buf)
-			err = dec.Decode(&children)
-			if err != nil {
-				return err
-			}
+	sort.Slice(locs, func(i, j int) bool {
+		if locs[i].IPNS != locs[j].IPNS {
+			return locs[i].IPNS < locs[j].IPNS
 		}
+		return locs[i].Path < locs[j].Path
+	})
+	return locs, nil
+}
 
-		// Add folder to children
-		children = append(children, folder.Path)
-
-		// Save back
-		buf.Reset()
-		enc := gob.NewEncoder(&buf)
-		err = enc.Encode(children)
+// FoldersWithout returns paths of all folders in a collection which don't contain the item, sorted by path.
+func (d *Datastore) FoldersWithout(cid, ipns string) ([]string, error) {
+	var paths []string
+	err := d.view(func(txn *badger.Txn) error {
+		err := d.checkCIDInTxn(txn, cid)
 		if err != nil {
 			return err
 		}
-
-		err = txn.Set(pck.Bytes(), buf.Bytes())
+		err = d.checkIPNSInTxn(txn, ipns)
 		if err != nil {
 			return err
 		}
-	}
 
-	return nil
-}
+		// item_folder::[cid]::[ipns]::[folderPath]
+		in := make(map[string]bool)
+		for _, k := range d.readKeysInTxn(txn, dbKey{"item_folder", cid, ipns, ""}) {
+			in[k[3]] = true
+		}
 
-// ReadFolder reads a folder from Datastore.
-func (d *Datastore) ReadFolder(ipns, path string) (*Folder, error) {
-	if ipns == "" {
-		panic("Invalid parameters.")
+		// folders::[ipns]::[folderPath]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"folders", ipns, ""}) {
+			if !in[k[2]] {
+				paths = append(paths, k[2])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// path can be "" as a root folder
+	sort.Strings(paths)
+	return paths, nil
+}
 
-	exists, err := d.IsFolderPathExists(ipns, path)
+// readPathListInTxn reads a list of folder paths, such as folder::[ipns]::[folderPath]::children.
+// A missing key reads as an empty list.
+func (d *Datastore) readPathListInTxn(txn *badger.Txn, k dbKey) ([]string, error) {
+	item, err := txn.Get(d.key(k))
 	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, nil
+		}
 		return nil, err
 	}
-	if !exists {
-		return nil, ErrFolderNotExists
-	}
 
-	return &Folder{Path: path, IPNSAddress: ipns}, nil
+	var paths []string
+	err = item.Value(func(val []byte) error {
+		dec := gob.NewDecoder(bytes.NewBuffer(val))
+		return dec.Decode(&paths)
+	})
+	return paths, err
 }
 
-// IsFolderPathExists checkes if a folder exists.
-func (d *Datastore) IsFolderPathExists(ipns, path string) (bool, error) {
-
-	exists := false
-
-	err := d.db.View(func(txn *badger.Txn) error {
-		var err error
-		exists, err = d.isFolderPathExistsInTxn(txn, ipns, path)
+// writePathListInTxn saves a list of folder paths.
+func (d *Datastore) writePathListInTxn(txn *badger.Txn, k dbKey, paths []string) error {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	err := enc.Encode(paths)
+	if err != nil {
 		return err
-	})
+	}
+	return txn.Set(d.key(k), buf.Bytes())
+}
 
+// removeFromPathListInTxn removes a path from a list of folder paths. It's a no-op if the list doesn't exist.
+func (d *Datastore) removeFromPathListInTxn(txn *badger.Txn, k dbKey, path string) error {
+	paths, err := d.readPathListInTxn(txn, k)
 	if err != nil {
-		return false, err
+		return err
+	}
+	if paths == nil {
+		return nil
 	}
-	return exists, nil
-}
 
-func (d *Datastore) isFolderPathExistsInTxn(txn *badger.Txn, ipns, path string) (bool, error) {
+	j := 0
+	for _, p := range paths {
+		if p != path {
+			paths[j] = p
+			j++
+		}
+	}
 
-	err := d.checkIPNS(ipns)
+	return d.writePathListInTxn(txn, k, paths[:j])
+}
+
+// LinkFolder adds a folder as a child of another parent folder in the same collection, without copying it.
+// The folder stays a child of its original parent. Deleting one of its parents only removes the link
+// if the folder is still referred by other parents.
+func (d *Datastore) LinkFolder(ipns, folderPath, newParentPath string) error {
+	folderPath, err := NormalizeFolderPath(folderPath)
 	if err != nil {
-		return false, err
+		return err
+	}
+	newParentPath, err = NormalizeFolderPath(newParentPath)
+	if err != nil {
+		return err
 	}
 
-	exists := false
-
-	k := dbKey{"folders", ipns, path}
+	exists, err := d.IsFolderPathExists(ipns, folderPath)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrFolderNotExists
+	}
 
-	_, err = txn.Get(k.Bytes())
+	exists, err = d.IsFolderPathExists(ipns, newParentPath)
 	if err != nil {
-		if err != badger.ErrKeyNotFound {
-			return false, err
-		}
-	} else {
-		exists = true
+		return err
+	}
+	if !exists {
+		return ErrParentFolderNotExists
 	}
 
-	return exists, nil
+	err = d.update(func(txn *badger.Txn) error {
+		return d.linkFolderInTxn(txn, ipns, folderPath, newParentPath)
+	})
+
+	return err
 }
 
-// assertParent checks if parent of folder exists. If not, an error will be returned.
-// If parent is root, it will create the root folder.
-func (d *Datastore) assertParentInTxn(txn *badger.Txn, folder *Folder) error {
+func (d *Datastore) linkFolderInTxn(txn *badger.Txn, ipns, folderPath, newParentPath string) error {
+	folder := &Folder{IPNSAddress: ipns, Path: folderPath}
 
-	if folder.ParentPath() == "" {
-		// Check root folder existence
-		rootExists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, "")
-		if err != nil {
-			if err == ErrFolderNotExists {
-				rootExists = false
-			} else {
-				return err
-			}
-		}
+	// Linking a folder into its own subtree would make it its own descendant
+	reachable, err := d.isFolderReachableInTxn(txn, ipns, folderPath, newParentPath)
+	if err != nil {
+		return err
+	}
+	if reachable {
+		return ErrFolderCycle
+	}
 
-		// Create root folder if not exists in Datastore
-		if !rootExists {
-			root := &Folder{IPNSAddress: folder.IPNSAddress}
-			err = d.createOrUpdateFolderInTxn(txn, root)
-			if err != nil {
-				return err
-			}
+	pck := dbKey{"folder", ipns, newParentPath, "children"}
+	children, err := d.readPathListInTxn(txn, pck)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if child == folderPath {
+			// Already a child of the parent
+			return nil
 		}
+	}
+	err = d.writePathListInTxn(txn, pck, append(children, folderPath))
+	if err != nil {
+		return err
+	}
 
-	} else {
-		exists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, folder.ParentPath())
-		if err != nil {
-			return err
-		}
-		if !exists {
-			return ErrParentFolderNotExists
-		}
+	err = d.touchCollectionInTxn(txn, ipns)
+	if err != nil {
+		return err
+	}
 
+	if newParentPath == folder.ParentPath() {
+		return nil
 	}
 
-	return nil
+	lk := dbKey{"folder", ipns, folderPath, "links"}
+	links, err := d.readPathListInTxn(txn, lk)
+	if err != nil {
+		return err
+	}
+	return d.writePathListInTxn(txn, lk, append(links, newParentPath))
 }
 
-// AddItemToFolder adds an item to a folder
-func (d *Datastore) AddItemToFolder(cid string, folder *Folder) error {
-	err := d.checkCID(cid)
+// UnlinkFolder removes a folder linked by LinkFolder from a parent folder. The folder itself won't be deleted.
+func (d *Datastore) UnlinkFolder(ipns, folderPath, parentPath string) error {
+	folderPath, err := NormalizeFolderPath(folderPath)
+	if err != nil {
+		return err
+	}
+	parentPath, err = NormalizeFolderPath(parentPath)
 	if err != nil {
 		return err
 	}
 
-	exists, err := d.IsFolderPathExists(folder.IPNSAddress, folder.Path)
+	exists, err := d.IsFolderPathExists(ipns, folderPath)
 	if err != nil {
 		return err
 	}
@@ -1189,106 +3661,225 @@ func (d *Datastore) AddItemToFolder(cid string, folder *Folder) error {
 		return ErrFolderNotExists
 	}
 
-	err = d.db.Update(func(txn *badger.Txn) error {
-		// item_folder::[cid]::[ipns]::[folderPath] = [folderPath]
-		k := dbKey{"item_folder", cid, folder.IPNSAddress, folder.Path}
-		err := txn.Set(k.Bytes(), []byte(folder.Path))
+	err = d.update(func(txn *badger.Txn) error {
+		lk := dbKey{"folder", ipns, folderPath, "links"}
+		links, err := d.readPathListInTxn(txn, lk)
+		if err != nil {
+			return err
+		}
+
+		linked := false
+		for _, l := range links {
+			if l == parentPath {
+				linked = true
+				break
+			}
+		}
+		if !linked {
+			return ErrFolderNotLinked
+		}
+
+		err = d.removeFromPathListInTxn(txn, lk, parentPath)
 		if err != nil {
 			return err
 		}
 
-		// folder_item::[ipns]::[folderPath]::[cid] = [cid]
-		k = dbKey{"folder_item", folder.IPNSAddress, folder.Path, cid}
-		err = txn.Set(k.Bytes(), []byte(cid))
+		err = d.removeFromPathListInTxn(txn, dbKey{"folder", ipns, parentPath, "children"}, folderPath)
 		if err != nil {
 			return err
 		}
 
-		return nil
+		return d.touchCollectionInTxn(txn, ipns)
+	})
 
+	return err
+}
+
+// isFolderReachableInTxn checks if folder path "to" is "from" itself or one of its descendants, following links.
+func (d *Datastore) isFolderReachableInTxn(txn *badger.Txn, ipns, from, to string) (bool, error) {
+	visited := make(map[string]bool)
+	stack := []string{from}
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if p == to {
+			return true, nil
+		}
+		if visited[p] {
+			continue
+		}
+		visited[p] = true
+
+		children, err := d.readPathListInTxn(txn, dbKey{"folder", ipns, p, "children"})
+		if err != nil {
+			return false, err
+		}
+		stack = append(stack, children...)
+	}
+	return false, nil
+}
+
+// DelFolder deletes a folder and all its children folders. It also remove relationships with items.
+// Items won't be deleted. If an item doesn't belong to any folder of the collection, it will be removed from the collection.
+// Linked children folders won't be deleted. Children folders which are still linked to folders
+// outside of the deleted folder are kept as well, and moved under the first of those folders. ErrFolderExists is
+// returned if all those folders already have a child with the same name.
+func (d *Datastore) DelFolder(folder *Folder) error {
+	folder, err := normalizeFolder(folder)
+	if err != nil {
+		return err
+	}
+
+	if folder.Path == "" {
+		return ErrCantDelRootFolder
+	}
+
+	err = d.update(func(txn *badger.Txn) error {
+		return d.delFolderTreeInTxn(txn, folder)
 	})
 
 	return err
 }
 
-// RemoveItemFromFolder removes item from a folder
-func (d *Datastore) RemoveItemFromFolder(cid string, folder *Folder) error {
-	err := d.checkCID(cid)
+// delFolderTreeInTxn deletes a folder and its children, and removes it from the children lists of its parents.
+func (d *Datastore) delFolderTreeInTxn(txn *badger.Txn, folder *Folder) error {
+	plan, err := d.planFolderDeletionInTxn(txn, folder)
+	if err != nil {
+		return err
+	}
+
+	links, err := d.readPathListInTxn(txn, dbKey{"folder", folder.IPNSAddress, folder.Path, "links"})
+	if err != nil {
+		return err
+	}
+
+	// Delete folder itself and its children
+	err = d.delFolderInTxn(txn, folder.IPNSAddress, plan)
 	if err != nil {
 		return err
 	}
 
-	err = d.db.Update(func(txn *badger.Txn) error {
-		// item_folder::[cid]::[ipns]::[folderPath] = [folderPath]
-		k := dbKey{"item_folder", cid, folder.IPNSAddress, folder.Path}
-		_, err := txn.Get(k.Bytes())
+	// Remove folder from children lists of parent and linked parents
+	for _, parentPath := range append([]string{folder.ParentPath()}, links...) {
+		pck := dbKey{"folder", folder.IPNSAddress, parentPath, "children"}
+		err = d.removeFromPathListInTxn(txn, pck, folder.Path)
 		if err != nil {
-			if err == badger.ErrKeyNotFound {
-				return ErrItemNotInFolder
-			}
 			return err
 		}
+	}
 
-		err = txn.Delete(k.Bytes())
-		if err != nil {
-			return err
-		}
+	return nil
+}
 
-		// folder_item::[ipns]::[folderPath]::[cid] = [cid]
-		k = dbKey{"folder_item", folder.IPNSAddress, folder.Path, cid}
-		err = txn.Delete(k.Bytes())
+// DeleteEmptyFolders deletes all folders of a collection which have no items and no child folders, bottom-up, so
+// folders left empty by deleting their children are deleted too. The root folder is never deleted.
+// It returns the number of deleted folders.
+func (d *Datastore) DeleteEmptyFolders(ipns string) (int, error) {
+	var n int
+	err := d.update(func(txn *badger.Txn) error {
+		n = 0
+		err := d.checkIPNSInTxn(txn, ipns)
 		if err != nil {
 			return err
 		}
 
-		return nil
-	})
+		// Children sort after their parents, so reversed paths are bottom-up. Linked children may sort before their
+		// linked parents, so look again until nothing is deleted.
+		for {
+			// folders::[ipns]::[folderPath]
+			var paths []string
+			for _, k := range d.readKeysInTxn(txn, dbKey{"folders", ipns, ""}) {
+				if k[2] != "" {
+					paths = append(paths, k[2])
+				}
+			}
+			sort.Sort(sort.Reverse(sort.StringSlice(paths)))
 
-	return err
-}
+			deleted := 0
+			for _, p := range paths {
+				empty, err := d.isFolderEmptyInTxn(txn, ipns, p)
+				if err != nil {
+					return err
+				}
+				if !empty {
+					continue
+				}
 
-// IsItemInFolder checks if an item is in a folder
-func (d *Datastore) IsItemInFolder(cid string, folder *Folder) (bool, error) {
-	var inFolder bool
-	err := d.db.View(func(txn *badger.Txn) error {
-		var err error
-		inFolder, err = d.isItemInFolderInTxn(txn, cid, folder)
-		return err
+				err = d.delFolderTreeInTxn(txn, &Folder{IPNSAddress: ipns, Path: p})
+				if err != nil {
+					return err
+				}
+				deleted++
+			}
+
+			n += deleted
+			if deleted == 0 {
+				return nil
+			}
+		}
 	})
+	if err != nil {
+		return 0, err
+	}
 
-	return inFolder, err
+	return n, nil
 }
 
-func (d *Datastore) isItemInFolderInTxn(txn *badger.Txn, cid string, folder *Folder) (bool, error) {
-	err := d.checkCID(cid)
+// DelFolderPreview returns the folders DelFolder would delete and the items it would remove from the collection.
+// Nothing is changed.
+func (d *Datastore) DelFolderPreview(folder *Folder) (*DeletionPreview, error) {
+	folder, err := normalizeFolder(folder)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	exists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, folder.Path)
-	if err != nil {
-		return false, err
-	}
-	if !exists {
-		return false, ErrFolderNotExists
+	if folder.Path == "" {
+		return nil, ErrCantDelRootFolder
 	}
 
-	var inFolder bool
-	k := dbKey{"item_folder", cid, folder.IPNSAddress, folder.Path}
-	_, err = txn.Get(k.Bytes())
+	var plan *folderDeletion
+	err = d.view(func(txn *badger.Txn) error {
+		var err error
+		plan, err = d.planFolderDeletionInTxn(txn, folder)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	if err == nil {
-		inFolder = true
-	} else if err == badger.ErrKeyNotFound {
-		err = nil
+	preview := &DeletionPreview{
+		Folders:     append([]string{}, plan.folders...),
+		EvictedCIDs: append([]string{}, plan.evicted...),
 	}
+	sort.Strings(preview.Folders)
+	sort.Strings(preview.EvictedCIDs)
+	return preview, nil
+}
 
-	return inFolder, err
+// folderDeletion is what deleting a folder changes, computed by planFolderDeletionInTxn.
+type folderDeletion struct {
+	// folders are paths of folders to delete, children first
+	folders []string
+	// items maps a folder path in folders to CIDs of items in it
+	items map[string][]string
+	// unlinks are links from deleted folders to linked children which are kept
+	unlinks []folderLink
+	// kept are paths of children of deleted folders which are kept because they are linked to folders which won't be
+	// deleted. They are moved under one of those folders.
+	kept []string
+	// evicted are CIDs of items which won't be in any folder of the collection
+	evicted []string
 }
 
-// ReadFolderItems returns all items' CID in a folder
-func (d *Datastore) ReadFolderItems(folder *Folder) ([]string, error) {
-	exists, err := d.IsFolderPathExists(folder.IPNSAddress, folder.Path)
+type folderLink struct {
+	parent string
+	child  string
+}
+
+// planFolderDeletionInTxn walks a folder and its children like DelFolder does, without changing anything.
+func (d *Datastore) planFolderDeletionInTxn(txn *badger.Txn, folder *Folder) (*folderDeletion, error) {
+	exists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, folder.Path)
 	if err != nil {
 		return nil, err
 	}
@@ -1296,452 +3887,713 @@ func (d *Datastore) ReadFolderItems(folder *Folder) ([]string, error) {
 		return nil, ErrFolderNotExists
 	}
 
-	var items []string
-	err = d.db.View(func(txn *badger.Txn) error {
-		p := dbKey{"folder_item", folder.IPNSAddress, folder.Path}
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-		it := txn.NewIterator(opts)
-		defer it.Close()
+	plan := &folderDeletion{items: make(map[string][]string)}
+	err = d.walkFolderDeletionInTxn(txn, folder, folder.Path, plan)
+	if err != nil {
+		return nil, err
+	}
 
-		for it.Seek(p.Bytes()); it.ValidForPrefix(p.Bytes()); it.Next() {
-			item := it.Item()
-			keyStr := string(item.Key())
-			key := newDbKeyFromStr(keyStr)
+	deleted := make(map[string]bool)
+	for _, path := range plan.folders {
+		deleted[path] = true
+	}
 
-			items = append(items, key[3])
+	// An item is removed from the collection if all its folders are deleted
+	checked := make(map[string]bool)
+	for _, path := range plan.folders {
+		for _, cid := range plan.items[path] {
+			if checked[cid] {
+				continue
+			}
+			checked[cid] = true
+
+			evicted := true
+			// item_folder::[cid]::[ipns]::[folderPath]
+			for _, k := range d.readKeysInTxn(txn, dbKey{"item_folder", cid, folder.IPNSAddress, ""}) {
+				if !deleted[k[3]] {
+					evicted = false
+					break
+				}
+			}
+			if evicted {
+				plan.evicted = append(plan.evicted, cid)
+			}
 		}
+	}
 
-		return nil
-	})
-
-	return items, err
+	return plan, nil
 }
 
-// ReadCollectionItems returns all items' CID in a collection
-func (d *Datastore) ReadCollectionItems(ipns string) ([]string, error) {
-	err := d.checkIPNS(ipns)
+// walkFolderDeletionInTxn adds a folder and its children folders to plan. top is the path of the folder DelFolder is called with.
+func (d *Datastore) walkFolderDeletionInTxn(txn *badger.Txn, folder *Folder, top string, plan *folderDeletion) error {
+	children, err := d.readPathListInTxn(txn, dbKey{"folder", folder.IPNSAddress, folder.Path, "children"})
 	if err != nil {
-		return nil, err
+		return err
 	}
+	for _, child := range children {
+		childFolder := &Folder{IPNSAddress: folder.IPNSAddress, Path: child}
 
-	var items []string
-	err = d.db.View(func(txn *badger.Txn) error {
-		p := dbKey{"collection_item", ipns}
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-		it := txn.NewIterator(opts)
-		defer it.Close()
+		exists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, child)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			// Just skip if folder isn't exist
+			continue
+		}
 
-		for it.Seek(p.Bytes()); it.ValidForPrefix(p.Bytes()); it.Next() {
-			item := it.Item()
-			keyStr := string(item.Key())
-			key := newDbKeyFromStr(keyStr)
+		if childFolder.ParentPath() != folder.Path {
+			// A linked child. Only remove the link.
+			plan.unlinks = append(plan.unlinks, folderLink{parent: folder.Path, child: child})
+			continue
+		}
 
-			items = append(items, key[2])
+		// Keep the child if it's still linked to a folder which won't be deleted
+		links, err := d.readPathListInTxn(txn, dbKey{"folder", folder.IPNSAddress, child, "links"})
+		if err != nil {
+			return err
+		}
+		linked := false
+		for _, l := range links {
+			if !isPathUnder(l, top) {
+				linked = true
+				break
+			}
+		}
+		if linked {
+			plan.kept = append(plan.kept, child)
+			continue
 		}
 
-		return nil
-	})
+		err = d.walkFolderDeletionInTxn(txn, childFolder, top, plan)
+		if err != nil {
+			return err
+		}
+	}
 
-	return items, err
+	// folder_item::[ipns]::[folderPath]::[cid]
+	var cids []string
+	for _, k := range d.readKeysInTxn(txn, dbKey{"folder_item", folder.IPNSAddress, folder.Path, ""}) {
+		cids = append(cids, k[3])
+	}
+
+	plan.folders = append(plan.folders, folder.Path)
+	plan.items[folder.Path] = cids
+	return nil
 }
 
-// ReadFolderChildren returns all children (sub-folders) in a folder
-func (d *Datastore) ReadFolderChildren(folder *Folder) ([]string, error) {
-	exists, err := d.IsFolderPathExists(folder.IPNSAddress, folder.Path)
+// isPathUnder checks if path is root or in the subtree of root
+func isPathUnder(path, root string) bool {
+	return path == root || strings.HasPrefix(path, root+"/")
+}
+
+// joinFolderPath returns the path of a child folder named basename under parentPath. parentPath is "" for root.
+func joinFolderPath(parentPath, basename string) string {
+	if parentPath == "" {
+		return basename
+	}
+	return parentPath + "/" + basename
+}
+
+// normalizeFolder returns a copy of folder with its path normalized by NormalizeFolderPath, leaving folder unchanged.
+func normalizeFolder(folder *Folder) (*Folder, error) {
+	path, err := NormalizeFolderPath(folder.Path)
 	if err != nil {
 		return nil, err
 	}
-	if !exists {
-		return nil, ErrFolderNotExists
-	}
+	f := *folder
+	f.Path = path
+	return &f, nil
+}
 
-	var children []string
-	err = d.db.View(func(txn *badger.Txn) error {
-		k := dbKey{"folder", folder.IPNSAddress, folder.Path, "children"}
-		i, err := txn.Get(k.Bytes())
-		if err != nil && err != badger.ErrKeyNotFound {
+// delFolderInTxn deletes the folders of a plan made by planFolderDeletionInTxn and their relationships with items.
+func (d *Datastore) delFolderInTxn(txn *badger.Txn, ipns string, plan *folderDeletion) error {
+	for _, l := range plan.unlinks {
+		err := d.removeFromPathListInTxn(txn, dbKey{"folder", ipns, l.child, "links"}, l.parent)
+		if err != nil {
 			return err
 		}
+	}
 
-		if i != nil {
-			err := i.Value(func(val []byte) error {
-				buf := bytes.NewBuffer(val)
-				dec := gob.NewDecoder(buf)
-				err = dec.Decode(&children)
-				if err != nil {
-					return err
-				}
-				return nil
-			})
+	for _, path := range plan.folders {
+		// item_folder::[cid]::[ipns]::[folderPath]
+		for _, cid := range plan.items[path] {
+			err := txn.Delete(d.key(dbKey{"item_folder", cid, ipns, path}))
 			if err != nil {
 				return err
 			}
+		}
 
+		// folder_item::[ipns]::[folderPath]::[cid]
+		err := d.dropPrefix(txn, dbKey{"folder_item", ipns, path, ""})
+		if err != nil {
+			return err
 		}
 
-		return nil
-	})
+		// folder::[ipns]::[folderPath]
+		err = d.dropPrefix(txn, dbKey{"folder", ipns, path, ""})
+		if err != nil {
+			return err
+		}
 
-	return children, err
-}
+		// folder_order::[ipns]::[folderPath]
+		err = txn.Delete(d.key(dbKey{"folder_order", ipns, path}))
+		if err != nil {
+			return err
+		}
 
-// DelFolder deletes a folder and all its children folders. It also remove relationships with items.
-// Items won't be deleted. If an item doesn't belong to any folder of the collection, it will be removed from the collection.
-func (d *Datastore) DelFolder(folder *Folder) error {
-	if folder.Path == "" {
-		return ErrCantDelRootFolder
+		// folders::[ipns]::[folderPath]
+		err = txn.Delete(d.key(dbKey{"folders", ipns, path}))
+		if err != nil {
+			return err
+		}
 	}
 
-	exists, err := d.IsFolderPathExists(folder.IPNSAddress, folder.Path)
+	deleted := make(map[string]bool)
+	for _, path := range plan.folders {
+		deleted[path] = true
+	}
+	for _, path := range plan.kept {
+		err := d.reparentKeptFolderInTxn(txn, ipns, path, deleted)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Items which don't belong to any folder of the collection are removed from the collection
+	for _, cid := range plan.evicted {
+		err := d.removeItemFromCollectionInTxn(txn, cid, ipns)
+		if err != nil {
+			return err
+		}
+	}
+
+	return d.touchCollectionInTxn(txn, ipns)
+}
+
+// reparentKeptFolderInTxn moves a folder whose parent is deleted under the first of its linked parents which isn't
+// deleted and has no child with the same name. Links to deleted parents are dropped.
+// ErrFolderExists is returned if all its linked parents have a child with the same name.
+func (d *Datastore) reparentKeptFolderInTxn(txn *badger.Txn, ipns, path string, deleted map[string]bool) error {
+	lk := dbKey{"folder", ipns, path, "links"}
+	links, err := d.readPathListInTxn(txn, lk)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return ErrFolderNotExists
-	}
 
-	err = d.db.Update(func(txn *badger.Txn) error {
+	var parents []string
+	for _, l := range links {
+		if !deleted[l] {
+			parents = append(parents, l)
+		}
+	}
 
-		// Delete folder itself
-		err := d.delFolderInTxn(txn, folder)
+	basename := (&Folder{IPNSAddress: ipns, Path: path}).Basename()
+	for i, parentPath := range parents {
+		newPath := joinFolderPath(parentPath, basename)
+		exists, err := d.isFolderPathExistsInTxn(txn, ipns, newPath)
 		if err != nil {
 			return err
 		}
+		if exists {
+			continue
+		}
 
-		// Remove folder from parent's children list
-		pck := dbKey{"folder", folder.IPNSAddress, folder.ParentPath(), "children"}
-		item, err := txn.Get(pck.Bytes())
-		if err != nil && err != badger.ErrKeyNotFound {
+		// The new parent becomes the natural parent, so it's not a link anymore
+		rest := append(append([]string{}, parents[:i]...), parents[i+1:]...)
+		if len(rest) == 0 {
+			err = txn.Delete(d.key(lk))
+		} else {
+			err = d.writePathListInTxn(txn, lk, rest)
+		}
+		if err != nil {
 			return err
 		}
-		if item != nil {
-			var pChildren []string
-			var buf bytes.Buffer
-			v, err := item.ValueCopy(nil)
-			if err != nil {
-				return err
-			}
-			// Read children
-			buf = *bytes.NewBuffer(v)
-			dec := gob.NewDecoder(&buf)
-			err = dec.Decode(&pChildren)
-			if err != nil {
-				return err
-			}
+		return d.moveFolderInTxn(txn, ipns, path, newPath)
+	}
+	return ErrFolderExists
+}
 
-			// Remove folder from children
-			j := 0
-			for _, child := range pChildren {
-				if child != folder.Path {
-					pChildren[j] = child
-					j++
-				}
-			}
-			pChildren = pChildren[:j]
+// MoveItemToFolder moves an item from a folder to another folder in one transaction.
+// If the folders are in different collections, the item is added to the collection of "to", and it's removed from the
+// collection of "from" unless it's still in other folders of that collection.
+// ErrItemNotInFolder is returned if the item isn't in "from" and ErrFolderNotExists if "to" doesn't exist.
+func (d *Datastore) MoveItemToFolder(cid string, from, to *Folder) error {
+	from, err := normalizeFolder(from)
+	if err != nil {
+		return err
+	}
+	to, err = normalizeFolder(to)
+	if err != nil {
+		return err
+	}
 
-			// Save back
-			buf.Reset()
-			enc := gob.NewEncoder(&buf)
-			err = enc.Encode(pChildren)
-			if err != nil {
-				return err
-			}
+	return d.update(func(txn *badger.Txn) error {
+		return d.moveItemToFolderInTxn(txn, cid, from, to)
+	})
+}
 
-			err = txn.Set(pck.Bytes(), buf.Bytes())
-			if err != nil {
-				return err
-			}
+func (d *Datastore) moveItemToFolderInTxn(txn *badger.Txn, cid string, from, to *Folder) error {
+	err := d.checkItemMovableInTxn(txn, cid, from, to)
+	if err != nil {
+		return err
+	}
 
-		}
+	err = d.removeItemFromFolderInTxn(txn, cid, from)
+	if err != nil {
+		return err
+	}
+
+	err = d.addItemToFolderInTxn(txn, cid, to)
+	if err != nil {
+		return err
+	}
 
+	if from.IPNSAddress == to.IPNSAddress {
 		return nil
-	})
+	}
+	err = d.ensureItemInCollectionInTxn(txn, cid, to.IPNSAddress)
+	if err != nil {
+		return err
+	}
+	// item_folder::[cid]::[ipns]::[folderPath]
+	if d.hasPrefixInTxn(txn, dbKey{"item_folder", cid, from.IPNSAddress, ""}) {
+		return nil
+	}
+	return d.removeItemFromCollectionInTxn(txn, cid, from.IPNSAddress)
+}
 
-	return err
+// CopyItemToFolder adds an item in a folder to another folder too. The item stays in "from".
+// If the folders are in different collections, the item is added to the collection of "to" as well.
+// ErrItemNotInFolder is returned if the item isn't in "from" and ErrFolderNotExists if "to" doesn't exist.
+func (d *Datastore) CopyItemToFolder(cid string, from, to *Folder) error {
+	from, err := normalizeFolder(from)
+	if err != nil {
+		return err
+	}
+	to, err = normalizeFolder(to)
+	if err != nil {
+		return err
+	}
+
+	return d.update(func(txn *badger.Txn) error {
+		return d.copyItemToFolderInTxn(txn, cid, from, to)
+	})
 }
 
-func (d *Datastore) delFolderInTxn(txn *badger.Txn, folder *Folder) error {
+func (d *Datastore) copyItemToFolderInTxn(txn *badger.Txn, cid string, from, to *Folder) error {
+	err := d.checkItemMovableInTxn(txn, cid, from, to)
+	if err != nil {
+		return err
+	}
 
-	exists, err := d.IsFolderPathExists(folder.IPNSAddress, folder.Path)
+	err = d.addItemToFolderInTxn(txn, cid, to)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		// Just skip if folder isn't exist
+
+	if from.IPNSAddress == to.IPNSAddress {
 		return nil
 	}
+	return d.ensureItemInCollectionInTxn(txn, cid, to.IPNSAddress)
+}
 
-	children, err := d.ReadFolderChildren(folder)
+// MoveAllItems moves all items directly in folder "from" to folder "to" in one transaction. Sub folders are not moved.
+// Collection membership is updated like MoveItemToFolder. ErrFolderNotExists is returned if either folder doesn't exist.
+func (d *Datastore) MoveAllItems(from, to *Folder) error {
+	from, err := normalizeFolder(from)
 	if err != nil {
 		return err
 	}
-	// Recursively delete children folder
-	for _, child := range children {
-		err := d.delFolderInTxn(txn, &Folder{IPNSAddress: folder.IPNSAddress, Path: child})
-		if err != nil {
-			return err
-		}
-	}
-
-	items, err := d.ReadFolderItems(folder)
+	to, err = normalizeFolder(to)
 	if err != nil {
 		return err
 	}
 
-	opts := badger.DefaultIteratorOptions
-	opts.PrefetchValues = false
-
-	// item_folder::[cid]::[ipns]::[folderPath]
-	for _, cid := range items {
-		k := dbKey{"item_folder", cid, folder.IPNSAddress, folder.Path}
-		err := txn.Delete(k.Bytes())
-		if err != nil {
-			return err
+	return d.update(func(txn *badger.Txn) error {
+		for _, f := range []*Folder{from, to} {
+			exists, err := d.isFolderPathExistsInTxn(txn, f.IPNSAddress, f.Path)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return ErrFolderNotExists
+			}
 		}
-
-		// Check if the item belongs to any other folders of the collection.
-		// If not, remove it from collection.
-		p := dbKey{"item_folder", cid, folder.IPNSAddress}
-		it := txn.NewIterator(opts)
-
-		inFolder := false
-		for it.Seek(p.Bytes()); it.ValidForPrefix(p.Bytes()); it.Next() {
-			inFolder = true
-			break
+		if from.IPNSAddress == to.IPNSAddress && from.Path == to.Path {
+			return nil
 		}
-		it.Close()
 
-		if !inFolder {
-			err = d.removeItemFromCollectionInTxn(txn, cid, folder.IPNSAddress)
+		// folder_item::[ipns]::[folderPath]::[cid]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"folder_item", from.IPNSAddress, from.Path, ""}) {
+			err := d.moveItemToFolderInTxn(txn, k[3], from, to)
 			if err != nil {
-				it.Close()
 				return err
 			}
 		}
-	}
+		return nil
+	})
+}
 
-	// folder_item::[ipns]::[folderPath]::[cid]
-	p := dbKey{"folder_item", folder.IPNSAddress, folder.Path}
-	err = d.dropPrefix(txn, p)
+// checkItemMovableInTxn checks that an item is in folder "from" and folder "to" exists.
+func (d *Datastore) checkItemMovableInTxn(txn *badger.Txn, cid string, from, to *Folder) error {
+	err := d.checkCIDInTxn(txn, cid)
 	if err != nil {
 		return err
 	}
 
-	// folder::[ipns]::[folderPath]
-	p = dbKey{"folder", folder.IPNSAddress, folder.Path}
-	err = d.dropPrefix(txn, p)
+	exists, err := d.isItemInFolderInTxn(txn, cid, from)
 	if err != nil {
 		return err
 	}
+	if !exists {
+		return ErrItemNotInFolder
+	}
 
-	// folders::[ipns]::[folderPath]
-	k := dbKey{"folders", folder.IPNSAddress, folder.Path}
-	err = txn.Delete(k.Bytes())
+	exists, err = d.isFolderPathExistsInTxn(txn, to.IPNSAddress, to.Path)
 	if err != nil {
 		return err
 	}
-
+	if !exists {
+		return ErrFolderNotExists
+	}
 	return nil
-
 }
 
-// MoveOrCopyItem moves or copies an item from a folder to another folder
-func (d *Datastore) MoveOrCopyItem(cid string, folderFrom, folderTo *Folder, copy bool) error {
-	err := d.checkCID(cid)
+// ensureItemInCollectionInTxn adds an item to a collection without adding it to the root folder, if it's not in the collection yet.
+func (d *Datastore) ensureItemInCollectionInTxn(txn *badger.Txn, cid, ipns string) error {
+	inCollection, err := d.isItemInCollectionInTxn(txn, cid, ipns)
 	if err != nil {
 		return err
 	}
+	if inCollection {
+		return nil
+	}
+	return d.addItemToCollectionInTxn(txn, cid, ipns, false)
+}
 
-	exists, err := d.IsItemInFolder(cid, folderFrom)
+// MoveOrCopyItem moves or copies an item from a folder to another folder. See MoveItemToFolder and CopyItemToFolder.
+func (d *Datastore) MoveOrCopyItem(cid string, folderFrom, folderTo *Folder, copy bool) error {
+	folderFrom, err := normalizeFolder(folderFrom)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return ErrItemNotInFolder
-	}
-
-	exists, err = d.IsFolderPathExists(folderTo.IPNSAddress, folderTo.Path)
+	folderTo, err = normalizeFolder(folderTo)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return ErrFolderNotExists
-	}
 
-	err = d.db.Update(func(txn *badger.Txn) error {
+	return d.update(func(txn *badger.Txn) error {
 		return d.moveOrCopyItemInTxn(txn, cid, folderFrom, folderTo, copy)
 	})
-
-	return nil
 }
 
 func (d *Datastore) moveOrCopyItemInTxn(txn *badger.Txn, cid string, folderFrom, folderTo *Folder, copy bool) error {
-	err := d.checkCID(cid)
+	if copy {
+		return d.copyItemToFolderInTxn(txn, cid, folderFrom, folderTo)
+	}
+	return d.moveItemToFolderInTxn(txn, cid, folderFrom, folderTo)
+}
+
+// MoveOrCopyFolder moves or copies a folder to destination
+func (d *Datastore) MoveOrCopyFolder(folderFrom, folderTo *Folder, copy bool) error {
+	folderFrom, err := normalizeFolder(folderFrom)
+	if err != nil {
+		return err
+	}
+	folderTo, err = normalizeFolder(folderTo)
 	if err != nil {
 		return err
 	}
 
-	exists, err := d.isItemInFolderInTxn(txn, cid, folderFrom)
+	exists, err := d.IsFolderPathExists(folderFrom.IPNSAddress, folderFrom.Path)
 	if err != nil {
 		return err
 	}
 	if !exists {
-		// Just skip if folder isn't exist
-		return nil
+		return ErrFolderNotExists
 	}
 
-	exists, err = d.isFolderPathExistsInTxn(txn, folderTo.IPNSAddress, folderTo.Path)
+	err = d.checkIPNS(folderTo.IPNSAddress)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		// Just skip if folder isn't exist
-		return nil
-	}
 
-	// Copy folder_item::[ipns]::[folderPath]::[cid]
-	k := dbKey{"folder_item", folderTo.IPNSAddress, folderTo.Path, cid}
-	err = txn.Set(k.Bytes(), []byte(cid))
+	err = d.update(func(txn *badger.Txn) error {
+		return d.copyFolderInTxn(txn, folderFrom, folderTo)
+	})
 	if err != nil {
 		return err
 	}
 
 	if !copy {
-		k = dbKey{"folder_item", folderFrom.IPNSAddress, folderFrom.Path, cid}
-		err = txn.Delete(k.Bytes())
+		// Moving folder. Delete from folder
+		err = d.DelFolder(folderFrom)
 		if err != nil {
 			return err
 		}
 	}
 
-	// Copy item_folder::[cid]::[ipns]::[folderPath]
-	k = dbKey{"item_folder", cid, folderTo.IPNSAddress, folderTo.Path}
-	err = txn.Set(k.Bytes(), []byte(folderTo.Path))
+	return nil
+}
+
+func (d *Datastore) copyFolderInTxn(txn *badger.Txn, folderFrom, folderTo *Folder) error {
+	// Copying a folder into its own subtree would never end
+	if folderFrom.IPNSAddress == folderTo.IPNSAddress && isPathUnder(folderTo.Path, folderFrom.Path) {
+		return ErrFolderCycle
+	}
+
+	// Copy / move folder
+	folderToExists, err := d.isFolderPathExistsInTxn(txn, folderTo.IPNSAddress, folderTo.Path)
 	if err != nil {
 		return err
 	}
 
-	if !copy {
-		k = dbKey{"item_folder", cid, folderFrom.IPNSAddress, folderFrom.Path, cid}
-		err = txn.Delete(k.Bytes())
+	if !folderToExists {
+		err = d.createOrUpdateFolderInTxn(txn, folderTo)
 		if err != nil {
 			return err
 		}
 	}
 
-	if folderFrom.IPNSAddress != folderTo.IPNSAddress {
-		// Different collection. Add item to the To collection
-		// collection_item::[ipns]::[cid]
-		k = dbKey{"collection_item", folderTo.IPNSAddress, cid}
-		err = txn.Set(k.Bytes(), []byte(cid))
+	// Copy / move items in folder
+	cids, err := d.readFolderItemsInTxn(txn, folderFrom)
+	if err != nil {
+		return err
+	}
+
+	for _, cid := range cids {
+		err := d.moveOrCopyItemInTxn(txn, cid, folderFrom, folderTo, true)
 		if err != nil {
 			return err
 		}
-		// item_collection::[cid]::[ipns]
-		k = dbKey{"item_collection", cid, folderTo.IPNSAddress}
-		err = txn.Set(k.Bytes(), []byte(folderTo.IPNSAddress))
+	}
+
+	// A new folder keeps the manual order of items
+	if !folderToExists {
+		order, err := d.readPathListInTxn(txn, dbKey{"folder_order", folderFrom.IPNSAddress, folderFrom.Path})
 		if err != nil {
 			return err
 		}
-
-		if !copy {
-			// Remove item from old collection
-
-			// collection_item::[ipns]::[cid]
-			k = dbKey{"collection_item", folderFrom.IPNSAddress, cid}
-			err = txn.Delete(k.Bytes())
-			if err != nil {
-				return err
-			}
-			// item_collection::[cid]::[ipns]
-			k = dbKey{"item_collection", cid, folderFrom.IPNSAddress}
-			err = txn.Delete(k.Bytes())
+		if order != nil {
+			err = d.writePathListInTxn(txn, dbKey{"folder_order", folderTo.IPNSAddress, folderTo.Path}, order)
 			if err != nil {
 				return err
 			}
 		}
 	}
 
+	// Copy / move children folder
+	children, err := d.readPathListInTxn(txn, dbKey{"folder", folderFrom.IPNSAddress, folderFrom.Path, "children"})
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		subFromFolder := &Folder{IPNSAddress: folderFrom.IPNSAddress, Path: child}
+		subToPath := joinFolderPath(folderTo.Path, subFromFolder.Basename())
+		subToFolder := &Folder{IPNSAddress: folderTo.IPNSAddress, Path: subToPath}
+
+		err := d.copyFolderInTxn(txn, subFromFolder, subToFolder)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// MoveOrCopyFolder moves or copies a folder to destination
-func (d *Datastore) MoveOrCopyFolder(folderFrom, folderTo *Folder, copy bool) error {
+// MoveFolder moves a folder and its children folders to a new path in the same collection, in one transaction.
+// Items stay in the moved folders and keep their other folder memberships. Links from and to the moved folders are kept.
+func (d *Datastore) MoveFolder(from, to *Folder) error {
+	if from.IPNSAddress != to.IPNSAddress {
+		panic("Invalid parameters.")
+	}
 
-	exists, err := d.IsFolderPathExists(folderFrom.IPNSAddress, folderFrom.Path)
+	from, err := normalizeFolder(from)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return ErrFolderNotExists
+
+	if from.Path == "" {
+		return ErrCantMoveRootFolder
 	}
 
-	err = d.checkIPNS(folderTo.IPNSAddress)
+	toPath, err := NormalizeFolderPath(to.Path)
 	if err != nil {
 		return err
 	}
 
-	err = d.db.Update(func(txn *badger.Txn) error {
-		return d.copyFolderInTxn(txn, folderFrom, folderTo)
+	return d.update(func(txn *badger.Txn) error {
+		return d.moveFolderInTxn(txn, from.IPNSAddress, from.Path, toPath)
 	})
+}
+
+func (d *Datastore) moveFolderInTxn(txn *badger.Txn, ipns, fromPath, toPath string) error {
+	exists, err := d.isFolderPathExistsInTxn(txn, ipns, fromPath)
 	if err != nil {
 		return err
 	}
+	if !exists {
+		return ErrFolderNotExists
+	}
 
-	if !copy {
-		// Moving folder. Delete from folder
-		err = d.DelFolder(folderFrom)
-		if err != nil {
-			return err
-		}
+	if isPathUnder(toPath, fromPath) {
+		return ErrFolderCycle
 	}
 
-	return nil
-}
+	exists, err = d.isFolderPathExistsInTxn(txn, ipns, toPath)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrFolderExists
+	}
 
-func (d *Datastore) copyFolderInTxn(txn *badger.Txn, folderFrom, folderTo *Folder) error {
+	to := &Folder{IPNSAddress: ipns, Path: toPath}
+	exists, err = d.isFolderPathExistsInTxn(txn, ipns, to.ParentPath())
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrParentFolderNotExists
+	}
 
-	// Copy / move folder
-	folderToExists, err := d.IsFolderPathExists(folderTo.IPNSAddress, folderTo.Path)
+	err = d.touchCollectionInTxn(txn, ipns)
 	if err != nil {
 		return err
 	}
 
-	if !folderToExists {
-		err = d.createOrUpdateFolderInTxn(txn, folderTo)
-		if err != nil {
-			return err
+	// New paths of the folder and its children folders
+	renames := make(map[string]string)
+	var paths []string
+	// folders::[ipns]::[folderPath]
+	for _, k := range d.readKeysInTxn(txn, dbKey{"folders", ipns, ""}) {
+		p := k[2]
+		paths = append(paths, p)
+		if isPathUnder(p, fromPath) {
+			renames[p] = toPath + strings.TrimPrefix(p, fromPath)
 		}
 	}
 
-	// Copy / move items in folder
-	cids, err := d.ReadFolderItems(folderFrom)
-	if err != nil {
-		return err
+	// Rename moved folders in children and links lists of all folders
+	for _, p := range paths {
+		for _, list := range []string{"children", "links"} {
+			k := dbKey{"folder", ipns, p, list}
+			listPaths, err := d.readPathListInTxn(txn, k)
+			if err != nil {
+				return err
+			}
+
+			changed := false
+			for i, lp := range listPaths {
+				if newPath, ok := renames[lp]; ok {
+					listPaths[i] = newPath
+					changed = true
+				}
+			}
+			if changed {
+				err = d.writePathListInTxn(txn, k, listPaths)
+				if err != nil {
+					return err
+				}
+			}
+		}
 	}
 
-	for _, cid := range cids {
-		err := d.moveOrCopyItemInTxn(txn, cid, folderFrom, folderTo, true)
+	for oldPath, newPath := range renames {
+		// folders::[ipns]::[folderPath]
+		err = txn.Delete(d.key(dbKey{"folders", ipns, oldPath}))
+		if err != nil {
+			return err
+		}
+		err = txn.Set(d.key(dbKey{"folders", ipns, newPath}), []byte(newPath))
 		if err != nil {
 			return err
 		}
-	}
 
-	// Copy / move children folder
-	children, err := d.ReadFolderChildren(folderFrom)
-	for _, child := range children {
-		subFromFolder := &Folder{IPNSAddress: folderFrom.IPNSAddress, Path: child}
-		subToPath := folderTo.Path + "/" + subFromFolder.Basename()
-		subToFolder := &Folder{IPNSAddress: folderTo.IPNSAddress, Path: subToPath}
+		// folder::[ipns]::[folderPath]::*
+		for _, k := range d.readKeysInTxn(txn, dbKey{"folder", ipns, oldPath, ""}) {
+			item, err := txn.Get(d.key(k))
+			if err != nil {
+				return err
+			}
+			v, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			err = txn.Delete(d.key(k))
+			if err != nil {
+				return err
+			}
+			nk := append(dbKey{"folder", ipns, newPath}, k[3:]...)
+			err = txn.Set(d.key(nk), v)
+			if err != nil {
+				return err
+			}
+		}
 
-		err := d.copyFolderInTxn(txn, subFromFolder, subToFolder)
+		// folder_order::[ipns]::[folderPath]
+		order, err := d.readPathListInTxn(txn, dbKey{"folder_order", ipns, oldPath})
 		if err != nil {
 			return err
 		}
+		if order != nil {
+			err = txn.Delete(d.key(dbKey{"folder_order", ipns, oldPath}))
+			if err != nil {
+				return err
+			}
+			err = d.writePathListInTxn(txn, dbKey{"folder_order", ipns, newPath}, order)
+			if err != nil {
+				return err
+			}
+		}
+
+		// folder_item::[ipns]::[folderPath]::[cid] and item_folder::[cid]::[ipns]::[folderPath]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"folder_item", ipns, oldPath, ""}) {
+			cid := k[3]
+			err = txn.Delete(d.key(k))
+			if err != nil {
+				return err
+			}
+			err = txn.Set(d.key(dbKey{"folder_item", ipns, newPath, cid}), []byte(cid))
+			if err != nil {
+				return err
+			}
+			err = txn.Delete(d.key(dbKey{"item_folder", cid, ipns, oldPath}))
+			if err != nil {
+				return err
+			}
+			err = txn.Set(d.key(dbKey{"item_folder", cid, ipns, newPath}), []byte(newPath))
+			if err != nil {
+				return err
+			}
+		}
 	}
-	return nil
+
+	// Move the folder from the old parent to the new parent.
+	// The old parent's children list already has the new path after renaming.
+	from := &Folder{IPNSAddress: ipns, Path: fromPath}
+	if from.ParentPath() != to.ParentPath() {
+		err = d.removeFromPathListInTxn(txn, dbKey{"folder", ipns, from.ParentPath(), "children"}, toPath)
+		if err != nil {
+			return err
+		}
+	}
+	// The new parent may have been a linked parent
+	err = d.removeFromPathListInTxn(txn, dbKey{"folder", ipns, toPath, "links"}, to.ParentPath())
+	if err != nil {
+		return err
+	}
+	pck := dbKey{"folder", ipns, to.ParentPath(), "children"}
+	children, err := d.readPathListInTxn(txn, pck)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if child == toPath {
+			return nil
+		}
+	}
+	return d.writePathListInTxn(txn, pck, append(children, toPath))
 }
 
 func (d *Datastore) IsCollectionEmpty(ipns string) (bool, error) {
@@ -1751,16 +4603,16 @@ func (d *Datastore) IsCollectionEmpty(ipns string) (bool, error) {
 	}
 
 	empty := true
-	p := dbKey{"collection_item", ipns}
-	err = d.db.View(func(txn *badger.Txn) error {
+	p := dbKey{"collection_item", ipns, ""}
+	err = d.view(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
 		it := txn.NewIterator(opts)
 		defer it.Close()
 
-		it.Seek(p.Bytes())
+		it.Seek(d.key(p))
 
-		if it.ValidForPrefix(p.Bytes()) {
+		if it.ValidForPrefix(d.key(p)) {
 			empty = false
 		}
 
@@ -1770,6 +4622,46 @@ func (d *Datastore) IsCollectionEmpty(ipns string) (bool, error) {
 	return empty, err
 }
 
+// IsFolderEmpty checks if a folder has no items and no child folders, including linked ones.
+func (d *Datastore) IsFolderEmpty(folder *Folder) (bool, error) {
+	folder, err := normalizeFolder(folder)
+	if err != nil {
+		return false, err
+	}
+
+	empty := true
+	err = d.view(func(txn *badger.Txn) error {
+		exists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, folder.Path)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrFolderNotExists
+		}
+
+		empty, err = d.isFolderEmptyInTxn(txn, folder.IPNSAddress, folder.Path)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return empty, nil
+}
+
+func (d *Datastore) isFolderEmptyInTxn(txn *badger.Txn, ipns, path string) (bool, error) {
+	// folder_item::[ipns]::[folderPath]::[cid]
+	if d.hasPrefixInTxn(txn, dbKey{"folder_item", ipns, path, ""}) {
+		return false, nil
+	}
+
+	children, err := d.readPathListInTxn(txn, dbKey{"folder", ipns, path, "children"})
+	if err != nil {
+		return false, err
+	}
+	return len(children) == 0, nil
+}
+
 // TODO: ListItems() SearchItems()
 // func (d *Datastore) ListItems(tags []Tag, ipns string) ([]string, error) {
 
@@ -3,10 +3,18 @@ package resource
 import (
 	"bytes"
 	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"testing/quick"
+	"time"
 
+	"github.com/dgraph-io/badger"
 	"github.com/thoas/go-funk"
 )
 
@@ -38,13 +46,72 @@ func TestDbKey(t *testing.T) {
 
 }
 
+func TestDbKeyRoundTrip(t *testing.T) {
+	keys := []dbKey{
+		{"hello", "world"},
+		{"tag", "a:b:c", "count"},
+		{"a::b", "c"},
+		{"a:", "b"},
+		{":a", "b"},
+		{"a", ":"},
+		{":::", "::", ""},
+		{"a", "", "", "b:"},
+		{"a:", "", ":b"},
+		{"a\\", "b"},
+		{"a\\:", "b"},
+		{"a\\:\\:b", "\\"},
+		{"a:", ""},
+		{""},
+	}
+	for _, k := range keys {
+		got := newDbKeyFromStr(k.String())
+		if !reflect.DeepEqual(got, k) {
+			t.Errorf("newDbKeyFromStr(%q) = %q; want %q", k.String(), []string(got), []string(k))
+		}
+	}
+
+	// Single inner ":" are kept as is, so tag keys don't change
+	if s := (dbKey{"tags", "a:b"}).String(); s != "tags::a:b" {
+		t.Errorf("dbKey string = %s; want tags::a:b", s)
+	}
+	// A part is still a prefix of the parts starting with it
+	if !strings.HasPrefix(dbKey{"tags", "a:b"}.String(), dbKey{"tags", "a:"}.String()) {
+		t.Error("dbKey of a tag prefix ending with \":\" should be a prefix of the tag key")
+	}
+
+	f := func(parts []string) bool {
+		if len(parts) == 0 {
+			return true
+		}
+		k := dbKey(parts)
+		return reflect.DeepEqual(newDbKeyFromStr(k.String()), k)
+	}
+	err := quick.Check(f, &quick.Config{
+		MaxCount: 10000,
+		Values: func(args []reflect.Value, r *rand.Rand) {
+			// Strings mostly made of ":" and "\\" to hit the escaping
+			parts := make([]string, r.Intn(4)+1)
+			for i := range parts {
+				b := make([]byte, r.Intn(6))
+				for j := range b {
+					b[j] = ":\\ab"[r.Intn(4)]
+				}
+				parts[i] = string(b)
+			}
+			args[0] = reflect.ValueOf(parts)
+		},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
 func TestDatastore(t *testing.T) {
 	ds, err := NewDatastore(dbPath)
-	defer ds.Close()
-
 	if err != nil {
-		t.Errorf("Unable to create Datastore. Error: %s", err)
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
 	}
+	defer ds.Close()
 	c := &Collection{
 		IPNSAddress: "test.com",
 		Name:        "Test Collection",
@@ -58,6 +125,23 @@ func TestDatastore(t *testing.T) {
 		t.Errorf("Unable to create Collection. Error: %s", err)
 	}
 
+	// CollectionExists
+	exists, err := ds.CollectionExists(c.IPNSAddress)
+	if err != nil {
+		t.Errorf("Unable to check if Collection exists. Error: %s", err)
+	}
+	if !exists {
+		t.Error("Collection exists but false returns.")
+	}
+
+	exists, err = ds.CollectionExists("notexists.com")
+	if err != nil {
+		t.Errorf("Unable to check if Collection exists. Error: %s", err)
+	}
+	if exists {
+		t.Error("Collection doesn't exist but true returns.")
+	}
+
 	// IsCollectionEmpty
 	empty, err := ds.IsCollectionEmpty(c.IPNSAddress)
 	if err != nil {
@@ -193,6 +277,22 @@ func TestDatastore(t *testing.T) {
 		t.Error("Collection is in collection list.")
 	}
 
+	// ListMyCollections
+	cs, err = ds.ListMyCollections()
+	if err != nil {
+		t.Errorf("Unable to list my collections. Error: %v", err)
+	}
+
+	found = false
+	for _, ci := range cs {
+		if c.IPNSAddress == ci.IPNSAddress && ci.Name == c.Name && ci.IsMine {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Collection is not in my collection list.")
+	}
+
 	// Update collection
 	c.Name = "Test Collection2"
 	c.IsMine = false
@@ -215,6 +315,30 @@ func TestDatastore(t *testing.T) {
 		t.Error("Collection is not mine but true returns.")
 	}
 
+	cs, err = ds.ListMyCollections()
+	if err != nil {
+		t.Errorf("Unable to list my collections. Error: %v", err)
+	}
+	for _, ci := range cs {
+		if c.IPNSAddress == ci.IPNSAddress {
+			t.Error("Collection is not mine but in my collection list.")
+		}
+	}
+
+	cs, err = ds.ListCollections(FilterNone, FilterAny)
+	if err != nil {
+		t.Errorf("Unable to list collections. Error: %v", err)
+	}
+	found = false
+	for _, ci := range cs {
+		if c.IPNSAddress == ci.IPNSAddress {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Collection is not in others collection list.")
+	}
+
 	// Create Item
 	tag3 := Tag{"tag3"}
 	item := &Item{
@@ -231,6 +355,15 @@ func TestDatastore(t *testing.T) {
 		t.Errorf("Unable to create Item. Error: %s", err)
 	}
 
+	// ItemExists
+	exists, err = ds.ItemExists(item.CID)
+	if err != nil {
+		t.Errorf("Unable to check if Item exists. Error: %s", err)
+	}
+	if !exists {
+		t.Error("Item exists but false returns.")
+	}
+
 	// Read Item
 	itemActual, err := ds.ReadItem(item.CID)
 	if err != nil {
@@ -283,6 +416,25 @@ func TestDatastore(t *testing.T) {
 		t.Errorf("Actual read item is not the same as wanted.")
 	}
 
+	if itemActual.PreviewCID != "" {
+		t.Errorf("Item without preview should read back an empty PreviewCID.")
+	}
+
+	// Set preview
+	item.PreviewCID = "QmPreviewCID"
+	err = ds.CreateOrUpdateItem(item)
+	if err != nil {
+		t.Errorf("Unable to update Item. Error: %s", err)
+	}
+
+	itemActual, err = ds.ReadItem(item.CID)
+	if err != nil {
+		t.Errorf("Unable to read Item. Error: %s", err)
+	}
+	if itemActual.PreviewCID != item.PreviewCID {
+		t.Errorf("Item PreviewCID = %s; want %s", itemActual.PreviewCID, item.PreviewCID)
+	}
+
 	hasTag, err := ds.HasTag(item.CID, tag3)
 	if err != nil {
 		t.Errorf("Unable to check if Item has Tag. Error: %s", err)
@@ -435,6 +587,14 @@ func TestDatastore(t *testing.T) {
 		t.Errorf("Item is not deleted.")
 	}
 
+	exists, err = ds.ItemExists(item.CID)
+	if err != nil {
+		t.Errorf("Unable to check if Item exists. Error: %s", err)
+	}
+	if exists {
+		t.Error("Item is deleted but true returns.")
+	}
+
 	// Delete collection
 	err = ds.DelCollection(c.IPNSAddress)
 	if err != nil {
@@ -450,11 +610,10 @@ func TestDatastore(t *testing.T) {
 
 func TestSearchTags(t *testing.T) {
 	ds, err := NewDatastore(dbPath)
-	defer ds.Close()
-
 	if err != nil {
-		t.Errorf("Unable to create Datastore. Error: %s", err)
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
 	}
+	defer ds.Close()
 
 	tag100_1 := Tag{"tag100a", "tag100b", "tag100c"}
 	tag100_2 := Tag{"tag100a", "tag100d"}
@@ -553,11 +712,10 @@ func TestSearchTags(t *testing.T) {
 
 func TestFolders(t *testing.T) {
 	ds, err := NewDatastore(dbPath)
-	defer ds.Close()
-
 	if err != nil {
-		t.Errorf("Unable to create Datastore. Error: %s", err)
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
 	}
+	defer ds.Close()
 
 	ipns := "test.com"
 
@@ -639,6 +797,9 @@ func TestFolders(t *testing.T) {
 	if !funk.ContainsString(children, "folder1/folder3") {
 		t.Error("folder3 should be in folder1's children")
 	}
+	if folder1Actual.ChildCount != len(children) {
+		t.Errorf("folder1 ChildCount = %d; want %d", folder1Actual.ChildCount, len(children))
+	}
 
 	folder2Actual, err := ds.ReadFolder(ipns, "folder1/folder2")
 	if err != nil {
@@ -784,13 +945,3725 @@ func TestFolders(t *testing.T) {
 		t.Errorf("Folder4 should be deleted but not.")
 	}
 
+	// item1 is still in folder1copy
 	inCollection, err := ds.IsItemInCollection(item1.CID, c.IPNSAddress)
 	if err != nil {
 		t.Errorf("Unable to check if item1 is in collection. Error: %s", err)
 	}
 
+	if !inCollection {
+		t.Errorf("Item1 should be in collection.")
+	}
+
+	err = ds.DelFolder(folder1CopyActual)
+	if err != nil {
+		t.Errorf("Unable to delete folder1copy. Error: %s", err)
+	}
+
+	inCollection, err = ds.IsItemInCollection(item1.CID, c.IPNSAddress)
+	if err != nil {
+		t.Errorf("Unable to check if item1 is in collection. Error: %s", err)
+	}
+
 	if inCollection {
 		t.Errorf("Item1 should not be in collection.")
 	}
 
 }
+
+func TestDropAll(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	c := &Collection{IPNSAddress: "dropall.com", Name: "DropAll Collection"}
+	err = ds.CreateOrUpdateCollection(c)
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+
+	err = ds.DropAll()
+	if err != nil {
+		t.Errorf("Unable to drop all. Error: %s", err)
+	}
+
+	_, err = ds.ReadCollection(c.IPNSAddress)
+	if err != ErrIPNSNotFound {
+		t.Errorf("Collection should be dropped.")
+	}
+
+	// Datastore should still work after DropAll
+	err = ds.CreateOrUpdateCollection(c)
+	if err != nil {
+		t.Errorf("Unable to create Collection after DropAll. Error: %s", err)
+	}
+
+	_, err = ds.ReadCollection(c.IPNSAddress)
+	if err != nil {
+		t.Errorf("Unable to read Collection after DropAll. Error: %s", err)
+	}
+}
+
+func TestLinkFolder(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "link.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Link Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+
+	for _, path := range []string{"a", "a/x", "b", "c"} {
+		err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: path})
+		if err != nil {
+			t.Errorf("Unable to create folder %s. Error: %s", path, err)
+		}
+	}
+
+	err = ds.LinkFolder(ipns, "a", "a/x")
+	if err != ErrFolderCycle {
+		t.Errorf("Linking a folder into its own subtree should fail with ErrFolderCycle. Actual %v", err)
+	}
+
+	err = ds.LinkFolder(ipns, "a/x", "b")
+	if err != nil {
+		t.Errorf("Unable to link a/x to b. Error: %s", err)
+	}
+	err = ds.LinkFolder(ipns, "a/x", "c")
+	if err != nil {
+		t.Errorf("Unable to link a/x to c. Error: %s", err)
+	}
+
+	children, err := ds.ReadFolderChildren(&Folder{IPNSAddress: ipns, Path: "b"})
+	if err != nil {
+		t.Errorf("Unable to read children of b. Error: %s", err)
+	}
+	if !funk.ContainsString(children, "a/x") {
+		t.Error("a/x should be in b's children")
+	}
+
+	// Deleting a linking parent only removes the link
+	err = ds.DelFolder(&Folder{IPNSAddress: ipns, Path: "b"})
+	if err != nil {
+		t.Errorf("Unable to delete b. Error: %s", err)
+	}
+	exists, err := ds.IsFolderPathExists(ipns, "a/x")
+	if err != nil {
+		t.Errorf("Unable to check if a/x exists. Error: %s", err)
+	}
+	if !exists {
+		t.Error("a/x should not be deleted with b")
+	}
+
+	err = ds.UnlinkFolder(ipns, "a/x", "c")
+	if err != nil {
+		t.Errorf("Unable to unlink a/x from c. Error: %s", err)
+	}
+	children, err = ds.ReadFolderChildren(&Folder{IPNSAddress: ipns, Path: "c"})
+	if err != nil {
+		t.Errorf("Unable to read children of c. Error: %s", err)
+	}
+	if funk.ContainsString(children, "a/x") {
+		t.Error("a/x should not be in c's children")
+	}
+
+	err = ds.UnlinkFolder(ipns, "a/x", "c")
+	if err != ErrFolderNotLinked {
+		t.Errorf("Unlinking twice should fail with ErrFolderNotLinked. Actual %v", err)
+	}
+}
+
+func TestDelFolderLinkedChildren(t *testing.T) {
+	path := filepath.Join(testdataDir, "del_linked.db")
+	_ = os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	ds, err := NewDatastore(path)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "dellinked.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Del Linked"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	for _, p := range []string{"a", "a/x", "a/x/sub", "a/y", "b", "b/x", "c"} {
+		err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: p})
+		if err != nil {
+			t.Errorf("Unable to create folder %s. Error: %s", p, err)
+		}
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmDelLinked1", Name: "Del Linked"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	err = ds.AddItemToFolder("QmDelLinked1", &Folder{IPNSAddress: ipns, Path: "a/x/sub"})
+	if err != nil {
+		t.Errorf("Unable to add item to folder. Error: %s", err)
+	}
+	// a/y is deleted with a, b already has a child named x, so a/x is moved under c
+	for _, parent := range []string{"a/y", "b", "c"} {
+		err = ds.LinkFolder(ipns, "a/x", parent)
+		if err != nil {
+			t.Errorf("Unable to link a/x to %s. Error: %s", parent, err)
+		}
+	}
+
+	err = ds.DelFolder(&Folder{IPNSAddress: ipns, Path: "a"})
+	if err != nil {
+		t.Errorf("Unable to delete a. Error: %s", err)
+	}
+
+	for p, want := range map[string]bool{"a": false, "a/x": false, "a/y": false, "c/x": true, "c/x/sub": true} {
+		exists, err := ds.IsFolderPathExists(ipns, p)
+		if err != nil {
+			t.Errorf("Unable to check if %s exists. Error: %s", p, err)
+		}
+		if exists != want {
+			t.Errorf("%s exists = %t; want %t", p, exists, want)
+		}
+	}
+	_, ancestors, err := ds.ResolveFolder(ipns, "c/x/sub")
+	if err != nil {
+		t.Errorf("Moved folder should have its ancestors. Error: %s", err)
+	} else if len(ancestors) != 3 {
+		t.Errorf("Ancestors of c/x/sub = %v; want root, c and c/x", ancestors)
+	}
+
+	children, err := ds.ReadFolderChildren(&Folder{IPNSAddress: ipns, Path: "b"})
+	if err != nil {
+		t.Errorf("Unable to read children of b. Error: %s", err)
+	}
+	sort.Strings(children)
+	if !reflect.DeepEqual(children, []string{"b/x", "c/x"}) {
+		t.Errorf("Children of b = %v; want [b/x c/x]", children)
+	}
+	err = ds.UnlinkFolder(ipns, "c/x", "c")
+	if err != ErrFolderNotLinked {
+		t.Errorf("c should be the parent of c/x, not a link. Actual %v", err)
+	}
+
+	items, err := ds.ReadFolderItems(&Folder{IPNSAddress: ipns, Path: "c/x/sub"})
+	if err != nil {
+		t.Errorf("Unable to read folder items. Error: %s", err)
+	}
+	if !reflect.DeepEqual(items, []string{"QmDelLinked1"}) {
+		t.Errorf("Items of c/x/sub = %v; want [QmDelLinked1]", items)
+	}
+
+	incs, err := ds.Verify()
+	if err != nil {
+		t.Errorf("Unable to verify Datastore. Error: %s", err)
+	}
+	if len(incs) != 0 {
+		t.Errorf("Deleting a folder should leave no stale links. Actual %v", incs)
+	}
+
+	// A kept folder which can't be moved under any of its linked parents fails the deletion
+	for _, p := range []string{"p", "p/z", "q", "q/z"} {
+		err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: p})
+		if err != nil {
+			t.Errorf("Unable to create folder %s. Error: %s", p, err)
+		}
+	}
+	err = ds.LinkFolder(ipns, "p/z", "q")
+	if err != nil {
+		t.Errorf("Unable to link p/z to q. Error: %s", err)
+	}
+	err = ds.DelFolder(&Folder{IPNSAddress: ipns, Path: "p"})
+	if err != ErrFolderExists {
+		t.Errorf("Deleting p should fail with ErrFolderExists. Actual %v", err)
+	}
+	exists, err := ds.IsFolderPathExists(ipns, "p/z")
+	if err != nil {
+		t.Errorf("Unable to check if p/z exists. Error: %s", err)
+	}
+	if !exists {
+		t.Error("A failed deletion should not change anything")
+	}
+}
+
+func TestCreateFolderNormalizesPath(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "normalize.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Normalize Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+
+	err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: "a/"})
+	if err != nil {
+		t.Errorf("Unable to create folder a/. Error: %s", err)
+	}
+	err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: "a//b"})
+	if err != nil {
+		t.Errorf("Unable to create folder a//b. Error: %s", err)
+	}
+
+	exists, err := ds.IsFolderPathExists(ipns, "a/b")
+	if err != nil {
+		t.Errorf("Unable to check if a/b exists. Error: %s", err)
+	}
+	if !exists {
+		t.Error("a/b should exist.")
+	}
+
+	err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: "../a"})
+	if err != ErrInvalidFolderPath {
+		t.Errorf("Creating ../a should fail with ErrInvalidFolderPath. Actual %v", err)
+	}
+
+	// The argument is left as it is
+	folder := &Folder{IPNSAddress: ipns, Path: "c//"}
+	err = ds.CreateOrUpdateFolder(folder)
+	if err != nil {
+		t.Errorf("Unable to create folder c//. Error: %s", err)
+	}
+	if folder.Path != "c//" {
+		t.Errorf("CreateOrUpdateFolder should not change folder.Path. Actual %q", folder.Path)
+	}
+
+	// Other folder methods normalize paths the same way
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmNormalize1", Name: "Normalize"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	err = ds.AddItemToFolder("QmNormalize1", &Folder{IPNSAddress: ipns, Path: "a//b/"})
+	if err != nil {
+		t.Errorf("Unable to add item to a//b/. Error: %s", err)
+	}
+	f, err := ds.ReadFolder(ipns, "a/b/")
+	if err != nil {
+		t.Errorf("Unable to read folder a/b/. Error: %s", err)
+	} else if f.Path != "a/b" || f.ItemCount != 1 {
+		t.Errorf("Wrong folder a/b: %+v", f)
+	}
+	err = ds.LinkFolder(ipns, "a/b/", "c/")
+	if err != nil {
+		t.Errorf("Unable to link a/b/ to c/. Error: %s", err)
+	}
+	children, err := ds.ReadFolderChildren(&Folder{IPNSAddress: ipns, Path: "c/"})
+	if err != nil {
+		t.Errorf("Unable to read children of c/. Error: %s", err)
+	}
+	if !reflect.DeepEqual(children, []string{"a/b"}) {
+		t.Errorf("Children of c = %v; want [a/b]", children)
+	}
+	err = ds.MoveOrCopyFolder(&Folder{IPNSAddress: ipns, Path: "a//"}, &Folder{IPNSAddress: ipns, Path: "d/"}, false)
+	if err != nil {
+		t.Errorf("Unable to move a// to d/. Error: %s", err)
+	}
+	for p, want := range map[string]bool{"a": false, "a/b": false, "d": true, "d/b": true} {
+		exists, err := ds.IsFolderPathExists(ipns, p)
+		if err != nil {
+			t.Errorf("Unable to check if %s exists. Error: %s", p, err)
+		}
+		if exists != want {
+			t.Errorf("%s exists = %t; want %t", p, exists, want)
+		}
+	}
+	err = ds.DelFolder(&Folder{IPNSAddress: ipns, Path: "d//b/"})
+	if err != nil {
+		t.Errorf("Unable to delete d//b/. Error: %s", err)
+	}
+	_, err = ds.ReadFolder(ipns, "d/b")
+	if err != ErrFolderNotExists {
+		t.Errorf("d/b should be deleted. Actual %v", err)
+	}
+	_, err = ds.ReadFolder(ipns, "../d")
+	if err != ErrInvalidFolderPath {
+		t.Errorf("Reading ../d should fail with ErrInvalidFolderPath. Actual %v", err)
+	}
+}
+
+func TestCopyFolderIntoRoot(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	for _, ipns := range []string{"copyroot.com", "copyroot2.com"} {
+		err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: ipns})
+		if err != nil {
+			t.Errorf("Unable to create Collection. Error: %s", err)
+		}
+	}
+	for _, p := range []string{"x", "x/y", "x/y/z"} {
+		err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: "copyroot.com", Path: p})
+		if err != nil {
+			t.Errorf("Unable to create folder %s. Error: %s", p, err)
+		}
+	}
+
+	err = ds.MoveOrCopyFolder(&Folder{IPNSAddress: "copyroot.com", Path: "x"}, &Folder{IPNSAddress: "copyroot2.com"}, false)
+	if err != nil {
+		t.Errorf("Unable to move x into root. Error: %s", err)
+	}
+	for p, want := range map[string]bool{"y": true, "y/z": true, "/y": false} {
+		exists, err := ds.IsFolderPathExists("copyroot2.com", p)
+		if err != nil && err != ErrInvalidFolderPath {
+			t.Errorf("Unable to check if %s exists. Error: %s", p, err)
+		}
+		if exists != want {
+			t.Errorf("%s exists = %t; want %t", p, exists, want)
+		}
+	}
+	exists, err := ds.IsFolderPathExists("copyroot.com", "x")
+	if err != nil {
+		t.Errorf("Unable to check if x exists. Error: %s", err)
+	}
+	if exists {
+		t.Error("Moved folder x should be deleted.")
+	}
+}
+
+func TestCreateTopLevelFolderWithoutRoot(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "noroot.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "No Root Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+
+	// Simulate a collection whose root folder doesn't exist yet
+	err = ds.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(dbKey{"folders", ipns, ""}.Bytes())
+	})
+	if err != nil {
+		t.Fatalf("Unable to delete root folder. Error: %s", err)
+	}
+
+	err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: "top"})
+	if err != nil {
+		t.Errorf("Unable to create top-level folder. Error: %s", err)
+	}
+
+	root, err := ds.ReadFolder(ipns, "")
+	if err != nil {
+		t.Errorf("Root folder should be created. Error: %s", err)
+	}
+
+	children, err := ds.ReadFolderChildren(root)
+	if err != nil {
+		t.Errorf("Unable to read children of root. Error: %s", err)
+	}
+	if !funk.ContainsString(children, "top") {
+		t.Error("top should be in root's children")
+	}
+
+	err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: "missing/child"})
+	if err != ErrParentFolderNotExists {
+		t.Errorf("Creating a folder without parent should fail with ErrParentFolderNotExists. Actual %v", err)
+	}
+}
+
+func TestCreateFolderAll(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "mkdir.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Mkdir Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+
+	err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: "a"})
+	if err != nil {
+		t.Errorf("Unable to create folder a. Error: %s", err)
+	}
+
+	err = ds.CreateFolderAll(ipns, "a/b/c")
+	if err != nil {
+		t.Errorf("Unable to create folder a/b/c. Error: %s", err)
+	}
+
+	for _, path := range []string{"a", "a/b", "a/b/c"} {
+		exists, err := ds.IsFolderPathExists(ipns, path)
+		if err != nil {
+			t.Errorf("Unable to check if %s exists. Error: %s", path, err)
+		}
+		if !exists {
+			t.Errorf("%s should exist.", path)
+		}
+	}
+
+	children, err := ds.ReadFolderChildren(&Folder{IPNSAddress: ipns, Path: "a/b"})
+	if err != nil {
+		t.Errorf("Unable to read children of a/b. Error: %s", err)
+	}
+	if len(children) != 1 || children[0] != "a/b/c" {
+		t.Errorf("Children of a/b = %v; want [a/b/c]", children)
+	}
+
+	children, err = ds.ReadFolderChildren(&Folder{IPNSAddress: ipns, Path: ""})
+	if err != nil {
+		t.Errorf("Unable to read children of root. Error: %s", err)
+	}
+	if len(children) != 1 || children[0] != "a" {
+		t.Errorf("Children of root = %v; want [a]", children)
+	}
+}
+
+func TestAddTagToItems(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	tag := Tag{"bulk", "add"}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmBulkAdd1", Name: "Bulk Add 1", Tags: []Tag{tag}})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmBulkAdd2", Name: "Bulk Add 2"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+
+	err = ds.AddTagToItems([]string{"QmBulkAdd1", "QmBulkAdd2", "QmBulkAddMissing"}, tag)
+	notFound, ok := err.(*CIDsNotFoundError)
+	if !ok {
+		t.Fatalf("AddTagToItems should return CIDsNotFoundError. Actual %v", err)
+	}
+	if len(notFound.CIDs) != 1 || notFound.CIDs[0] != "QmBulkAddMissing" {
+		t.Errorf("Missing CIDs = %v; want [QmBulkAddMissing]", notFound.CIDs)
+	}
+
+	has, err := ds.HasTag("QmBulkAdd2", tag)
+	if err != nil {
+		t.Errorf("Unable to check tag. Error: %s", err)
+	}
+	if !has {
+		t.Error("QmBulkAdd2 should have the tag.")
+	}
+
+	counts, err := ds.ReadTagItemCount([]Tag{tag})
+	if err != nil {
+		t.Errorf("Unable to read tag item count. Error: %s", err)
+	}
+	if counts[0] != 2 {
+		t.Errorf("Tag item count = %d; want 2", counts[0])
+	}
+}
+
+func TestRemoveTagFromItems(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	tag := Tag{"bulk", "remove"}
+	other := Tag{"bulk", "remove", "other"}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmBulkRemove1", Name: "Bulk Remove 1", Tags: []Tag{tag, other}})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmBulkRemove2", Name: "Bulk Remove 2", Tags: []Tag{tag}})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmBulkRemove3", Name: "Bulk Remove 3", Tags: []Tag{other}})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+
+	err = ds.RemoveTagFromItems([]string{"QmBulkRemove1", "QmBulkRemove3", "QmBulkRemoveMissing"}, tag)
+	if err != nil {
+		t.Errorf("Unable to remove tag from items. Error: %s", err)
+	}
+
+	counts, err := ds.ReadTagItemCount([]Tag{tag, other})
+	if err != nil {
+		t.Errorf("Unable to read tag item count. Error: %s", err)
+	}
+	if counts[0] != 1 || counts[1] != 2 {
+		t.Errorf("Tag item counts = %v; want [1 2]", counts)
+	}
+
+	has, err := ds.HasTag("QmBulkRemove1", tag)
+	if err != nil {
+		t.Errorf("Unable to check tag. Error: %s", err)
+	}
+	if has {
+		t.Error("QmBulkRemove1 should not have the tag.")
+	}
+}
+
+func TestFoldersWithout(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "without.com"
+	cid := "QmWithout1"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Without Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: "Without Item"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	err = ds.CreateFolderAll(ipns, "b/c")
+	if err != nil {
+		t.Errorf("Unable to create folders. Error: %s", err)
+	}
+	err = ds.CreateFolderAll(ipns, "a")
+	if err != nil {
+		t.Errorf("Unable to create folders. Error: %s", err)
+	}
+	err = ds.AddItemToFolder(cid, &Folder{IPNSAddress: ipns, Path: "b"})
+	if err != nil {
+		t.Errorf("Unable to add item to folder. Error: %s", err)
+	}
+
+	paths, err := ds.FoldersWithout(cid, ipns)
+	if err != nil {
+		t.Errorf("Unable to list folders without item. Error: %s", err)
+	}
+	if !reflect.DeepEqual(paths, []string{"", "a", "b/c"}) {
+		t.Errorf("FoldersWithout = %v; want [ a b/c]", paths)
+	}
+}
+
+func TestReadCollectionStats(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "stats.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Stats Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+
+	items := []*Item{
+		{CID: "QmStats1", Name: "Stats 1", Size: 100, Tags: []Tag{{"stats", "a"}, {"stats", "b"}}},
+		{CID: "QmStats2", Name: "Stats 2", Size: 20, Tags: []Tag{{"stats", "a"}}},
+		{CID: "QmStats3", Name: "Stats 3"},
+	}
+	for _, item := range items {
+		err = ds.CreateOrUpdateItem(item)
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+		err = ds.AddItemToCollection(item.CID, ipns)
+		if err != nil {
+			t.Errorf("Unable to add item to collection. Error: %s", err)
+		}
+	}
+
+	itemActual, err := ds.ReadItem("QmStats1")
+	if err != nil {
+		t.Errorf("Unable to read item. Error: %s", err)
+	}
+	if itemActual.Size != 100 {
+		t.Errorf("Item Size = %d; want 100", itemActual.Size)
+	}
+
+	stats, err := ds.ReadCollectionStats(ipns)
+	if err != nil {
+		t.Fatalf("Unable to read collection stats. Error: %s", err)
+	}
+	expected := &CollectionStats{
+		ItemCount: 3,
+		TotalSize: 120,
+		TagCounts: map[string]uint{"stats:a": 2, "stats:b": 1},
+	}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("ReadCollectionStats = %+v; want %+v", stats, expected)
+	}
+
+	_, err = ds.ReadCollectionStats("missing.stats.com")
+	if err != ErrIPNSNotFound {
+		t.Errorf("ReadCollectionStats of a missing collection should return ErrIPNSNotFound. Actual %v", err)
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+
+	cid := "QmNormalizeTag1"
+	err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: "Normalize Tag", Tags: []Tag{{"Movie", "Drama"}}})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	err = ds.AddItemTag(cid, Tag{"movie", "drama"})
+	if err != nil {
+		t.Errorf("Unable to add tag. Error: %s", err)
+	}
+	err = ds.AddItemTag(cid, Tag{" MOVIE ", "drama "})
+	if err != nil {
+		t.Errorf("Unable to add tag. Error: %s", err)
+	}
+
+	item, err := ds.ReadItem(cid)
+	if err != nil {
+		t.Errorf("Unable to read item. Error: %s", err)
+	}
+	if len(item.Tags) != 1 || !item.Tags[0].Equals(Tag{"movie", "drama"}) {
+		t.Errorf("Item tags = %v; want [movie:drama]", item.Tags)
+	}
+
+	counts, err := ds.ReadTagItemCount([]Tag{{"Movie", "Drama"}})
+	if err != nil {
+		t.Errorf("Unable to read tag item count. Error: %s", err)
+	}
+	if counts[0] != 1 {
+		t.Errorf("Tag item count = %d; want 1", counts[0])
+	}
+
+	tags, err := ds.SearchTags("Movie:Dr")
+	if err != nil {
+		t.Errorf("Unable to search tags. Error: %s", err)
+	}
+	if len(tags) != 1 {
+		t.Errorf("SearchTags(Movie:Dr) = %v; want [movie:drama]", tags)
+	}
+	ds.Close()
+
+	// Strict-case tags
+	ds, err = NewDatastoreWithOptions(dbPath, DefaultOptions().WithNormalizeTags(false))
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	err = ds.AddItemTag(cid, Tag{"Movie", "Drama"})
+	if err != nil {
+		t.Errorf("Unable to add tag. Error: %s", err)
+	}
+	item, err = ds.ReadItem(cid)
+	if err != nil {
+		t.Errorf("Unable to read item. Error: %s", err)
+	}
+	if len(item.Tags) != 2 {
+		t.Errorf("Item tags = %v; want [Movie:Drama movie:drama]", item.Tags)
+	}
+}
+
+func TestRemoveLegacyMixedCaseTag(t *testing.T) {
+	path := filepath.Join(testdataDir, "legacy_tags.db")
+	_ = os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	// Tags saved before NormalizeTags was enabled keep their case
+	ds, err := NewDatastoreWithOptions(path, DefaultOptions().WithNormalizeTags(false))
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	legacy := Tag{"Legacy", "Mixed Case"}
+	for _, cid := range []string{"QmLegacyTag1", "QmLegacyTag2"} {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid, Tags: []Tag{legacy}})
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+	}
+	ds.Close()
+
+	ds, err = NewDatastore(path)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	err = ds.RemoveItemTag("QmLegacyTag1", legacy)
+	if err != nil {
+		t.Errorf("Unable to remove legacy tag. Error: %s", err)
+	}
+	item, err := ds.ReadItem("QmLegacyTag1")
+	if err != nil {
+		t.Errorf("Unable to read item. Error: %s", err)
+	} else if len(item.Tags) != 0 {
+		t.Errorf("Legacy tag should be removed. Actual %v", item.Tags)
+	}
+
+	err = ds.RemoveTagFromItems([]string{"QmLegacyTag2"}, Tag{"legacy", "mixed case"})
+	if err != nil {
+		t.Errorf("Unable to remove legacy tag. Error: %s", err)
+	}
+	item, err = ds.ReadItem("QmLegacyTag2")
+	if err != nil {
+		t.Errorf("Unable to read item. Error: %s", err)
+	} else if len(item.Tags) != 0 {
+		t.Errorf("Legacy tag should be removed by its normalized form. Actual %v", item.Tags)
+	}
+
+	// Removing a tag an item doesn't have leaves no phantom count behind
+	err = ds.RemoveItemTag("QmLegacyTag1", legacy)
+	if err != nil {
+		t.Errorf("Removing a missing tag should be a no-op. Error: %s", err)
+	}
+	err = ds.db.View(func(txn *badger.Txn) error {
+		for _, tagStr := range []string{legacy.String(), legacy.Normalized().String()} {
+			for _, k := range []dbKey{{"tag", tagStr, "count"}, {"tags", tagStr}} {
+				_, err := txn.Get(k.Bytes())
+				if err != badger.ErrKeyNotFound {
+					t.Errorf("%v should be deleted. Actual %v", k, err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Unable to read Datastore. Error: %s", err)
+	}
+}
+
+func TestDelItems(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "delitems.com"
+	folder := &Folder{IPNSAddress: ipns, Path: "folder"}
+	tag := Tag{"delitems"}
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "DelItems Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	err = ds.CreateOrUpdateFolder(folder)
+	if err != nil {
+		t.Errorf("Unable to create folder. Error: %s", err)
+	}
+
+	cids := []string{"QmDelItems1", "QmDelItems2", "QmDelItems3"}
+	for _, cid := range cids {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid, Tags: []Tag{tag}})
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+		err = ds.AddItemToCollection(cid, ipns)
+		if err != nil {
+			t.Errorf("Unable to add item to collection. Error: %s", err)
+		}
+		err = ds.AddItemToFolder(cid, folder)
+		if err != nil {
+			t.Errorf("Unable to add item to folder. Error: %s", err)
+		}
+	}
+
+	n, err := ds.DelItems([]string{"QmDelItems1", "QmDelItems2", "QmDelItemsMissing"})
+	notFound, ok := err.(*CIDsNotFoundError)
+	if !ok {
+		t.Fatalf("DelItems should return CIDsNotFoundError. Actual %v", err)
+	}
+	if len(notFound.CIDs) != 1 || notFound.CIDs[0] != "QmDelItemsMissing" {
+		t.Errorf("Missing CIDs = %v; want [QmDelItemsMissing]", notFound.CIDs)
+	}
+	if n != 2 {
+		t.Errorf("Deleted %d items; want 2", n)
+	}
+
+	items, err := ds.ReadCollectionItems(ipns)
+	if err != nil {
+		t.Errorf("Unable to read collection items. Error: %s", err)
+	}
+	if !reflect.DeepEqual(items, []string{"QmDelItems3"}) {
+		t.Errorf("Collection items = %v; want [QmDelItems3]", items)
+	}
+
+	items, err = ds.ReadFolderItems(folder)
+	if err != nil {
+		t.Errorf("Unable to read folder items. Error: %s", err)
+	}
+	if !reflect.DeepEqual(items, []string{"QmDelItems3"}) {
+		t.Errorf("Folder items = %v; want [QmDelItems3]", items)
+	}
+
+	counts, err := ds.ReadTagItemCount([]Tag{tag})
+	if err != nil {
+		t.Errorf("Unable to read tag item count. Error: %s", err)
+	}
+	if counts[0] != 1 {
+		t.Errorf("Tag item count = %d; want 1", counts[0])
+	}
+
+	incs, err := ds.Verify()
+	if err != nil {
+		t.Errorf("Unable to verify. Error: %s", err)
+	}
+	for _, inc := range incs {
+		if strings.Contains(inc.Key, "QmDelItems") {
+			t.Errorf("Inconsistency after DelItems: %s", inc)
+		}
+	}
+}
+
+func TestDelFolderPreview(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "delpreview.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Preview Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	err = ds.CreateFolderAll(ipns, "a/b")
+	if err != nil {
+		t.Errorf("Unable to create folders. Error: %s", err)
+	}
+	err = ds.CreateFolderAll(ipns, "c")
+	if err != nil {
+		t.Errorf("Unable to create folders. Error: %s", err)
+	}
+
+	// QmPreview1 is only in a/b, QmPreview2 is in a and c. Both are moved out of the root folder.
+	root := &Folder{IPNSAddress: ipns, Path: ""}
+	folders := map[string][]string{"QmPreview1": {"a/b"}, "QmPreview2": {"a", "c"}}
+	for cid, paths := range folders {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid})
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+		err = ds.AddItemToCollection(cid, ipns)
+		if err != nil {
+			t.Errorf("Unable to add item to collection. Error: %s", err)
+		}
+		for _, path := range paths {
+			err = ds.AddItemToFolder(cid, &Folder{IPNSAddress: ipns, Path: path})
+			if err != nil {
+				t.Errorf("Unable to add item to folder. Error: %s", err)
+			}
+		}
+		err = ds.RemoveItemFromFolder(cid, root)
+		if err != nil {
+			t.Errorf("Unable to remove item from root folder. Error: %s", err)
+		}
+	}
+
+	folder := &Folder{IPNSAddress: ipns, Path: "a"}
+	preview, err := ds.DelFolderPreview(folder)
+	if err != nil {
+		t.Fatalf("Unable to preview folder deletion. Error: %s", err)
+	}
+	expected := &DeletionPreview{Folders: []string{"a", "a/b"}, EvictedCIDs: []string{"QmPreview1"}}
+	if !reflect.DeepEqual(preview, expected) {
+		t.Errorf("DelFolderPreview = %+v; want %+v", preview, expected)
+	}
+
+	// Nothing is deleted by the preview
+	exists, err := ds.IsFolderPathExists(ipns, "a/b")
+	if err != nil {
+		t.Errorf("Unable to check folder. Error: %s", err)
+	}
+	if !exists {
+		t.Error("a/b should still exist after preview.")
+	}
+
+	err = ds.DelFolder(folder)
+	if err != nil {
+		t.Errorf("Unable to delete folder. Error: %s", err)
+	}
+	items, err := ds.ReadCollectionItems(ipns)
+	if err != nil {
+		t.Errorf("Unable to read collection items. Error: %s", err)
+	}
+	if !reflect.DeepEqual(items, []string{"QmPreview2"}) {
+		t.Errorf("Collection items after DelFolder = %v; want [QmPreview2]", items)
+	}
+}
+
+func TestSearchTagsRecent(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	cid := "QmRecentTag1"
+	err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: "Recent Tag"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	for _, tag := range []Tag{{"recent", "c"}, {"recent", "a"}, {"recent", "ab"}, {"recent", "c"}} {
+		err = ds.AddItemTag(cid, tag)
+		if err != nil {
+			t.Errorf("Unable to add tag. Error: %s", err)
+		}
+	}
+
+	tags, err := ds.SearchTagsRecent("recent", 0)
+	if err != nil {
+		t.Errorf("Unable to search tags. Error: %s", err)
+	}
+	var tagStrs []string
+	for _, tag := range tags {
+		tagStrs = append(tagStrs, tag.String())
+	}
+	if !reflect.DeepEqual(tagStrs, []string{"recent:c", "recent:ab", "recent:a"}) {
+		t.Errorf("SearchTagsRecent = %v; want [recent:c recent:ab recent:a]", tagStrs)
+	}
+
+	tags, err = ds.SearchTagsRecent("recent", 2)
+	if err != nil {
+		t.Errorf("Unable to search tags. Error: %s", err)
+	}
+	if len(tags) != 2 {
+		t.Errorf("SearchTagsRecent with limit 2 returned %d tags", len(tags))
+	}
+
+	// Deleting the last item of recent:a doesn't touch recent:ab
+	err = ds.RemoveItemTag(cid, Tag{"recent", "a"})
+	if err != nil {
+		t.Errorf("Unable to remove tag. Error: %s", err)
+	}
+	counts, err := ds.ReadTagItemCount([]Tag{{"recent", "ab"}})
+	if err != nil {
+		t.Errorf("Unable to read tag item count. Error: %s", err)
+	}
+	if counts[0] != 1 {
+		t.Errorf("Tag item count of recent:ab = %d; want 1", counts[0])
+	}
+}
+
+func TestMoveFolder(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "movefolder.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Move Folder Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	for _, path := range []string{"a/b/c", "x", "y", "z"} {
+		err = ds.CreateFolderAll(ipns, path)
+		if err != nil {
+			t.Errorf("Unable to create folder %s. Error: %s", path, err)
+		}
+	}
+	// x is linked into a/b, a/b is linked into y
+	err = ds.LinkFolder(ipns, "x", "a/b")
+	if err != nil {
+		t.Errorf("Unable to link folder. Error: %s", err)
+	}
+	err = ds.LinkFolder(ipns, "a/b", "y")
+	if err != nil {
+		t.Errorf("Unable to link folder. Error: %s", err)
+	}
+
+	cid := "QmMoveFolder1"
+	err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: "Move Folder Item"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	err = ds.AddItemToCollection(cid, ipns)
+	if err != nil {
+		t.Errorf("Unable to add item to collection. Error: %s", err)
+	}
+	err = ds.AddItemToFolder(cid, &Folder{IPNSAddress: ipns, Path: "a/b/c"})
+	if err != nil {
+		t.Errorf("Unable to add item to folder. Error: %s", err)
+	}
+
+	err = ds.MoveFolder(&Folder{IPNSAddress: ipns, Path: "a"}, &Folder{IPNSAddress: ipns, Path: "z/a2"})
+	if err != nil {
+		t.Fatalf("Unable to move folder. Error: %s", err)
+	}
+
+	for path, want := range map[string]bool{"a": false, "a/b/c": false, "z/a2": true, "z/a2/b": true, "z/a2/b/c": true} {
+		exists, err := ds.IsFolderPathExists(ipns, path)
+		if err != nil {
+			t.Errorf("Unable to check folder %s. Error: %s", path, err)
+		}
+		if exists != want {
+			t.Errorf("Folder %s exists = %v; want %v", path, exists, want)
+		}
+	}
+
+	isIn, err := ds.IsItemInFolder(cid, &Folder{IPNSAddress: ipns, Path: "z/a2/b/c"})
+	if err != nil {
+		t.Errorf("Unable to check if item is in folder. Error: %s", err)
+	}
+	if !isIn {
+		t.Error("Item should be in z/a2/b/c.")
+	}
+	isIn, err = ds.IsItemInFolder(cid, &Folder{IPNSAddress: ipns, Path: ""})
+	if err != nil {
+		t.Errorf("Unable to check if item is in folder. Error: %s", err)
+	}
+	if !isIn {
+		t.Error("Item should still be in the root folder.")
+	}
+
+	expectedChildren := map[string][]string{
+		"":       {"x", "y", "z"},
+		"z":      {"z/a2"},
+		"z/a2/b": {"z/a2/b/c", "x"},
+		"y":      {"z/a2/b"},
+	}
+	for path, want := range expectedChildren {
+		children, err := ds.ReadFolderChildren(&Folder{IPNSAddress: ipns, Path: path})
+		if err != nil {
+			t.Errorf("Unable to read children of %s. Error: %s", path, err)
+		}
+		if !reflect.DeepEqual(children, want) {
+			t.Errorf("Children of %s = %v; want %v", path, children, want)
+		}
+	}
+
+	folder, err := ds.ReadFolder(ipns, "z/a2/b/c")
+	if err != nil {
+		t.Errorf("Unable to read folder. Error: %s", err)
+	}
+	if folder.ItemCount != 1 || folder.ChildCount != 0 {
+		t.Errorf("z/a2/b/c ItemCount = %d, ChildCount = %d; want 1, 0", folder.ItemCount, folder.ChildCount)
+	}
+
+	err = ds.MoveFolder(&Folder{IPNSAddress: ipns, Path: "z/a2"}, &Folder{IPNSAddress: ipns, Path: "z/a2/b/a3"})
+	if err != ErrFolderCycle {
+		t.Errorf("Moving a folder into its descendant should return ErrFolderCycle. Actual %v", err)
+	}
+	err = ds.MoveFolder(&Folder{IPNSAddress: ipns, Path: "x"}, &Folder{IPNSAddress: ipns, Path: "y"})
+	if err != ErrFolderExists {
+		t.Errorf("Moving a folder onto an existing folder should return ErrFolderExists. Actual %v", err)
+	}
+
+	incs, err := ds.Verify()
+	if err != nil {
+		t.Errorf("Unable to verify. Error: %s", err)
+	}
+	for _, inc := range incs {
+		if strings.Contains(inc.Key, ipns) {
+			t.Errorf("Inconsistency after MoveFolder: %s", inc)
+		}
+	}
+}
+
+func TestReadRecentItems(t *testing.T) {
+	recentDbPath := filepath.Join(testdataDir, "recent.db")
+	_ = os.RemoveAll(recentDbPath)
+	defer os.RemoveAll(recentDbPath)
+
+	ds, err := NewDatastore(recentDbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, cid := range []string{"QmRecent1", "QmRecent2", "QmRecent3"} {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid, CreatedAt: base.Add(time.Duration(i) * time.Hour)})
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+	}
+	// Updating doesn't change the creation time
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmRecent1", Name: "Recent 1 Updated"})
+	if err != nil {
+		t.Errorf("Unable to update item. Error: %s", err)
+	}
+
+	item, err := ds.ReadItem("QmRecent1")
+	if err != nil {
+		t.Errorf("Unable to read item. Error: %s", err)
+	}
+	if !item.CreatedAt.Equal(base) {
+		t.Errorf("Item CreatedAt = %s; want %s", item.CreatedAt, base)
+	}
+
+	items, err := ds.ReadRecentItems(2)
+	if err != nil {
+		t.Errorf("Unable to read recent items. Error: %s", err)
+	}
+	if len(items) != 2 || items[0].CID != "QmRecent3" || items[1].CID != "QmRecent2" {
+		t.Errorf("Recent items = %v; want [QmRecent3 QmRecent2]", items)
+	}
+
+	err = ds.DelItem("QmRecent3")
+	if err != nil {
+		t.Errorf("Unable to delete item. Error: %s", err)
+	}
+	items, err = ds.ReadRecentItems(0)
+	if err != nil {
+		t.Errorf("Unable to read recent items. Error: %s", err)
+	}
+	if len(items) != 2 || items[0].CID != "QmRecent2" || items[1].CID != "QmRecent1" {
+		t.Errorf("Recent items = %v; want [QmRecent2 QmRecent1]", items)
+	}
+}
+
+func TestReadCollectionItemsPaged(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "paged.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Paged Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	var cids []string
+	for i := 0; i < 5; i++ {
+		cid := fmt.Sprintf("QmPaged%d", i)
+		cids = append(cids, cid)
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid})
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+		err = ds.AddItemToCollection(cid, ipns)
+		if err != nil {
+			t.Errorf("Unable to add item to collection. Error: %s", err)
+		}
+	}
+
+	var all []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("Too many pages.")
+		}
+		page, next, err := ds.ReadCollectionItemsPaged(ipns, cursor, 2)
+		if err != nil {
+			t.Fatalf("Unable to read page. Error: %s", err)
+		}
+		all = append(all, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if !reflect.DeepEqual(all, cids) {
+		t.Errorf("Paged items = %v; want %v", all, cids)
+	}
+}
+
+func TestCopyItemTags(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	tagA := Tag{"copytags", "a"}
+	tagB := Tag{"copytags", "b"}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmCopyTags1", Name: "Copy Tags 1", Tags: []Tag{tagA, tagB}})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmCopyTags2", Name: "Copy Tags 2", Tags: []Tag{tagA}})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+
+	err = ds.CopyItemTags("QmCopyTags1", "QmCopyTags2")
+	if err != nil {
+		t.Errorf("Unable to copy tags. Error: %s", err)
+	}
+
+	item, err := ds.ReadItem("QmCopyTags2")
+	if err != nil {
+		t.Errorf("Unable to read item. Error: %s", err)
+	}
+	if len(item.Tags) != 2 {
+		t.Errorf("Item tags = %v; want [copytags:a copytags:b]", item.Tags)
+	}
+
+	counts, err := ds.ReadTagItemCount([]Tag{tagA, tagB})
+	if err != nil {
+		t.Errorf("Unable to read tag item count. Error: %s", err)
+	}
+	if counts[0] != 2 || counts[1] != 2 {
+		t.Errorf("Tag item counts = %v; want [2 2]", counts)
+	}
+
+	err = ds.CopyItemTags("QmCopyTags1", "QmCopyTagsMissing")
+	if err != ErrCIDNotFound {
+		t.Errorf("Copying tags to a missing item should return ErrCIDNotFound. Actual %v", err)
+	}
+}
+
+func TestSetCollectionMine(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "setmine.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Set Mine", Description: "Set Mine Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: "folder"})
+	if err != nil {
+		t.Errorf("Unable to create folder. Error: %s", err)
+	}
+
+	err = ds.SetCollectionMine(ipns, true)
+	if err != nil {
+		t.Errorf("Unable to set collection mine. Error: %s", err)
+	}
+	c, err := ds.ReadCollection(ipns)
+	if err != nil {
+		t.Errorf("Unable to read collection. Error: %s", err)
+	}
+	if !c.IsMine || c.Name != "Set Mine" || c.Description != "Set Mine Collection" {
+		t.Errorf("Wrong collection after SetCollectionMine: %+v", c)
+	}
+	isMine := func() bool {
+		cs, err := ds.ListMyCollections()
+		if err != nil {
+			t.Errorf("Unable to list my collections. Error: %s", err)
+		}
+		for _, ci := range cs {
+			if ci.IPNSAddress == ipns {
+				return true
+			}
+		}
+		return false
+	}
+	if !isMine() {
+		t.Error("setmine.com should be in my collections.")
+	}
+
+	err = ds.SetCollectionMine(ipns, false)
+	if err != nil {
+		t.Errorf("Unable to set collection mine. Error: %s", err)
+	}
+	if isMine() {
+		t.Error("setmine.com should not be in my collections.")
+	}
+
+	children, err := ds.ReadFolderChildren(&Folder{IPNSAddress: ipns, Path: ""})
+	if err != nil {
+		t.Errorf("Unable to read root children. Error: %s", err)
+	}
+	if !reflect.DeepEqual(children, []string{"folder"}) {
+		t.Errorf("Root children = %v; want [folder]", children)
+	}
+
+	err = ds.SetCollectionMine("missing.setmine.com", true)
+	if err != ErrIPNSNotFound {
+		t.Errorf("SetCollectionMine of a missing collection should return ErrIPNSNotFound. Actual %v", err)
+	}
+}
+
+func TestTagCounts(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmTagCounts1", Name: "Tag Counts", Tags: []Tag{{"tagcounts", "a"}}})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+
+	counts, err := ds.TagCounts([]Tag{{"tagcounts", "a"}, {}, {"tagcounts", "unknown"}})
+	if err != nil {
+		t.Errorf("Unable to read tag counts. Error: %s", err)
+	}
+	expected := map[string]uint{"tagcounts:a": 1, "tagcounts:unknown": 0}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("TagCounts = %v; want %v", counts, expected)
+	}
+
+	counts, err = ds.TagCounts(nil)
+	if err != nil {
+		t.Errorf("Unable to read tag counts. Error: %s", err)
+	}
+	if len(counts) != 0 {
+		t.Errorf("TagCounts of no tags = %v; want empty map", counts)
+	}
+}
+
+func TestAddItemToCollectionOpts(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "opts.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Opts"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmOpts1", Name: "Opts 1"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+
+	err = ds.AddItemToCollectionOpts("QmOpts1", ipns, false)
+	if err != nil {
+		t.Errorf("Unable to add item to collection. Error: %s", err)
+	}
+
+	inCollection, err := ds.IsItemInCollection("QmOpts1", ipns)
+	if err != nil {
+		t.Errorf("Unable to check item in collection. Error: %s", err)
+	}
+	if !inCollection {
+		t.Error("QmOpts1 should be in the collection.")
+	}
+
+	items, err := ds.ReadFolderItems(&Folder{IPNSAddress: ipns})
+	if err != nil {
+		t.Errorf("Unable to read folder items. Error: %s", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("Root folder items = %v; want []", items)
+	}
+
+	err = ds.AddItemToCollectionOpts("QmOpts1", ipns, true)
+	if err != ErrItemInCollection {
+		t.Errorf("Adding an item twice should return ErrItemInCollection. Actual %v", err)
+	}
+}
+
+func TestAddItemTagConcurrent(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	cid := "QmConcurrentTag1"
+	err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: "Concurrent Tag"})
+	if err != nil {
+		t.Fatalf("Unable to create item. Error: %s", err)
+	}
+
+	tags := []Tag{{"concurrent", "a"}, {"concurrent", "b"}}
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for k := 0; k < 10; k++ {
+				err := ds.AddItemTag(cid, tags[(n+k)%len(tags)])
+				if err != nil {
+					t.Errorf("Unable to add tag. Error: %s", err)
+					return
+				}
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	item, err := ds.ReadItem(cid)
+	if err != nil {
+		t.Fatalf("Unable to read item. Error: %s", err)
+	}
+	if len(item.Tags) != 2 {
+		t.Errorf("Item tags = %v; want %v", item.Tags, tags)
+	}
+	counts, err := ds.ReadTagItemCount(tags)
+	if err != nil {
+		t.Errorf("Unable to read tag item count. Error: %s", err)
+	}
+	if !reflect.DeepEqual(counts, []uint{1, 1}) {
+		t.Errorf("Tag item counts = %v; want [1 1]", counts)
+	}
+}
+
+func TestAddItemTagGetError(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	// Every read of a discarded transaction fails
+	txn := ds.db.NewTransaction(true)
+	txn.Discard()
+	err = ds.addItemTagInTxn(txn, "QmTagGetError1", Tag{"geterror"})
+	if err != badger.ErrDiscardedTxn {
+		t.Errorf("addItemTagInTxn should return the read error. Actual %v", err)
+	}
+}
+
+func TestSetCollectionName(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "setname.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Set Name", Description: "Set Name Collection", IsMine: true})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+
+	err = ds.SetCollectionName(ipns, "Renamed")
+	if err != nil {
+		t.Errorf("Unable to set collection name. Error: %s", err)
+	}
+	err = ds.SetCollectionDescription(ipns, "Redescribed")
+	if err != nil {
+		t.Errorf("Unable to set collection description. Error: %s", err)
+	}
+
+	c, err := ds.ReadCollection(ipns)
+	if err != nil {
+		t.Errorf("Unable to read collection. Error: %s", err)
+	}
+	if c.Name != "Renamed" || c.Description != "Redescribed" || !c.IsMine {
+		t.Errorf("Wrong collection after SetCollectionName: %+v", c)
+	}
+
+	err = ds.SetCollectionName("missing.setname.com", "Missing")
+	if err != ErrIPNSNotFound {
+		t.Errorf("SetCollectionName of a missing collection should return ErrIPNSNotFound. Actual %v", err)
+	}
+	err = ds.SetCollectionDescription("missing.setname.com", "Missing")
+	if err != ErrIPNSNotFound {
+		t.Errorf("SetCollectionDescription of a missing collection should return ErrIPNSNotFound. Actual %v", err)
+	}
+}
+
+func TestCreateFolderTwice(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "twice.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Twice"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	for n := 0; n < 2; n++ {
+		err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: "folder"})
+		if err != nil {
+			t.Errorf("Unable to create folder. Error: %s", err)
+		}
+	}
+	// Updating the collection updates its root folder too
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Twice"})
+	if err != nil {
+		t.Errorf("Unable to update Collection. Error: %s", err)
+	}
+
+	children, err := ds.ReadFolderChildren(&Folder{IPNSAddress: ipns})
+	if err != nil {
+		t.Errorf("Unable to read folder children. Error: %s", err)
+	}
+	if !reflect.DeepEqual(children, []string{"folder"}) {
+		t.Errorf("Root children = %v; want [folder]", children)
+	}
+}
+
+func TestCountFolderItems(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "count.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Count"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	folder := &Folder{IPNSAddress: ipns, Path: "folder"}
+	err = ds.CreateOrUpdateFolder(folder)
+	if err != nil {
+		t.Errorf("Unable to create folder. Error: %s", err)
+	}
+	// A sibling whose path starts with the folder path is not counted
+	err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: "folder2"})
+	if err != nil {
+		t.Errorf("Unable to create folder. Error: %s", err)
+	}
+
+	for _, cid := range []string{"QmCount1", "QmCount2", "QmCount3"} {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid})
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+		err = ds.AddItemToCollection(cid, ipns)
+		if err != nil {
+			t.Errorf("Unable to add item to collection. Error: %s", err)
+		}
+	}
+	for _, cid := range []string{"QmCount1", "QmCount2"} {
+		err = ds.AddItemToFolder(cid, folder)
+		if err != nil {
+			t.Errorf("Unable to add item to folder. Error: %s", err)
+		}
+	}
+	err = ds.AddItemToFolder("QmCount3", &Folder{IPNSAddress: ipns, Path: "folder2"})
+	if err != nil {
+		t.Errorf("Unable to add item to folder. Error: %s", err)
+	}
+
+	n, err := ds.CountFolderItems(folder)
+	if err != nil {
+		t.Errorf("Unable to count folder items. Error: %s", err)
+	}
+	if n != 2 {
+		t.Errorf("CountFolderItems = %d; want 2", n)
+	}
+
+	_, err = ds.CountFolderItems(&Folder{IPNSAddress: ipns, Path: "missing"})
+	if err != ErrFolderNotExists {
+		t.Errorf("Counting items of a missing folder should return ErrFolderNotExists. Actual %v", err)
+	}
+}
+
+func TestCollectionUpdatedAt(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "updated.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Updated"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmUpdated1", Name: "Updated 1"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+
+	last := time.Time{}
+	assertUpdated := func(action string) {
+		c, err := ds.ReadCollection(ipns)
+		if err != nil {
+			t.Fatalf("Unable to read collection. Error: %s", err)
+		}
+		if !c.UpdatedAt.After(last) {
+			t.Errorf("UpdatedAt should be bumped by %s. Last %v, actual %v", action, last, c.UpdatedAt)
+		}
+		last = c.UpdatedAt
+	}
+	assertUpdated("CreateOrUpdateCollection")
+
+	err = ds.AddItemToCollection("QmUpdated1", ipns)
+	if err != nil {
+		t.Errorf("Unable to add item to collection. Error: %s", err)
+	}
+	assertUpdated("AddItemToCollection")
+
+	err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: "folder"})
+	if err != nil {
+		t.Errorf("Unable to create folder. Error: %s", err)
+	}
+	assertUpdated("CreateOrUpdateFolder")
+
+	err = ds.AddItemTag("QmUpdated1", Tag{"updated"})
+	if err != nil {
+		t.Errorf("Unable to add tag. Error: %s", err)
+	}
+	assertUpdated("AddItemTag")
+
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmUpdated1", Name: "Updated 1 Renamed"})
+	if err != nil {
+		t.Errorf("Unable to update item. Error: %s", err)
+	}
+	assertUpdated("CreateOrUpdateItem")
+
+	err = ds.RemoveItemFromCollection("QmUpdated1", ipns)
+	if err != nil {
+		t.Errorf("Unable to remove item from collection. Error: %s", err)
+	}
+	assertUpdated("RemoveItemFromCollection")
+
+	// The item isn't in the collection anymore
+	err = ds.AddItemTag("QmUpdated1", Tag{"updated", "again"})
+	if err != nil {
+		t.Errorf("Unable to add tag. Error: %s", err)
+	}
+	c, err := ds.ReadCollection(ipns)
+	if err != nil {
+		t.Fatalf("Unable to read collection. Error: %s", err)
+	}
+	if !c.UpdatedAt.Equal(last) {
+		t.Errorf("UpdatedAt should not change by tagging an item outside the collection. Last %v, actual %v", last, c.UpdatedAt)
+	}
+}
+
+func TestDebugScan(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmDebugScan1", Name: "Debug Scan", Tags: []Tag{{"debugscan", "a"}, {"debugscan", "b"}}})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+
+	keys, err := ds.DebugScan("item_tag::QmDebugScan1::", 10)
+	if err != nil {
+		t.Errorf("Unable to scan keys. Error: %s", err)
+	}
+	expected := []string{"item_tag::QmDebugScan1::debugscan:a", "item_tag::QmDebugScan1::debugscan:b"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("DebugScan = %v; want %v", keys, expected)
+	}
+
+	keys, err = ds.DebugScan("item_tag::QmDebugScan1::", 1)
+	if err != nil {
+		t.Errorf("Unable to scan keys. Error: %s", err)
+	}
+	if len(keys) != 1 {
+		t.Errorf("DebugScan with limit 1 = %v; want 1 key", keys)
+	}
+
+	// Keys are shown unescaped
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmDebugScan::2", Name: "Debug Scan"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	keys, err = ds.DebugScan(dbKey{"items", "QmDebugScan::2"}.String(), 10)
+	if err != nil {
+		t.Errorf("Unable to scan keys. Error: %s", err)
+	}
+	expected = []string{"items::QmDebugScan::2"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("DebugScan = %v; want %v", keys, expected)
+	}
+
+	_, err = ds.DebugScan("items::", 0)
+	if err != ErrInvalidLimit {
+		t.Errorf("DebugScan with limit 0 should return ErrInvalidLimit. Actual %v", err)
+	}
+}
+
+func TestDB(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmDBAccessor1", Name: "DB Accessor"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+
+	// A direct read sees data written through Datastore
+	var v []byte
+	err = ds.DB().View(func(txn *badger.Txn) error {
+		item, err := txn.Get(ds.key(dbKey{"items", "QmDBAccessor1"}))
+		if err != nil {
+			return err
+		}
+		v, err = item.ValueCopy(nil)
+		return err
+	})
+	if err != nil {
+		t.Errorf("Unable to read from DB. Error: %s", err)
+	}
+	if string(v) != "QmDBAccessor1" {
+		t.Errorf("Value = %q; want %q", v, "QmDBAccessor1")
+	}
+}
+
+func TestMoveItemToFolder(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	for _, ipns := range []string{"moveitem1.com", "moveitem2.com"} {
+		err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: ipns})
+		if err != nil {
+			t.Errorf("Unable to create Collection. Error: %s", err)
+		}
+		err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: "folder"})
+		if err != nil {
+			t.Errorf("Unable to create folder. Error: %s", err)
+		}
+	}
+	cid := "QmMoveItem1"
+	err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: "Move Item"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	err = ds.AddItemToCollection(cid, "moveitem1.com")
+	if err != nil {
+		t.Errorf("Unable to add item to collection. Error: %s", err)
+	}
+
+	root1 := &Folder{IPNSAddress: "moveitem1.com"}
+	folder1 := &Folder{IPNSAddress: "moveitem1.com", Path: "folder"}
+	folder2 := &Folder{IPNSAddress: "moveitem2.com", Path: "folder"}
+	isIn := func(f *Folder) bool {
+		in, err := ds.IsItemInFolder(cid, f)
+		if err != nil {
+			t.Errorf("Unable to check item in folder. Error: %s", err)
+		}
+		return in
+	}
+
+	err = ds.MoveItemToFolder(cid, root1, folder1)
+	if err != nil {
+		t.Errorf("Unable to move item. Error: %s", err)
+	}
+	if isIn(root1) || !isIn(folder1) {
+		t.Error("QmMoveItem1 should be moved from root to folder.")
+	}
+
+	err = ds.MoveItemToFolder(cid, root1, folder1)
+	if err != ErrItemNotInFolder {
+		t.Errorf("Moving an item not in the folder should return ErrItemNotInFolder. Actual %v", err)
+	}
+	err = ds.MoveItemToFolder(cid, folder1, &Folder{IPNSAddress: "moveitem1.com", Path: "missing"})
+	if err != ErrFolderNotExists {
+		t.Errorf("Moving an item to a missing folder should return ErrFolderNotExists. Actual %v", err)
+	}
+
+	// Move to another collection
+	err = ds.MoveItemToFolder(cid, folder1, folder2)
+	if err != nil {
+		t.Errorf("Unable to move item. Error: %s", err)
+	}
+	if isIn(folder1) || !isIn(folder2) {
+		t.Error("QmMoveItem1 should be moved to moveitem2.com.")
+	}
+	in1, err := ds.IsItemInCollection(cid, "moveitem1.com")
+	if err != nil {
+		t.Errorf("Unable to check item in collection. Error: %s", err)
+	}
+	in2, err := ds.IsItemInCollection(cid, "moveitem2.com")
+	if err != nil {
+		t.Errorf("Unable to check item in collection. Error: %s", err)
+	}
+	if in1 || !in2 {
+		t.Errorf("QmMoveItem1 should only be in moveitem2.com. In moveitem1.com: %v, in moveitem2.com: %v", in1, in2)
+	}
+	if isIn(&Folder{IPNSAddress: "moveitem2.com"}) {
+		t.Error("QmMoveItem1 should not be added to the root folder of moveitem2.com.")
+	}
+}
+
+func TestCopyItemToFolder(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	for _, ipns := range []string{"copyitem1.com", "copyitem2.com"} {
+		err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: ipns})
+		if err != nil {
+			t.Errorf("Unable to create Collection. Error: %s", err)
+		}
+		err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: "folder"})
+		if err != nil {
+			t.Errorf("Unable to create folder. Error: %s", err)
+		}
+	}
+	cid := "QmCopyItem1"
+	err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: "Copy Item"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	err = ds.AddItemToCollection(cid, "copyitem1.com")
+	if err != nil {
+		t.Errorf("Unable to add item to collection. Error: %s", err)
+	}
+
+	root1 := &Folder{IPNSAddress: "copyitem1.com"}
+	folder1 := &Folder{IPNSAddress: "copyitem1.com", Path: "folder"}
+	folder2 := &Folder{IPNSAddress: "copyitem2.com", Path: "folder"}
+	for _, to := range []*Folder{folder1, folder2} {
+		err = ds.CopyItemToFolder(cid, root1, to)
+		if err != nil {
+			t.Errorf("Unable to copy item. Error: %s", err)
+		}
+	}
+	for _, f := range []*Folder{root1, folder1, folder2} {
+		in, err := ds.IsItemInFolder(cid, f)
+		if err != nil {
+			t.Errorf("Unable to check item in folder. Error: %s", err)
+		}
+		if !in {
+			t.Errorf("QmCopyItem1 should be in %s/%s.", f.IPNSAddress, f.Path)
+		}
+	}
+	in, err := ds.IsItemInCollection(cid, "copyitem2.com")
+	if err != nil {
+		t.Errorf("Unable to check item in collection. Error: %s", err)
+	}
+	if !in {
+		t.Error("QmCopyItem1 should be added to copyitem2.com.")
+	}
+
+	err = ds.CopyItemToFolder(cid, &Folder{IPNSAddress: "copyitem2.com"}, folder1)
+	if err != ErrItemNotInFolder {
+		t.Errorf("Copying an item not in the folder should return ErrItemNotInFolder. Actual %v", err)
+	}
+	err = ds.CopyItemToFolder(cid, root1, &Folder{IPNSAddress: "copyitem1.com", Path: "missing"})
+	if err != ErrFolderNotExists {
+		t.Errorf("Copying an item to a missing folder should return ErrFolderNotExists. Actual %v", err)
+	}
+}
+
+func TestDelCollectionNativeDropPrefix(t *testing.T) {
+	ds, err := NewDatastoreWithOptions(dbPath, DefaultOptions().WithNativeDropPrefix(true))
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	// native.com2 shares the prefix of native.com and must survive
+	for _, ipns := range []string{"native.com", "native.com2"} {
+		err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: ipns})
+		if err != nil {
+			t.Errorf("Unable to create Collection. Error: %s", err)
+		}
+		err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: "folder"})
+		if err != nil {
+			t.Errorf("Unable to create folder. Error: %s", err)
+		}
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmNativeDrop1", Name: "Native Drop"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	err = ds.AddItemToCollection("QmNativeDrop1", "native.com")
+	if err != nil {
+		t.Errorf("Unable to add item to collection. Error: %s", err)
+	}
+	err = ds.AddItemToFolder("QmNativeDrop1", &Folder{IPNSAddress: "native.com", Path: "folder"})
+	if err != nil {
+		t.Errorf("Unable to add item to folder. Error: %s", err)
+	}
+
+	err = ds.DelCollection("native.com")
+	if err != nil {
+		t.Fatalf("Unable to delete Collection. Error: %s", err)
+	}
+
+	for _, p := range []string{"collection::native.com::", "collection_item::native.com::", "folders::native.com::",
+		"folder::native.com::", "folder_item::native.com::", "item_folder::QmNativeDrop1::", "item_collection::QmNativeDrop1::"} {
+		keys, err := ds.DebugScan(p, 10)
+		if err != nil {
+			t.Errorf("Unable to scan keys. Error: %s", err)
+		}
+		if len(keys) != 0 {
+			t.Errorf("Keys under %s should be deleted. Actual %v", p, keys)
+		}
+	}
+
+	exists, err := ds.ItemExists("QmNativeDrop1")
+	if err != nil {
+		t.Errorf("Unable to check if Item exists. Error: %s", err)
+	}
+	if !exists {
+		t.Error("Deleting a collection should not delete its items.")
+	}
+	exists, err = ds.IsFolderPathExists("native.com2", "folder")
+	if err != nil {
+		t.Errorf("Unable to check if folder exists. Error: %s", err)
+	}
+	if !exists {
+		t.Error("Folders of native.com2 should not be deleted.")
+	}
+}
+
+func TestRemoveItemFromCollectionPrefixIPNS(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	// prefix.com.evil shares the prefix of prefix.com and must keep the item
+	cid := "QmPrefixIPNS1"
+	err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: "Prefix IPNS"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	for _, ipns := range []string{"prefix.com", "prefix.com.evil"} {
+		err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: ipns})
+		if err != nil {
+			t.Errorf("Unable to create Collection. Error: %s", err)
+		}
+		folder := &Folder{IPNSAddress: ipns, Path: "folder"}
+		err = ds.CreateOrUpdateFolder(folder)
+		if err != nil {
+			t.Errorf("Unable to create folder. Error: %s", err)
+		}
+		err = ds.AddItemToFolder(cid, folder)
+		if err != nil {
+			t.Errorf("Unable to add item to folder. Error: %s", err)
+		}
+	}
+
+	err = ds.RemoveItemFromCollection(cid, "prefix.com")
+	if err != nil {
+		t.Fatalf("Unable to remove item from collection. Error: %s", err)
+	}
+
+	locations, err := ds.ItemLocations(cid)
+	if err != nil {
+		t.Errorf("Unable to read item locations. Error: %s", err)
+	}
+	expected := []ItemLocation{{IPNS: "prefix.com.evil", Path: "folder"}}
+	if !reflect.DeepEqual(locations, expected) {
+		t.Errorf("Item locations = %v; want %v", locations, expected)
+	}
+}
+
+func TestUpdateItemPrefixCID(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	// QmPrefixCIDLonger shares the prefix of QmPrefixCID and must keep its tags
+	tag := Tag{"prefixcid", "tag"}
+	for _, cid := range []string{"QmPrefixCID", "QmPrefixCIDLonger"} {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid, Tags: []Tag{tag}})
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmPrefixCID", Name: "Updated"})
+	if err != nil {
+		t.Errorf("Unable to update item. Error: %s", err)
+	}
+
+	item, err := ds.ReadItem("QmPrefixCIDLonger")
+	if err != nil {
+		t.Fatalf("Unable to read item. Error: %s", err)
+	}
+	if !reflect.DeepEqual(item.Tags, []Tag{tag}) {
+		t.Errorf("Item tags = %v; want %v", item.Tags, []Tag{tag})
+	}
+	hasTag, err := ds.HasTag("QmPrefixCIDLonger", tag)
+	if err != nil {
+		t.Errorf("Unable to check if Item has Tag. Error: %s", err)
+	}
+	if !hasTag {
+		t.Error("Item should have Tag but not.")
+	}
+}
+
+func TestDelCollectionTxnTooBig(t *testing.T) {
+	bigDbPath := filepath.Join(testdataDir, "big.db")
+	_ = os.RemoveAll(bigDbPath)
+	defer os.RemoveAll(bigDbPath)
+
+	ds, err := NewDatastore(bigDbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "big.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Big"})
+	if err != nil {
+		t.Fatalf("Unable to create Collection. Error: %s", err)
+	}
+
+	// Every item adds 4 relationship keys to delete, which is more than one transaction can hold
+	count := int(ds.db.MaxBatchCount()/4) + 1000
+	for n := 0; n < count; n += 200 {
+		err = ds.Update(func(tx *Tx) error {
+			for i := n; i < n+200 && i < count; i++ {
+				cid := fmt.Sprintf("QmBig%06d", i)
+				err := tx.CreateOrUpdateItem(&Item{CID: cid, Name: cid})
+				if err != nil {
+					return err
+				}
+				err = tx.AddItemToCollection(cid, ipns)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unable to create items. Error: %s", err)
+		}
+	}
+
+	err = ds.DelCollection(ipns)
+	if err != nil {
+		t.Fatalf("Unable to delete Collection. Error: %s", err)
+	}
+
+	exists, err := ds.CollectionExists(ipns)
+	if err != nil {
+		t.Errorf("Unable to check if Collection exists. Error: %s", err)
+	}
+	if exists {
+		t.Error("Collection should be deleted.")
+	}
+	for _, p := range []string{"collection_item::big.com::", "folder_item::big.com::", "item_collection::QmBig000000::"} {
+		keys, err := ds.DebugScan(p, 1)
+		if err != nil {
+			t.Errorf("Unable to scan keys. Error: %s", err)
+		}
+		if len(keys) != 0 {
+			t.Errorf("Keys under %s should be deleted. Actual %v", p, keys)
+		}
+	}
+}
+
+func TestSetCollectionPublished(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "published.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Published", IsMine: true})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	c, err := ds.ReadCollection(ipns)
+	if err != nil {
+		t.Fatalf("Unable to read collection. Error: %s", err)
+	}
+	if c.Published || c.PublishedCID != "" {
+		t.Errorf("New collection should not be published: %+v", c)
+	}
+
+	err = ds.SetCollectionPublished(ipns, "QmPublishedRoot")
+	if err != nil {
+		t.Errorf("Unable to set collection published. Error: %s", err)
+	}
+	c, err = ds.ReadCollection(ipns)
+	if err != nil {
+		t.Fatalf("Unable to read collection. Error: %s", err)
+	}
+	if !c.Published || c.PublishedCID != "QmPublishedRoot" || c.Name != "Published" {
+		t.Errorf("Wrong collection after SetCollectionPublished: %+v", c)
+	}
+
+	// Updating the collection without publish state keeps it
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Published Renamed", IsMine: true})
+	if err != nil {
+		t.Errorf("Unable to update Collection. Error: %s", err)
+	}
+	c, err = ds.ReadCollection(ipns)
+	if err != nil {
+		t.Fatalf("Unable to read collection. Error: %s", err)
+	}
+	if !c.Published || c.PublishedCID != "QmPublishedRoot" || c.Name != "Published Renamed" {
+		t.Errorf("Updating a collection should keep its publish state: %+v", c)
+	}
+
+	// An empty root CID clears the publish state
+	err = ds.SetCollectionPublished(ipns, "")
+	if err != nil {
+		t.Errorf("Unable to clear collection publish state. Error: %s", err)
+	}
+	c, err = ds.ReadCollection(ipns)
+	if err != nil {
+		t.Fatalf("Unable to read collection. Error: %s", err)
+	}
+	if c.Published || c.PublishedCID != "" || c.Name != "Published Renamed" {
+		t.Errorf("Wrong collection after clearing publish state: %+v", c)
+	}
+
+	err = ds.SetCollectionPublished("missing.published.com", "QmPublishedRoot")
+	if err != ErrIPNSNotFound {
+		t.Errorf("SetCollectionPublished of a missing collection should return ErrIPNSNotFound. Actual %v", err)
+	}
+}
+
+func TestMoveAllItems(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	for _, ipns := range []string{"moveall1.com", "moveall2.com"} {
+		err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: ipns})
+		if err != nil {
+			t.Errorf("Unable to create Collection. Error: %s", err)
+		}
+	}
+	err = ds.CreateFolderAll("moveall1.com", "from/sub")
+	if err != nil {
+		t.Errorf("Unable to create folders. Error: %s", err)
+	}
+	err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: "moveall2.com", Path: "to"})
+	if err != nil {
+		t.Errorf("Unable to create folder. Error: %s", err)
+	}
+
+	from := &Folder{IPNSAddress: "moveall1.com", Path: "from"}
+	to := &Folder{IPNSAddress: "moveall2.com", Path: "to"}
+	for _, cid := range []string{"QmMoveAll1", "QmMoveAll2"} {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid})
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+		err = ds.AddItemToCollectionOpts(cid, "moveall1.com", false)
+		if err != nil {
+			t.Errorf("Unable to add item to collection. Error: %s", err)
+		}
+		err = ds.AddItemToFolder(cid, from)
+		if err != nil {
+			t.Errorf("Unable to add item to folder. Error: %s", err)
+		}
+	}
+	sub := &Folder{IPNSAddress: "moveall1.com", Path: "from/sub"}
+	err = ds.AddItemToFolder("QmMoveAll2", sub)
+	if err != nil {
+		t.Errorf("Unable to add item to folder. Error: %s", err)
+	}
+
+	err = ds.MoveAllItems(from, to)
+	if err != nil {
+		t.Errorf("Unable to move items. Error: %s", err)
+	}
+
+	fromItems, err := ds.ReadFolderItems(from)
+	if err != nil {
+		t.Errorf("Unable to read folder items. Error: %s", err)
+	}
+	toItems, err := ds.ReadFolderItems(to)
+	if err != nil {
+		t.Errorf("Unable to read folder items. Error: %s", err)
+	}
+	if len(fromItems) != 0 || !reflect.DeepEqual(toItems, []string{"QmMoveAll1", "QmMoveAll2"}) {
+		t.Errorf("Items of from = %v, to = %v; want [] and [QmMoveAll1 QmMoveAll2]", fromItems, toItems)
+	}
+
+	// QmMoveAll2 stays in moveall1.com because it's still in from/sub
+	for cid, want := range map[string]bool{"QmMoveAll1": false, "QmMoveAll2": true} {
+		in, err := ds.IsItemInCollection(cid, "moveall1.com")
+		if err != nil {
+			t.Errorf("Unable to check item in collection. Error: %s", err)
+		}
+		if in != want {
+			t.Errorf("%s in moveall1.com = %v; want %v", cid, in, want)
+		}
+	}
+
+	err = ds.MoveAllItems(from, &Folder{IPNSAddress: "moveall2.com", Path: "missing"})
+	if err != ErrFolderNotExists {
+		t.Errorf("Moving items to a missing folder should return ErrFolderNotExists. Actual %v", err)
+	}
+}
+
+func TestSyncWritesDisabled(t *testing.T) {
+	ds, err := NewDatastoreWithOptions(dbPath, DefaultOptions().WithSyncWrites(false))
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmNoSync1", Name: "No Sync"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	ds.Close()
+
+	// A clean Close still flushes the writes
+	ds, err = NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	i, err := ds.ReadItem("QmNoSync1")
+	if err != nil {
+		t.Errorf("Unable to read item. Error: %s", err)
+	} else if i.Name != "No Sync" {
+		t.Errorf("Item name = %q; want %q", i.Name, "No Sync")
+	}
+}
+
+func TestReadFolderChildrenFull(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "childrenfull.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Children Full"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	for _, p := range []string{"a/b/c", "a/d"} {
+		err = ds.CreateFolderAll(ipns, p)
+		if err != nil {
+			t.Errorf("Unable to create folders. Error: %s", err)
+		}
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmChildrenFull1", Name: "Children Full"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	err = ds.AddItemToCollection("QmChildrenFull1", ipns)
+	if err != nil {
+		t.Errorf("Unable to add item to collection. Error: %s", err)
+	}
+	err = ds.AddItemToFolder("QmChildrenFull1", &Folder{IPNSAddress: ipns, Path: "a/b"})
+	if err != nil {
+		t.Errorf("Unable to add item to folder. Error: %s", err)
+	}
+
+	children, err := ds.ReadFolderChildrenFull(&Folder{IPNSAddress: ipns, Path: "a"})
+	if err != nil {
+		t.Errorf("Unable to read folder children. Error: %s", err)
+	}
+	expected := []*Folder{
+		{IPNSAddress: ipns, Path: "a/b", ItemCount: 1, ChildCount: 1},
+		{IPNSAddress: ipns, Path: "a/d"},
+	}
+	if !reflect.DeepEqual(children, expected) {
+		t.Errorf("ReadFolderChildrenFull = %v; want %v", children, expected)
+	}
+
+	_, err = ds.ReadFolderChildrenFull(&Folder{IPNSAddress: ipns, Path: "missing"})
+	if err != ErrFolderNotExists {
+		t.Errorf("Reading children of a missing folder should return ErrFolderNotExists. Actual %v", err)
+	}
+}
+
+func TestCreateEmptyCollection(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "emptycollection.com"
+	err = ds.CreateEmptyCollection(ipns)
+	if err != nil {
+		t.Errorf("Unable to create empty Collection. Error: %s", err)
+	}
+
+	c, err := ds.ReadCollection(ipns)
+	if err != nil {
+		t.Fatalf("Unable to read Collection. Error: %s", err)
+	}
+	if c.Name != "" {
+		t.Errorf("Name of an empty collection = %q; want \"\"", c.Name)
+	}
+	exists, err := ds.IsFolderPathExists(ipns, "")
+	if err != nil {
+		t.Errorf("Unable to check folder. Error: %s", err)
+	}
+	if !exists {
+		t.Error("Root folder of an empty collection should exist.")
+	}
+
+	err = ds.SetCollectionName(ipns, "Named")
+	if err != nil {
+		t.Errorf("Unable to set collection name. Error: %s", err)
+	}
+	// Creating again doesn't reset the name
+	err = ds.CreateEmptyCollection(ipns)
+	if err != nil {
+		t.Errorf("Unable to create empty Collection. Error: %s", err)
+	}
+	c, err = ds.ReadCollection(ipns)
+	if err != nil {
+		t.Fatalf("Unable to read Collection. Error: %s", err)
+	}
+	if c.Name != "Named" {
+		t.Errorf("Name of the collection = %q; want %q", c.Name, "Named")
+	}
+}
+
+func TestFolderAncestors(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "ancestors.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Ancestors"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	err = ds.CreateFolderAll(ipns, "a/b/c")
+	if err != nil {
+		t.Errorf("Unable to create folders. Error: %s", err)
+	}
+
+	ancestors, err := ds.FolderAncestors(ipns, "a/b/c")
+	if err != nil {
+		t.Errorf("Unable to read folder ancestors. Error: %s", err)
+	}
+	expected := []*Folder{
+		{IPNSAddress: ipns, Path: ""},
+		{IPNSAddress: ipns, Path: "a"},
+		{IPNSAddress: ipns, Path: "a/b"},
+	}
+	if !reflect.DeepEqual(ancestors, expected) {
+		t.Errorf("FolderAncestors = %v; want %v", ancestors, expected)
+	}
+
+	ancestors, err = ds.FolderAncestors(ipns, "")
+	if err != nil {
+		t.Errorf("Unable to read folder ancestors. Error: %s", err)
+	}
+	if len(ancestors) != 0 {
+		t.Errorf("Root folder should have no ancestors. Actual %v", ancestors)
+	}
+
+	_, err = ds.FolderAncestors(ipns, "a/missing")
+	if err != ErrFolderNotExists {
+		t.Errorf("Reading ancestors of a missing folder should return ErrFolderNotExists. Actual %v", err)
+	}
+}
+
+func TestResolveFolder(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "resolve.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Resolve"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	err = ds.CreateFolderAll(ipns, "a/b/c")
+	if err != nil {
+		t.Errorf("Unable to create folders. Error: %s", err)
+	}
+
+	folder, ancestors, err := ds.ResolveFolder(ipns, "a/b")
+	if err != nil {
+		t.Errorf("Unable to resolve folder. Error: %s", err)
+	}
+	if folder.Path != "a/b" || folder.ChildCount != 1 {
+		t.Errorf("Folder = %v; want a/b with 1 child", folder)
+	}
+	var paths []string
+	for _, a := range ancestors {
+		paths = append(paths, a.Path)
+	}
+	if !reflect.DeepEqual(paths, []string{"", "a"}) {
+		t.Errorf("Ancestor paths = %v; want [ a]", paths)
+	}
+
+	_, _, err = ds.ResolveFolder(ipns, "a/missing")
+	if err != ErrFolderNotExists {
+		t.Errorf("Resolving a missing folder should return ErrFolderNotExists. Actual %v", err)
+	}
+
+	// Simulate a broken tree with a missing ancestor
+	err = ds.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(dbKey{"folders", ipns, "a"}.Bytes())
+	})
+	if err != nil {
+		t.Fatalf("Unable to corrupt Datastore. Error: %s", err)
+	}
+	_, _, err = ds.ResolveFolder(ipns, "a/b/c")
+	if err != ErrParentFolderNotExists {
+		t.Errorf("Resolving a folder with a missing ancestor should return ErrParentFolderNotExists. Actual %v", err)
+	}
+}
+
+func TestInsertItemsFresh(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	tag := Tag{"insertfresh"}
+	err = ds.InsertItemsFresh([]*Item{
+		{CID: "QmInsertFresh1", Name: "Fresh 1", Tags: []Tag{tag}},
+		{CID: "QmInsertFresh2", Name: "Fresh 2", Tags: []Tag{tag}},
+	})
+	if err != nil {
+		t.Errorf("Unable to insert items. Error: %s", err)
+	}
+
+	i, err := ds.ReadItem("QmInsertFresh1")
+	if err != nil {
+		t.Errorf("Unable to read item. Error: %s", err)
+	} else if i.Name != "Fresh 1" || !reflect.DeepEqual(i.Tags, []Tag{tag}) {
+		t.Errorf("Item = %+v; want name Fresh 1 and tags [%v]", i, tag)
+	}
+	counts, err := ds.ReadTagItemCount([]Tag{tag})
+	if err != nil {
+		t.Errorf("Unable to read tag item count. Error: %s", err)
+	}
+	if !reflect.DeepEqual(counts, []uint{2}) {
+		t.Errorf("Tag item count = %v; want [2]", counts)
+	}
+
+	// Nothing is written if any CID exists
+	err = ds.InsertItemsFresh([]*Item{
+		{CID: "QmInsertFresh3", Name: "Fresh 3", Tags: []Tag{tag}},
+		{CID: "QmInsertFresh1", Name: "Fresh 1", Tags: []Tag{tag}},
+	})
+	if err != ErrItemExists {
+		t.Errorf("Inserting an existing item should return ErrItemExists. Actual %v", err)
+	}
+	exists, err := ds.ItemExists("QmInsertFresh3")
+	if err != nil {
+		t.Errorf("Unable to check item. Error: %s", err)
+	}
+	if exists {
+		t.Error("No item should be inserted if any CID exists.")
+	}
+
+	err = ds.InsertItemsFresh([]*Item{
+		{CID: "QmInsertFresh4", Name: "Fresh 4"},
+		{CID: "QmInsertFresh4", Name: "Fresh 4"},
+	})
+	if err != ErrItemExists {
+		t.Errorf("Inserting a CID twice should return ErrItemExists. Actual %v", err)
+	}
+
+	counts, err = ds.ReadTagItemCount([]Tag{tag})
+	if err != nil {
+		t.Errorf("Unable to read tag item count. Error: %s", err)
+	}
+	if !reflect.DeepEqual(counts, []uint{2}) {
+		t.Errorf("Tag item count = %v; want [2]", counts)
+	}
+}
+
+func TestReadCollections(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	for _, ipns := range []string{"readmany1.com", "readmany2.com"} {
+		err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: ipns})
+		if err != nil {
+			t.Errorf("Unable to create Collection. Error: %s", err)
+		}
+	}
+
+	list := []string{"readmany2.com", "readmanymissing.com", "readmany1.com"}
+	cs, err := ds.ReadCollections(list, true)
+	if err != nil {
+		t.Errorf("Unable to read collections. Error: %s", err)
+	}
+	var names []string
+	for _, c := range cs {
+		names = append(names, c.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"readmany2.com", "readmany1.com"}) {
+		t.Errorf("Names of collections = %v; want [readmany2.com readmany1.com]", names)
+	}
+
+	_, err = ds.ReadCollections(list, false)
+	if err != ErrIPNSNotFound {
+		t.Errorf("Reading a missing collection should return ErrIPNSNotFound. Actual %v", err)
+	}
+}
+
+func TestIsFolderEmpty(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "folderempty.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Folder Empty"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	// "a2" shares the prefix of "a" and its item doesn't count for "a"
+	for _, p := range []string{"a/b", "a2", "c"} {
+		err = ds.CreateFolderAll(ipns, p)
+		if err != nil {
+			t.Errorf("Unable to create folders. Error: %s", err)
+		}
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmFolderEmpty1", Name: "Folder Empty"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	err = ds.AddItemToCollection("QmFolderEmpty1", ipns)
+	if err != nil {
+		t.Errorf("Unable to add item to collection. Error: %s", err)
+	}
+	err = ds.AddItemToFolder("QmFolderEmpty1", &Folder{IPNSAddress: ipns, Path: "a2"})
+	if err != nil {
+		t.Errorf("Unable to add item to folder. Error: %s", err)
+	}
+
+	for path, want := range map[string]bool{"a": false, "a/b": true, "a2": false, "c": true} {
+		empty, err := ds.IsFolderEmpty(&Folder{IPNSAddress: ipns, Path: path})
+		if err != nil {
+			t.Errorf("Unable to check folder. Error: %s", err)
+		}
+		if empty != want {
+			t.Errorf("IsFolderEmpty(%q) = %v; want %v", path, empty, want)
+		}
+	}
+
+	_, err = ds.IsFolderEmpty(&Folder{IPNSAddress: ipns, Path: "missing"})
+	if err != ErrFolderNotExists {
+		t.Errorf("Checking a missing folder should return ErrFolderNotExists. Actual %v", err)
+	}
+}
+
+func TestDeleteEmptyFolders(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "deleteempty.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Delete Empty"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	for _, p := range []string{"a/b/c", "d/e", "f", "z"} {
+		err = ds.CreateFolderAll(ipns, p)
+		if err != nil {
+			t.Errorf("Unable to create folders. Error: %s", err)
+		}
+	}
+	// z is only left empty after its linked child f is deleted
+	err = ds.LinkFolder(ipns, "f", "z")
+	if err != nil {
+		t.Errorf("Unable to link folder. Error: %s", err)
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmDeleteEmpty1", Name: "Delete Empty"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	err = ds.AddItemToCollection("QmDeleteEmpty1", ipns)
+	if err != nil {
+		t.Errorf("Unable to add item to collection. Error: %s", err)
+	}
+	err = ds.AddItemToFolder("QmDeleteEmpty1", &Folder{IPNSAddress: ipns, Path: "d"})
+	if err != nil {
+		t.Errorf("Unable to add item to folder. Error: %s", err)
+	}
+
+	n, err := ds.DeleteEmptyFolders(ipns)
+	if err != nil {
+		t.Errorf("Unable to delete empty folders. Error: %s", err)
+	}
+	if n != 6 {
+		t.Errorf("DeleteEmptyFolders = %d; want 6", n)
+	}
+
+	for path, want := range map[string]bool{"": true, "d": true, "d/e": false, "a": false, "a/b/c": false, "f": false, "z": false} {
+		exists, err := ds.IsFolderPathExists(ipns, path)
+		if err != nil {
+			t.Errorf("Unable to check folder. Error: %s", err)
+		}
+		if exists != want {
+			t.Errorf("Folder %q exists = %v; want %v", path, exists, want)
+		}
+	}
+	children, err := ds.ReadFolderChildren(&Folder{IPNSAddress: ipns})
+	if err != nil {
+		t.Errorf("Unable to read folder children. Error: %s", err)
+	}
+	if !reflect.DeepEqual(children, []string{"d"}) {
+		t.Errorf("Children of root = %v; want [d]", children)
+	}
+
+	_, err = ds.DeleteEmptyFolders("deleteemptymissing.com")
+	if err != ErrIPNSNotFound {
+		t.Errorf("Deleting folders of a missing collection should return ErrIPNSNotFound. Actual %v", err)
+	}
+}
+
+func TestAddItemToFolderCollection(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "folderitemcollection.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Folder Item Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	sub := &Folder{IPNSAddress: ipns, Path: "a/b"}
+	err = ds.CreateFolderAll(ipns, sub.Path)
+	if err != nil {
+		t.Errorf("Unable to create folders. Error: %s", err)
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmFolderItemCollection1", Name: "Folder Item Collection"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+
+	// Adding twice is fine
+	for i := 0; i < 2; i++ {
+		err = ds.AddItemToFolder("QmFolderItemCollection1", sub)
+		if err != nil {
+			t.Errorf("Unable to add item to folder. Error: %s", err)
+		}
+	}
+
+	cids, err := ds.ReadCollectionItems(ipns)
+	if err != nil {
+		t.Errorf("Unable to read collection items. Error: %s", err)
+	}
+	if !reflect.DeepEqual(cids, []string{"QmFolderItemCollection1"}) {
+		t.Errorf("Collection items = %v; want [QmFolderItemCollection1]", cids)
+	}
+
+	// The item isn't added to the root folder
+	isIn, err := ds.IsItemInFolder("QmFolderItemCollection1", &Folder{IPNSAddress: ipns})
+	if err != nil {
+		t.Errorf("Unable to check if item is in folder. Error: %s", err)
+	}
+	if isIn {
+		t.Error("Item should not be in the root folder.")
+	}
+}
+
+// countingLogger counts the logs of Badger.
+type countingLogger struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (l *countingLogger) log() {
+	l.mu.Lock()
+	l.n++
+	l.mu.Unlock()
+}
+
+func (l *countingLogger) Errorf(string, ...interface{})   { l.log() }
+func (l *countingLogger) Warningf(string, ...interface{}) { l.log() }
+func (l *countingLogger) Infof(string, ...interface{})    { l.log() }
+func (l *countingLogger) Debugf(string, ...interface{})   { l.log() }
+
+func TestLogger(t *testing.T) {
+	path := filepath.Join(testdataDir, "logger.db")
+	_ = os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	logger := &countingLogger{}
+	ds, err := NewDatastoreWithOptions(path, DefaultOptions().WithLogger(logger))
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	ds.Close()
+
+	logger.mu.Lock()
+	n := logger.n
+	logger.mu.Unlock()
+	if n == 0 {
+		t.Error("Logs of Badger should go to the logger.")
+	}
+
+	ds, err = NewDatastoreWithOptions(path, DefaultOptions().WithSilentLogging())
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	if DefaultOptions().WithSilentLogging().Logger != nil {
+		t.Error("Logger should be nil with silent logging.")
+	}
+}
+
+func TestReadItemTagStrings(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmTagStrings1", Name: "Tag Strings", Tags: []Tag{{"tagstrings", "b"}, {"tagstrings", "a"}}})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	// Its CID starts with the CID of the first item
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmTagStrings12", Name: "Tag Strings", Tags: []Tag{{"tagstrings", "c"}}})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+
+	tagStrs, err := ds.ReadItemTagStrings("QmTagStrings1")
+	if err != nil {
+		t.Errorf("Unable to read item tags. Error: %s", err)
+	}
+	if !reflect.DeepEqual(tagStrs, []string{"tagstrings:a", "tagstrings:b"}) {
+		t.Errorf("ReadItemTagStrings = %v; want [tagstrings:a tagstrings:b]", tagStrs)
+	}
+
+	_, err = ds.ReadItemTagStrings("QmTagStringsMissing")
+	if err != ErrCIDNotFound {
+		t.Errorf("Reading tags of a missing item should return ErrCIDNotFound. Actual %v", err)
+	}
+}
+
+func TestSetItemTags(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	a, b, c := Tag{"setitemtags", "a"}, Tag{"setitemtags", "b"}, Tag{"setitemtags", "c"}
+	item := &Item{CID: "QmSetItemTags1", Name: "Set Item Tags", Tags: []Tag{a, b}, PreviewCID: "QmSetItemTagsPreview"}
+	err = ds.CreateOrUpdateItem(item)
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+
+	err = ds.SetItemTags(item.CID, []Tag{b, c})
+	if err != nil {
+		t.Errorf("Unable to set item tags. Error: %s", err)
+	}
+
+	i, err := ds.ReadItem(item.CID)
+	if err != nil {
+		t.Fatalf("Unable to read item. Error: %s", err)
+	}
+	if !reflect.DeepEqual(i.Tags, []Tag{b, c}) {
+		t.Errorf("Tags = %v; want %v", i.Tags, []Tag{b, c})
+	}
+	if i.Name != item.Name || i.PreviewCID != item.PreviewCID {
+		t.Errorf("Other fields of the item should be untouched. Actual %+v", i)
+	}
+
+	counts, err := ds.ReadTagItemCount([]Tag{a, b, c})
+	if err != nil {
+		t.Errorf("Unable to read tag item count. Error: %s", err)
+	}
+	if !reflect.DeepEqual(counts, []uint{0, 1, 1}) {
+		t.Errorf("Tag item counts = %v; want [0 1 1]", counts)
+	}
+
+	err = ds.SetItemTags("QmSetItemTagsMissing", []Tag{a})
+	if err != ErrCIDNotFound {
+		t.Errorf("Setting tags of a missing item should return ErrCIDNotFound. Actual %v", err)
+	}
+}
+
+func TestItemViews(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	for _, cid := range []string{"QmViews1", "QmViews2", "QmViews3"} {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid})
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for n := 0; n < 4; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 5; k++ {
+				err := ds.IncrementItemViews("QmViews2")
+				if err != nil {
+					t.Errorf("Unable to increment views. Error: %s", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	err = ds.IncrementItemViews("QmViews1")
+	if err != nil {
+		t.Errorf("Unable to increment views. Error: %s", err)
+	}
+
+	items, err := ds.ReadMostViewed(10)
+	if err != nil {
+		t.Errorf("Unable to read most viewed items. Error: %s", err)
+	}
+	var cids []string
+	for _, i := range items {
+		cids = append(cids, i.CID)
+	}
+	if !reflect.DeepEqual(cids, []string{"QmViews2", "QmViews1"}) {
+		t.Errorf("ReadMostViewed = %v; want [QmViews2 QmViews1]", cids)
+	}
+
+	var views uint64
+	err = ds.db.View(func(txn *badger.Txn) error {
+		views, err = ds.readItemViewsInTxn(txn, "QmViews2")
+		return err
+	})
+	if err != nil {
+		t.Errorf("Unable to read views. Error: %s", err)
+	}
+	if views != 20 {
+		t.Errorf("Views = %d; want 20", views)
+	}
+
+	// Deleting an item clears its views
+	err = ds.DelItem("QmViews2")
+	if err != nil {
+		t.Errorf("Unable to delete item. Error: %s", err)
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmViews2", Name: "QmViews2"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	items, err = ds.ReadMostViewed(1)
+	if err != nil {
+		t.Errorf("Unable to read most viewed items. Error: %s", err)
+	}
+	if len(items) != 1 || items[0].CID != "QmViews1" {
+		t.Errorf("ReadMostViewed = %v; want [QmViews1]", items)
+	}
+
+	err = ds.IncrementItemViews("QmViewsMissing")
+	if err != ErrCIDNotFound {
+		t.Errorf("Incrementing views of a missing item should return ErrCIDNotFound. Actual %v", err)
+	}
+}
+
+func TestReorderFolderChildren(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "reorder.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Reorder"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	for _, p := range []string{"a", "b", "c"} {
+		err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: p})
+		if err != nil {
+			t.Errorf("Unable to create folder. Error: %s", err)
+		}
+	}
+
+	root := &Folder{IPNSAddress: ipns}
+	err = ds.ReorderFolderChildren(root, []string{"c", "a", "b"})
+	if err != nil {
+		t.Errorf("Unable to reorder folder children. Error: %s", err)
+	}
+	children, err := ds.ReadFolderChildren(root)
+	if err != nil {
+		t.Errorf("Unable to read folder children. Error: %s", err)
+	}
+	if !reflect.DeepEqual(children, []string{"c", "a", "b"}) {
+		t.Errorf("Children = %v; want [c a b]", children)
+	}
+
+	for _, order := range [][]string{{"c", "a"}, {"c", "a", "a"}, {"c", "a", "d"}} {
+		err = ds.ReorderFolderChildren(root, order)
+		if err != ErrInvalidOrder {
+			t.Errorf("Reordering children by %v should return ErrInvalidOrder. Actual %v", order, err)
+		}
+	}
+
+	err = ds.ReorderFolderChildren(&Folder{IPNSAddress: ipns, Path: "missing"}, nil)
+	if err != ErrFolderNotExists {
+		t.Errorf("Reordering children of a missing folder should return ErrFolderNotExists. Actual %v", err)
+	}
+}
+
+func TestSetFolderItemOrder(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "itemorder.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Item Order"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	folder := &Folder{IPNSAddress: ipns, Path: "a"}
+	err = ds.CreateOrUpdateFolder(folder)
+	if err != nil {
+		t.Errorf("Unable to create folder. Error: %s", err)
+	}
+	for _, cid := range []string{"QmItemOrder1", "QmItemOrder2", "QmItemOrder3", "QmItemOrder4"} {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid})
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+		if cid == "QmItemOrder4" {
+			continue
+		}
+		err = ds.AddItemToFolder(cid, folder)
+		if err != nil {
+			t.Errorf("Unable to add item to folder. Error: %s", err)
+		}
+	}
+
+	readItems := func(f *Folder, want []string) {
+		t.Helper()
+		cids, err := ds.ReadFolderItems(f)
+		if err != nil {
+			t.Errorf("Unable to read folder items. Error: %s", err)
+		}
+		if !reflect.DeepEqual(cids, want) {
+			t.Errorf("Folder items = %v; want %v", cids, want)
+		}
+	}
+
+	err = ds.SetFolderItemOrder(folder, []string{"QmItemOrder3", "QmItemOrder1"})
+	if err != nil {
+		t.Errorf("Unable to set folder item order. Error: %s", err)
+	}
+	readItems(folder, []string{"QmItemOrder3", "QmItemOrder1", "QmItemOrder2"})
+
+	// A removed item leaves the order, so it goes to the end when added back
+	err = ds.RemoveItemFromFolder("QmItemOrder3", folder)
+	if err != nil {
+		t.Errorf("Unable to remove item from folder. Error: %s", err)
+	}
+	readItems(folder, []string{"QmItemOrder1", "QmItemOrder2"})
+	err = ds.AddItemToFolder("QmItemOrder3", folder)
+	if err != nil {
+		t.Errorf("Unable to add item to folder. Error: %s", err)
+	}
+	readItems(folder, []string{"QmItemOrder1", "QmItemOrder2", "QmItemOrder3"})
+
+	// Removing an item from the collection also removes it from the order
+	err = ds.SetFolderItemOrder(folder, []string{"QmItemOrder3", "QmItemOrder1"})
+	if err != nil {
+		t.Errorf("Unable to set folder item order. Error: %s", err)
+	}
+	err = ds.RemoveItemFromCollection("QmItemOrder3", ipns)
+	if err != nil {
+		t.Errorf("Unable to remove item from collection. Error: %s", err)
+	}
+	readItems(folder, []string{"QmItemOrder1", "QmItemOrder2"})
+	err = ds.AddItemToFolder("QmItemOrder3", folder)
+	if err != nil {
+		t.Errorf("Unable to add item to folder. Error: %s", err)
+	}
+	readItems(folder, []string{"QmItemOrder1", "QmItemOrder2", "QmItemOrder3"})
+
+	err = ds.SetFolderItemOrder(folder, []string{"QmItemOrder1", "QmItemOrder4"})
+	if err != ErrItemNotInFolder {
+		t.Errorf("Ordering an item not in the folder should return ErrItemNotInFolder. Actual %v", err)
+	}
+	err = ds.SetFolderItemOrder(folder, []string{"QmItemOrder1", "QmItemOrder1"})
+	if err != ErrInvalidOrder {
+		t.Errorf("Ordering an item twice should return ErrInvalidOrder. Actual %v", err)
+	}
+
+	// The order moves with the folder
+	err = ds.SetFolderItemOrder(folder, []string{"QmItemOrder2"})
+	if err != nil {
+		t.Errorf("Unable to set folder item order. Error: %s", err)
+	}
+	moved := &Folder{IPNSAddress: ipns, Path: "b"}
+	err = ds.MoveOrCopyFolder(folder, moved, false)
+	if err != nil {
+		t.Errorf("Unable to move folder. Error: %s", err)
+	}
+	readItems(moved, []string{"QmItemOrder2", "QmItemOrder1", "QmItemOrder3"})
+	folder = moved
+	moved = &Folder{IPNSAddress: ipns, Path: "c"}
+	err = ds.MoveFolder(folder, moved)
+	if err != nil {
+		t.Errorf("Unable to move folder. Error: %s", err)
+	}
+	readItems(moved, []string{"QmItemOrder2", "QmItemOrder1", "QmItemOrder3"})
+
+	// A new folder at the path of a deleted one has no order
+	err = ds.DelFolder(moved)
+	if err != nil {
+		t.Errorf("Unable to delete folder. Error: %s", err)
+	}
+	err = ds.CreateOrUpdateFolder(moved)
+	if err != nil {
+		t.Errorf("Unable to create folder. Error: %s", err)
+	}
+	for _, cid := range []string{"QmItemOrder2", "QmItemOrder1"} {
+		err = ds.AddItemToFolder(cid, moved)
+		if err != nil {
+			t.Errorf("Unable to add item to folder. Error: %s", err)
+		}
+	}
+	readItems(moved, []string{"QmItemOrder1", "QmItemOrder2"})
+}
+
+func TestPing(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+
+	err = ds.Ping()
+	if err != nil {
+		t.Errorf("Unable to ping Datastore. Error: %s", err)
+	}
+
+	ds.Close()
+	err = ds.Ping()
+	if err != ErrClosed {
+		t.Errorf("Pinging a closed Datastore should return ErrClosed. Actual %v", err)
+	}
+}
+
+func TestDeleteTag(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	del, keep := Tag{"deletetag", "old"}, Tag{"deletetag", "keep"}
+	for _, cid := range []string{"QmDeleteTag1", "QmDeleteTag2"} {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid, Tags: []Tag{del, keep}})
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+		// Cache the item
+		_, err = ds.ReadItem(cid)
+		if err != nil {
+			t.Errorf("Unable to read item. Error: %s", err)
+		}
+	}
+
+	err = ds.DeleteTag(del)
+	if err != nil {
+		t.Errorf("Unable to delete tag. Error: %s", err)
+	}
+
+	for _, cid := range []string{"QmDeleteTag1", "QmDeleteTag2"} {
+		i, err := ds.ReadItem(cid)
+		if err != nil {
+			t.Errorf("Unable to read item. Error: %s", err)
+			continue
+		}
+		if !reflect.DeepEqual(i.Tags, []Tag{keep}) {
+			t.Errorf("Tags of %s = %v; want [%v]", cid, i.Tags, keep)
+		}
+	}
+	tags, err := ds.SearchTags("deletetag")
+	if err != nil {
+		t.Errorf("Unable to search tags. Error: %s", err)
+	}
+	if !reflect.DeepEqual(tags, []Tag{keep}) {
+		t.Errorf("SearchTags = %v; want [%v]", tags, keep)
+	}
+	counts, err := ds.ReadTagItemCount([]Tag{del, keep})
+	if err != nil {
+		t.Errorf("Unable to read tag item count. Error: %s", err)
+	}
+	if !reflect.DeepEqual(counts, []uint{0, 2}) {
+		t.Errorf("Tag item counts = %v; want [0 2]", counts)
+	}
+
+	err = ds.DeleteTag(Tag{"deletetag", "missing"})
+	if err != nil {
+		t.Errorf("Deleting a missing tag should be a no-op. Error: %s", err)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	path := filepath.Join(testdataDir, "namespace.db")
+	_ = os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	ds, err := NewDatastoreWithOptions(path, DefaultOptions().WithNamespace("staging"))
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	ipns := "namespace.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Staging"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmNamespace1", Name: "Namespace", Tags: []Tag{{"namespace", "tag"}}})
+	if err != nil {
+		t.Errorf("Unable to create Item. Error: %s", err)
+	}
+	err = ds.AddItemToCollection("QmNamespace1", ipns)
+	if err != nil {
+		t.Errorf("Unable to add Item to Collection. Error: %s", err)
+	}
+	ds.Close()
+
+	for _, ns := range []string{"prod", ""} {
+		ds, err = NewDatastoreWithOptions(path, DefaultOptions().WithNamespace(ns))
+		if err != nil {
+			t.Fatalf("Unable to create Datastore. Error: %s", err)
+		}
+		_, err = ds.ReadCollection(ipns)
+		if err != ErrIPNSNotFound {
+			t.Errorf("Collection of another namespace should not be found in namespace %q. Actual %v", ns, err)
+		}
+		tags, err := ds.SearchTags("namespace")
+		if err != nil {
+			t.Errorf("Unable to search tags. Error: %s", err)
+		}
+		if len(tags) != 0 {
+			t.Errorf("Tags of another namespace should not be found in namespace %q. Actual %v", ns, tags)
+		}
+
+		err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Other"})
+		if err != nil {
+			t.Errorf("Unable to create Collection. Error: %s", err)
+		}
+		if ns != "" {
+			err = ds.DropAll()
+			if err != nil {
+				t.Errorf("Unable to drop all. Error: %s", err)
+			}
+		}
+		ds.Close()
+	}
+
+	ds, err = NewDatastoreWithOptions(path, DefaultOptions().WithNamespace("staging"))
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	c, err := ds.ReadCollection(ipns)
+	if err != nil {
+		t.Errorf("Unable to read Collection. Error: %s", err)
+	} else if c.Name != "Staging" {
+		t.Errorf("Collection name = %q; want %q", c.Name, "Staging")
+	}
+	cids, err := ds.ReadCollectionItems(ipns)
+	if err != nil {
+		t.Errorf("Unable to read Collection items. Error: %s", err)
+	}
+	if !reflect.DeepEqual(cids, []string{"QmNamespace1"}) {
+		t.Errorf("Collection items = %v; want [QmNamespace1]", cids)
+	}
+	keys, err := ds.DebugScan("items::", 10)
+	if err != nil {
+		t.Errorf("Unable to scan keys. Error: %s", err)
+	}
+	if !reflect.DeepEqual(keys, []string{"items::QmNamespace1"}) {
+		t.Errorf("DebugScan = %v; want [items::QmNamespace1]", keys)
+	}
+}
+
+func TestNamespaceSharedDB(t *testing.T) {
+	path := filepath.Join(testdataDir, "namespace_shared.db")
+	_ = os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	db, err := badger.Open(badger.DefaultOptions(path).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Unable to open Badger DB. Error: %s", err)
+	}
+	defer db.Close()
+
+	staging := NewDatastoreWithDB(db, DefaultOptions().WithNamespace("staging"))
+	defer staging.Close()
+	prod := NewDatastoreWithDB(db, DefaultOptions().WithNamespace("prod"))
+	defer prod.Close()
+
+	ipns := "shared.com"
+	for _, ds := range []*Datastore{staging, prod} {
+		err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: ds.namespace})
+		if err != nil {
+			t.Errorf("Unable to create Collection. Error: %s", err)
+		}
+	}
+	err = staging.CreateOrUpdateItem(&Item{CID: "QmShared1", Name: "Shared", Tags: []Tag{{"shared"}}})
+	if err != nil {
+		t.Errorf("Unable to create Item. Error: %s", err)
+	}
+
+	for _, ds := range []*Datastore{staging, prod} {
+		c, err := ds.ReadCollection(ipns)
+		if err != nil {
+			t.Errorf("Unable to read Collection. Error: %s", err)
+		} else if c.Name != ds.namespace {
+			t.Errorf("Collection name in namespace %q = %q", ds.namespace, c.Name)
+		}
+	}
+	_, err = prod.ReadItem("QmShared1")
+	if err != ErrCIDNotFound {
+		t.Errorf("Item of another namespace should not be found. Actual %v", err)
+	}
+	n, err := prod.ReadTagItemCount([]Tag{{"shared"}})
+	if err != nil {
+		t.Errorf("Unable to read tag item count. Error: %s", err)
+	}
+	if n[0] != 0 {
+		t.Errorf("Tag of another namespace should have no items. Actual %d", n[0])
+	}
+
+	// Dropping or closing one namespace leaves the other one and the Badger DB usable
+	err = prod.DropAll()
+	if err != nil {
+		t.Errorf("Unable to drop all. Error: %s", err)
+	}
+	err = prod.Close()
+	if err != nil {
+		t.Errorf("Unable to close Datastore. Error: %s", err)
+	}
+	_, err = prod.ReadCollection(ipns)
+	if err != ErrClosed {
+		t.Errorf("Reading a closed Datastore should return ErrClosed. Actual %v", err)
+	}
+	item, err := staging.ReadItem("QmShared1")
+	if err != nil {
+		t.Errorf("Unable to read Item. Error: %s", err)
+	} else if item.Name != "Shared" {
+		t.Errorf("Item name = %q; want %q", item.Name, "Shared")
+	}
+
+	prod = NewDatastoreWithDB(db, DefaultOptions().WithNamespace("prod"))
+	defer prod.Close()
+	_, err = prod.ReadCollection(ipns)
+	if err != ErrIPNSNotFound {
+		t.Errorf("Collection of a dropped namespace should be gone. Actual %v", err)
+	}
+}
+
+func TestMoveItemToTopAndBottom(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "movetotop.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Move To Top"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	folder := &Folder{IPNSAddress: ipns, Path: "a"}
+	err = ds.CreateOrUpdateFolder(folder)
+	if err != nil {
+		t.Errorf("Unable to create folder. Error: %s", err)
+	}
+	for _, cid := range []string{"QmMoveTop1", "QmMoveTop2", "QmMoveTop3", "QmMoveTop4"} {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid})
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+		if cid == "QmMoveTop4" {
+			continue
+		}
+		err = ds.AddItemToFolder(cid, folder)
+		if err != nil {
+			t.Errorf("Unable to add item to folder. Error: %s", err)
+		}
+	}
+
+	readItems := func(want []string) {
+		t.Helper()
+		cids, err := ds.ReadFolderItems(folder)
+		if err != nil {
+			t.Errorf("Unable to read folder items. Error: %s", err)
+		}
+		if !reflect.DeepEqual(cids, want) {
+			t.Errorf("Folder items = %v; want %v", cids, want)
+		}
+	}
+
+	err = ds.MoveItemToTop(folder, "QmMoveTop3")
+	if err != nil {
+		t.Errorf("Unable to move item to top. Error: %s", err)
+	}
+	readItems([]string{"QmMoveTop3", "QmMoveTop1", "QmMoveTop2"})
+
+	err = ds.MoveItemToBottom(folder, "QmMoveTop1")
+	if err != nil {
+		t.Errorf("Unable to move item to bottom. Error: %s", err)
+	}
+	readItems([]string{"QmMoveTop3", "QmMoveTop2", "QmMoveTop1"})
+
+	// Moving the top item to the top keeps the order
+	err = ds.MoveItemToTop(folder, "QmMoveTop3")
+	if err != nil {
+		t.Errorf("Unable to move item to top. Error: %s", err)
+	}
+	readItems([]string{"QmMoveTop3", "QmMoveTop2", "QmMoveTop1"})
+
+	err = ds.MoveItemToTop(folder, "QmMoveTop4")
+	if err != ErrItemNotInFolder {
+		t.Errorf("Moving an item not in the folder should return ErrItemNotInFolder. Actual %v", err)
+	}
+	err = ds.MoveItemToBottom(&Folder{IPNSAddress: ipns, Path: "missing"}, "QmMoveTop1")
+	if err != ErrFolderNotExists {
+		t.Errorf("Moving an item in a missing folder should return ErrFolderNotExists. Actual %v", err)
+	}
+}
+
+func TestReadItemName(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmItemName1", Name: "Item Name", Tags: []Tag{{"itemname"}}})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+
+	name, err := ds.ReadItemName("QmItemName1")
+	if err != nil {
+		t.Errorf("Unable to read item name. Error: %s", err)
+	}
+	if name != "Item Name" {
+		t.Errorf("Item name = %q; want %q", name, "Item Name")
+	}
+
+	_, err = ds.ReadItemName("QmItemNameMissing")
+	if err != ErrCIDNotFound {
+		t.Errorf("Reading the name of a missing item should return ErrCIDNotFound. Actual %v", err)
+	}
+}
+
+func TestTagCountsByPrefix(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	items := []*Item{
+		{CID: "QmTagCounts1", Name: "Tag Counts 1", Tags: []Tag{{"tagcounts", "a"}, {"tagcounts", "b"}}},
+		{CID: "QmTagCounts2", Name: "Tag Counts 2", Tags: []Tag{{"tagcounts", "a"}, {"tagcountsx"}}},
+	}
+	for _, item := range items {
+		err = ds.CreateOrUpdateItem(item)
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+	}
+
+	counts, err := ds.TagCountsByPrefix("tagcounts:")
+	if err != nil {
+		t.Errorf("Unable to read tag counts. Error: %s", err)
+	}
+	expected := map[string]uint{"tagcounts:a": 2, "tagcounts:b": 1}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Errorf("TagCountsByPrefix = %v; want %v", counts, expected)
+	}
+
+	counts, err = ds.TagCountsByPrefix("")
+	if err != nil {
+		t.Errorf("Unable to read tag counts. Error: %s", err)
+	}
+	if counts["tagcounts:a"] != 2 || counts["tagcountsx"] != 1 {
+		t.Errorf("TagCountsByPrefix with an empty prefix should return all tags. Actual %v", counts)
+	}
+}
+
+func TestSwapFolderItems(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "swapitems.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Swap Items"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	folder := &Folder{IPNSAddress: ipns, Path: "a"}
+	err = ds.CreateOrUpdateFolder(folder)
+	if err != nil {
+		t.Errorf("Unable to create folder. Error: %s", err)
+	}
+	for _, cid := range []string{"QmSwap1", "QmSwap2", "QmSwap3", "QmSwap4"} {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid})
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+		if cid == "QmSwap4" {
+			continue
+		}
+		err = ds.AddItemToFolder(cid, folder)
+		if err != nil {
+			t.Errorf("Unable to add item to folder. Error: %s", err)
+		}
+	}
+
+	err = ds.SwapFolderItems(folder, "QmSwap1", "QmSwap3")
+	if err != nil {
+		t.Errorf("Unable to swap folder items. Error: %s", err)
+	}
+	cids, err := ds.ReadFolderItems(folder)
+	if err != nil {
+		t.Errorf("Unable to read folder items. Error: %s", err)
+	}
+	expected := []string{"QmSwap3", "QmSwap2", "QmSwap1"}
+	if !reflect.DeepEqual(cids, expected) {
+		t.Errorf("Folder items = %v; want %v", cids, expected)
+	}
+
+	err = ds.SwapFolderItems(folder, "QmSwap1", "QmSwap4")
+	if err != ErrItemNotInFolder {
+		t.Errorf("Swapping an item not in the folder should return ErrItemNotInFolder. Actual %v", err)
+	}
+	cids, err = ds.ReadFolderItems(folder)
+	if err != nil {
+		t.Errorf("Unable to read folder items. Error: %s", err)
+	}
+	if !reflect.DeepEqual(cids, expected) {
+		t.Errorf("A failed swap should not change the order. Folder items = %v; want %v", cids, expected)
+	}
+}
+
+func TestItemLocations(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	for _, ipns := range []string{"locations2.com", "locations1.com"} {
+		err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: ipns})
+		if err != nil {
+			t.Errorf("Unable to create Collection. Error: %s", err)
+		}
+	}
+	for _, cid := range []string{"QmLocations1", "QmLocations2"} {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid})
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+	}
+	err = ds.CreateFolderAll("locations1.com", "a/b")
+	if err != nil {
+		t.Errorf("Unable to create folders. Error: %s", err)
+	}
+	folders := []*Folder{
+		{IPNSAddress: "locations2.com", Path: ""},
+		{IPNSAddress: "locations1.com", Path: "a/b"},
+		{IPNSAddress: "locations1.com", Path: "a"},
+	}
+	for _, f := range folders {
+		err = ds.AddItemToFolder("QmLocations1", f)
+		if err != nil {
+			t.Errorf("Unable to add item to folder. Error: %s", err)
+		}
+	}
+
+	locs, err := ds.ItemLocations("QmLocations1")
+	if err != nil {
+		t.Errorf("Unable to read item locations. Error: %s", err)
+	}
+	expected := []ItemLocation{
+		{IPNS: "locations1.com", Path: "a"},
+		{IPNS: "locations1.com", Path: "a/b"},
+		{IPNS: "locations2.com", Path: ""},
+	}
+	if !reflect.DeepEqual(locs, expected) {
+		t.Errorf("ItemLocations = %v; want %v", locs, expected)
+	}
+
+	locs, err = ds.ItemLocations("QmLocations2")
+	if err != nil {
+		t.Errorf("Unable to read item locations. Error: %s", err)
+	}
+	if locs == nil || len(locs) != 0 {
+		t.Errorf("Item in no folder should have an empty slice of locations. Actual %#v", locs)
+	}
+
+	_, err = ds.ItemLocations("QmLocationsMissing")
+	if err != ErrCIDNotFound {
+		t.Errorf("Reading locations of a missing item should return ErrCIDNotFound. Actual %v", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	path := filepath.Join(testdataDir, "close.db")
+	_ = os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	ds, err := NewDatastore(path)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmClose1", Name: "Close"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	// Cache the item
+	_, err = ds.ReadItem("QmClose1")
+	if err != nil {
+		t.Errorf("Unable to read item. Error: %s", err)
+	}
+
+	// Operations running while Datastore is closed either finish or return ErrClosed
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				err := ds.CreateOrUpdateItem(&Item{CID: fmt.Sprintf("QmCloseConcurrent%d-%d", i, j), Name: "Concurrent"})
+				if err == ErrClosed {
+					return
+				}
+				if err != nil {
+					t.Errorf("Unable to create item. Error: %s", err)
+					return
+				}
+			}
+		}(i)
+	}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := ds.Close()
+			if err != nil {
+				t.Errorf("Unable to close Datastore. Error: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	err = ds.Close()
+	if err != nil {
+		t.Errorf("Closing a closed Datastore should not fail. Error: %s", err)
+	}
+
+	_, err = ds.ReadItem("QmClose1")
+	if err != ErrClosed {
+		t.Errorf("Reading a cached item from a closed Datastore should return ErrClosed. Actual %v", err)
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmClose2", Name: "Close"})
+	if err != ErrClosed {
+		t.Errorf("Writing to a closed Datastore should return ErrClosed. Actual %v", err)
+	}
+	err = ds.Update(func(tx *Tx) error {
+		return nil
+	})
+	if err != ErrClosed {
+		t.Errorf("Updating a closed Datastore should return ErrClosed. Actual %v", err)
+	}
+}
+
+func TestCloseDuringMoveOrCopyFolder(t *testing.T) {
+	path := filepath.Join(testdataDir, "close_copy.db")
+	_ = os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	ds, err := NewDatastore(path)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+
+	// One collection for each goroutine, so the copies don't conflict
+	const n = 4
+	for i := 0; i < n; i++ {
+		ipns := fmt.Sprintf("closecopy%d.com", i)
+		err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Close Copy"})
+		if err != nil {
+			t.Errorf("Unable to create Collection. Error: %s", err)
+		}
+		err = ds.CreateFolderAll(ipns, "from/a/b")
+		if err != nil {
+			t.Errorf("Unable to create folders. Error: %s", err)
+		}
+		for j := 0; j < 50; j++ {
+			cid := fmt.Sprintf("QmCloseCopy%d-%d", i, j)
+			err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid})
+			if err != nil {
+				t.Errorf("Unable to create item. Error: %s", err)
+			}
+			// Items in sub folders make the copy check their destinations after a while
+			for _, p := range []string{"from", "from/a", "from/a/b"} {
+				err = ds.AddItemToFolder(cid, &Folder{IPNSAddress: ipns, Path: p})
+				if err != nil {
+					t.Errorf("Unable to add item to folder. Error: %s", err)
+				}
+			}
+		}
+	}
+
+	var wg, warm sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		warm.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ipns := fmt.Sprintf("closecopy%d.com", i)
+			from := &Folder{IPNSAddress: ipns, Path: "from"}
+			for j := 0; ; j++ {
+				err := ds.MoveOrCopyFolder(from, &Folder{IPNSAddress: ipns, Path: fmt.Sprintf("to%d", j)}, true)
+				if j == 0 {
+					warm.Done()
+				}
+				if err == ErrClosed {
+					return
+				}
+				if err != nil {
+					t.Errorf("Unable to copy folder. Error: %s", err)
+					return
+				}
+			}
+		}(i)
+	}
+	warm.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		err := ds.Close()
+		if err != nil {
+			t.Errorf("Unable to close Datastore. Error: %s", err)
+		}
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Closing Datastore while copying folders deadlocked.")
+	}
+}
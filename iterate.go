@@ -0,0 +1,75 @@
+package resource
+
+import (
+	"github.com/dgraph-io/badger"
+)
+
+// IterateCollectionItems calls fn with the CID of every item in a collection, in a single read transaction.
+// Iteration stops when fn returns an error, which is returned unless it's ErrStopIteration.
+func (d *Datastore) IterateCollectionItems(ipns string, fn func(cid string) error) error {
+	return d.view(func(txn *badger.Txn) error {
+		err := d.checkIPNSInTxn(txn, ipns)
+		if err != nil {
+			return err
+		}
+
+		// collection_item::[ipns]::[cid]
+		return d.iterateKeysInTxn(txn, dbKey{"collection_item", ipns, ""}, func(k dbKey) error {
+			return fn(k[2])
+		})
+	})
+}
+
+// IterateFolderItems calls fn with the CID of every item in a folder, in a single read transaction.
+// Iteration stops when fn returns an error, which is returned unless it's ErrStopIteration.
+func (d *Datastore) IterateFolderItems(folder *Folder, fn func(cid string) error) error {
+	folder, err := normalizeFolder(folder)
+	if err != nil {
+		return err
+	}
+
+	return d.view(func(txn *badger.Txn) error {
+		exists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, folder.Path)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrFolderNotExists
+		}
+
+		// folder_item::[ipns]::[folderPath]::[cid]
+		return d.iterateKeysInTxn(txn, dbKey{"folder_item", folder.IPNSAddress, folder.Path, ""}, func(k dbKey) error {
+			return fn(k[3])
+		})
+	})
+}
+
+// IterateTags calls fn with every tag in Datastore, in a single read transaction.
+// Iteration stops when fn returns an error, which is returned unless it's ErrStopIteration.
+func (d *Datastore) IterateTags(fn func(t Tag) error) error {
+	return d.view(func(txn *badger.Txn) error {
+		// tags::[tagStr]
+		return d.iterateKeysInTxn(txn, dbKey{"tags", ""}, func(k dbKey) error {
+			return fn(NewTagFromStr(k[1]))
+		})
+	})
+}
+
+// iterateKeysInTxn calls fn with every key with prefix. ErrStopIteration returned by fn stops iterating without an error.
+func (d *Datastore) iterateKeysInTxn(txn *badger.Txn, prefix dbKey, fn func(k dbKey) error) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek(d.key(prefix)); it.ValidForPrefix(d.key(prefix)); it.Next() {
+		err := fn(d.parseKey(it.Item().Key()))
+		if err == ErrStopIteration {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
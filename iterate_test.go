@@ -0,0 +1,68 @@
+package resource
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIterateCollectionItems(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "iterate.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Iterate Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+
+	for _, cid := range []string{"QmIterate1", "QmIterate2", "QmIterate3"} {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: "Iterate Item"})
+		if err != nil {
+			t.Errorf("Unable to create Item. Error: %s", err)
+		}
+		err = ds.AddItemToCollection(cid, ipns)
+		if err != nil {
+			t.Errorf("Unable to add Item to Collection. Error: %s", err)
+		}
+	}
+
+	var cids []string
+	err = ds.IterateCollectionItems(ipns, func(cid string) error {
+		cids = append(cids, cid)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Unable to iterate collection items. Error: %s", err)
+	}
+	if len(cids) != 3 {
+		t.Errorf("Expect 3 items. Actual %v", cids)
+	}
+
+	// Stop early
+	count := 0
+	err = ds.IterateFolderItems(&Folder{IPNSAddress: ipns}, func(cid string) error {
+		count++
+		if count == 2 {
+			return ErrStopIteration
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("ErrStopIteration should not be returned. Error: %s", err)
+	}
+	if count != 2 {
+		t.Errorf("Expect 2 iterations. Actual %d", count)
+	}
+
+	// Propagate callback error
+	errCallback := errors.New("callback")
+	err = ds.IterateCollectionItems(ipns, func(cid string) error {
+		return errCallback
+	})
+	if err != errCallback {
+		t.Errorf("Callback error should be returned. Actual %v", err)
+	}
+}
@@ -0,0 +1,327 @@
+package resource
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/badger"
+)
+
+// ManifestVersion is the version of manifests written by ExportCollectionManifest.
+const ManifestVersion = 1
+
+// CollectionManifest is a JSON document describing a collection, its folder tree and its items.
+// All lists are sorted, so exporting unchanged data always yields the same bytes.
+type CollectionManifest struct {
+	Version     int    `json:"version"`
+	IPNSAddress string `json:"ipns"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Root is the path of the exported folder for a manifest made by ExportFolderManifest. Folder paths are relative to it.
+	Root    string           `json:"root,omitempty"`
+	Folders []FolderManifest `json:"folders"`
+	Items   []ItemManifest   `json:"items"`
+}
+
+// FolderManifest describes a folder in a CollectionManifest.
+type FolderManifest struct {
+	Path string `json:"path"`
+	// Links are paths of parent folders the folder is linked to by LinkFolder.
+	Links []string `json:"links,omitempty"`
+	// Items are CIDs of items in the folder.
+	Items []string `json:"items,omitempty"`
+}
+
+// ItemManifest describes an item in a CollectionManifest.
+type ItemManifest struct {
+	CID         string   `json:"cid"`
+	Name        string   `json:"name"`
+	PreviewCID  string   `json:"preview,omitempty"`
+	Size        int64    `json:"size,omitempty"`
+	ContentType string   `json:"content_type,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// ExportCollectionManifest exports a collection as a JSON CollectionManifest.
+func (d *Datastore) ExportCollectionManifest(ipns string) ([]byte, error) {
+	var m *CollectionManifest
+	err := d.view(func(txn *badger.Txn) error {
+		var err error
+		m, err = d.readCollectionManifestInTxn(txn, ipns)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(m)
+}
+
+func (d *Datastore) readCollectionManifestInTxn(txn *badger.Txn, ipns string) (*CollectionManifest, error) {
+	return d.readManifestInTxn(txn, ipns, "")
+}
+
+// readManifestInTxn reads the manifest of the folder tree rooted at root. Paths in the manifest are relative to root.
+// Only items in the tree are included.
+func (d *Datastore) readManifestInTxn(txn *badger.Txn, ipns, root string) (*CollectionManifest, error) {
+	c, err := d.readCollectionInTxn(txn, ipns)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &CollectionManifest{
+		Version:     ManifestVersion,
+		IPNSAddress: c.IPNSAddress,
+		Name:        c.Name,
+		Description: c.Description,
+		Root:        root,
+		Folders:     []FolderManifest{},
+		Items:       []ItemManifest{},
+	}
+
+	cids := make(map[string]bool)
+	// folders::[ipns]::[folderPath]
+	for _, k := range d.readKeysInTxn(txn, dbKey{"folders", ipns, ""}) {
+		rel, ok := relFolderPath(k[2], root)
+		if !ok {
+			continue
+		}
+		f := FolderManifest{Path: rel}
+
+		links, err := d.readPathListInTxn(txn, dbKey{"folder", ipns, k[2], "links"})
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range links {
+			// Links to parents outside of the tree are dropped
+			if rl, ok := relFolderPath(l, root); ok {
+				f.Links = append(f.Links, rl)
+			}
+		}
+		sort.Strings(f.Links)
+
+		// folder_item::[ipns]::[folderPath]::[cid]
+		for _, ik := range d.readKeysInTxn(txn, dbKey{"folder_item", ipns, k[2], ""}) {
+			f.Items = append(f.Items, ik[3])
+			cids[ik[3]] = true
+		}
+		sort.Strings(f.Items)
+
+		m.Folders = append(m.Folders, f)
+	}
+	sort.Slice(m.Folders, func(i, j int) bool { return m.Folders[i].Path < m.Folders[j].Path })
+
+	if root == "" {
+		// Items of the collection which are in no folder are still part of the collection
+		// collection_item::[ipns]::[cid]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"collection_item", ipns, ""}) {
+			cids[k[2]] = true
+		}
+	}
+
+	for cid := range cids {
+		item, err := d.readItemInTxn(txn, cid)
+		if err != nil {
+			return nil, err
+		}
+
+		im := ItemManifest{CID: item.CID, Name: item.Name, PreviewCID: item.PreviewCID, Size: item.Size, ContentType: item.ContentType}
+		for _, t := range item.Tags {
+			im.Tags = append(im.Tags, t.String())
+		}
+		sort.Strings(im.Tags)
+
+		m.Items = append(m.Items, im)
+	}
+	sort.Slice(m.Items, func(i, j int) bool { return m.Items[i].CID < m.Items[j].CID })
+
+	return m, nil
+}
+
+// relFolderPath returns path relative to root, and false if path isn't root or under it.
+func relFolderPath(path, root string) (string, bool) {
+	if root == "" {
+		return path, true
+	}
+	if !isPathUnder(path, root) {
+		return "", false
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(path, root), "/"), true
+}
+
+// ExportFolderManifest exports the folder tree rooted at a folder as a JSON CollectionManifest.
+// Folder paths in the manifest are relative to the folder, and only items in the tree are included.
+// ErrFolderNotExists is returned if the folder doesn't exist.
+func (d *Datastore) ExportFolderManifest(folder *Folder) ([]byte, error) {
+	folder, err := normalizeFolder(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	var m *CollectionManifest
+	err = d.view(func(txn *badger.Txn) error {
+		exists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, folder.Path)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrFolderNotExists
+		}
+
+		m, err = d.readManifestInTxn(txn, folder.IPNSAddress, folder.Path)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(m)
+}
+
+// ImportCollectionManifest recreates a collection, its folder tree and its items from a manifest made by ExportCollectionManifest.
+// Existing items keep their information and get the tags of the manifest merged in.
+// ErrUnsupportedManifestVersion is returned if the manifest version is unknown. Folder manifests made by
+// ExportFolderManifest can't be imported and ErrInvalidManifest is returned, as it is for items without a CID or a name.
+func (d *Datastore) ImportCollectionManifest(data []byte) error {
+	var m CollectionManifest
+	err := json.Unmarshal(data, &m)
+	if err != nil {
+		return err
+	}
+	if m.Version != ManifestVersion {
+		return ErrUnsupportedManifestVersion
+	}
+	if m.IPNSAddress == "" || m.Root != "" {
+		return ErrInvalidManifest
+	}
+	for _, im := range m.Items {
+		if im.CID == "" || im.Name == "" {
+			return ErrInvalidManifest
+		}
+	}
+
+	keys := []string{collectionCacheKey(m.IPNSAddress)}
+	for _, im := range m.Items {
+		keys = append(keys, itemCacheKey(im.CID))
+	}
+	defer d.cache.remove(keys...)
+
+	return d.update(func(txn *badger.Txn) error {
+		return d.importCollectionManifestInTxn(txn, &m)
+	})
+}
+
+func (d *Datastore) importCollectionManifestInTxn(txn *badger.Txn, m *CollectionManifest) error {
+	ipns := m.IPNSAddress
+
+	c := &Collection{IPNSAddress: ipns, Name: m.Name, Description: m.Description}
+	old, err := d.readCollectionInTxn(txn, ipns)
+	if err != nil && err != ErrIPNSNotFound {
+		return err
+	}
+	if old != nil {
+		c.IsMine = old.IsMine
+		c.Published = old.Published
+		c.PublishedCID = old.PublishedCID
+	}
+	// A draft collection made by CreateEmptyCollection has an empty name
+	err = d.writeCollectionInTxn(txn, c)
+	if err != nil {
+		return err
+	}
+
+	// Parents sort before their children
+	folders := append([]FolderManifest{}, m.Folders...)
+	sort.Slice(folders, func(i, j int) bool { return folders[i].Path < folders[j].Path })
+	for _, f := range folders {
+		exists, err := d.isFolderPathExistsInTxn(txn, ipns, f.Path)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			err = d.createOrUpdateFolderInTxn(txn, &Folder{IPNSAddress: ipns, Path: f.Path})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range folders {
+		for _, parentPath := range f.Links {
+			err = d.linkFolderInTxn(txn, ipns, f.Path, parentPath)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	inRoot := make(map[string]bool)
+	for _, f := range folders {
+		if f.Path == "" {
+			for _, cid := range f.Items {
+				inRoot[cid] = true
+			}
+		}
+	}
+
+	for _, im := range m.Items {
+		err = d.importItemInTxn(txn, &im)
+		if err != nil {
+			return err
+		}
+
+		inCollection, err := d.isItemInCollectionInTxn(txn, im.CID, ipns)
+		if err != nil {
+			return err
+		}
+		if inCollection {
+			continue
+		}
+		err = d.addItemToCollectionInTxn(txn, im.CID, ipns, inRoot[im.CID])
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, f := range folders {
+		for _, cid := range f.Items {
+			err = d.addItemToFolderInTxn(txn, cid, &Folder{IPNSAddress: ipns, Path: f.Path})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// importItemInTxn creates an item of a manifest. If the item exists, only its tags are merged.
+func (d *Datastore) importItemInTxn(txn *badger.Txn, im *ItemManifest) error {
+	var tags []Tag
+	for _, tagStr := range im.Tags {
+		tags = append(tags, NewTagFromStr(tagStr))
+	}
+
+	err := d.checkCIDInTxn(txn, im.CID)
+	if err == ErrCIDNotFound {
+		return d.createOrUpdateItemInTxn(txn, &Item{
+			CID:         im.CID,
+			Name:        im.Name,
+			Tags:        tags,
+			PreviewCID:  im.PreviewCID,
+			Size:        im.Size,
+			ContentType: im.ContentType,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tags {
+		err = d.addItemTagInTxn(txn, im.CID, t)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package resource
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestExportCollectionManifest(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "manifest.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Manifest", Description: "Manifest Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	err = ds.CreateFolderAll(ipns, "b/c")
+	if err != nil {
+		t.Errorf("Unable to create folders. Error: %s", err)
+	}
+	err = ds.CreateFolderAll(ipns, "a")
+	if err != nil {
+		t.Errorf("Unable to create folders. Error: %s", err)
+	}
+
+	items := []*Item{
+		{CID: "QmManifest2", Name: "Manifest 2", Tags: []Tag{{"manifest", "z"}, {"manifest", "a"}}},
+		{CID: "QmManifest1", Name: "Manifest 1", Size: 42},
+	}
+	for _, item := range items {
+		err = ds.CreateOrUpdateItem(item)
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+		err = ds.AddItemToCollection(item.CID, ipns)
+		if err != nil {
+			t.Errorf("Unable to add item to collection. Error: %s", err)
+		}
+	}
+	err = ds.AddItemToFolder("QmManifest2", &Folder{IPNSAddress: ipns, Path: "b/c"})
+	if err != nil {
+		t.Errorf("Unable to add item to folder. Error: %s", err)
+	}
+
+	data, err := ds.ExportCollectionManifest(ipns)
+	if err != nil {
+		t.Fatalf("Unable to export manifest. Error: %s", err)
+	}
+
+	var m CollectionManifest
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		t.Fatalf("Unable to decode manifest. Error: %s", err)
+	}
+	if m.Version != ManifestVersion || m.Name != "Manifest" || m.Description != "Manifest Collection" {
+		t.Errorf("Wrong manifest header: %+v", m)
+	}
+
+	var paths []string
+	for _, f := range m.Folders {
+		paths = append(paths, f.Path)
+	}
+	if len(paths) != 4 || paths[0] != "" || paths[1] != "a" || paths[2] != "b" || paths[3] != "b/c" {
+		t.Errorf("Manifest folders = %v; want [ a b b/c]", paths)
+	}
+	if len(m.Folders[3].Items) != 1 || m.Folders[3].Items[0] != "QmManifest2" {
+		t.Errorf("Items of b/c = %v; want [QmManifest2]", m.Folders[3].Items)
+	}
+
+	if len(m.Items) != 2 || m.Items[0].CID != "QmManifest1" || m.Items[0].Size != 42 {
+		t.Errorf("Wrong manifest items: %+v", m.Items)
+	}
+	if len(m.Items) == 2 && (len(m.Items[1].Tags) != 2 || m.Items[1].Tags[0] != "manifest:a") {
+		t.Errorf("Manifest tags = %v; want [manifest:a manifest:z]", m.Items[1].Tags)
+	}
+
+	// Exporting unchanged data yields the same bytes
+	data2, err := ds.ExportCollectionManifest(ipns)
+	if err != nil {
+		t.Fatalf("Unable to export manifest. Error: %s", err)
+	}
+	if !bytes.Equal(data, data2) {
+		t.Error("Manifest should be deterministic.")
+	}
+
+	_, err = ds.ExportCollectionManifest("missing.manifest.com")
+	if err != ErrIPNSNotFound {
+		t.Errorf("Exporting a missing collection should return ErrIPNSNotFound. Actual %v", err)
+	}
+}
+
+func TestImportCollectionManifest(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	// QmImport1 already exists with another tag
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmImport1", Name: "Local Name", Tags: []Tag{{"import", "local"}}})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+
+	m := CollectionManifest{
+		Version:     ManifestVersion,
+		IPNSAddress: "import.com",
+		Name:        "Import",
+		Folders: []FolderManifest{
+			{Path: "a/b", Items: []string{"QmImport2"}},
+			{Path: "a"},
+			{Path: "c", Links: []string{"a"}},
+			{Path: "", Items: []string{"QmImport1"}},
+		},
+		Items: []ItemManifest{
+			{CID: "QmImport1", Name: "Remote Name", Tags: []string{"import:remote"}},
+			{CID: "QmImport2", Name: "Import 2", Size: 7},
+		},
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("Unable to encode manifest. Error: %s", err)
+	}
+
+	err = ds.ImportCollectionManifest(data)
+	if err != nil {
+		t.Fatalf("Unable to import manifest. Error: %s", err)
+	}
+
+	item, err := ds.ReadItem("QmImport1")
+	if err != nil {
+		t.Errorf("Unable to read item. Error: %s", err)
+	}
+	if item.Name != "Local Name" || len(item.Tags) != 2 {
+		t.Errorf("Existing item should keep its name and merge tags. Actual %+v", item)
+	}
+
+	items, err := ds.ReadFolderItems(&Folder{IPNSAddress: "import.com", Path: "a/b"})
+	if err != nil {
+		t.Errorf("Unable to read folder items. Error: %s", err)
+	}
+	if len(items) != 1 || items[0] != "QmImport2" {
+		t.Errorf("Items of a/b = %v; want [QmImport2]", items)
+	}
+
+	children, err := ds.ReadFolderChildren(&Folder{IPNSAddress: "import.com", Path: "a"})
+	if err != nil {
+		t.Errorf("Unable to read folder children. Error: %s", err)
+	}
+	if len(children) != 2 {
+		t.Errorf("Children of a = %v; want [a/b c]", children)
+	}
+
+	// Exporting the imported collection round trips
+	exported, err := ds.ExportCollectionManifest("import.com")
+	if err != nil {
+		t.Fatalf("Unable to export manifest. Error: %s", err)
+	}
+	var m2 CollectionManifest
+	err = json.Unmarshal(exported, &m2)
+	if err != nil {
+		t.Fatalf("Unable to decode manifest. Error: %s", err)
+	}
+	if len(m2.Folders) != 4 || len(m2.Folders[0].Items) != 1 || m2.Folders[0].Items[0] != "QmImport1" {
+		t.Errorf("Wrong exported folders: %+v", m2.Folders)
+	}
+
+	err = ds.ImportCollectionManifest([]byte(`{"version": 99, "ipns": "import.com"}`))
+	if err != ErrUnsupportedManifestVersion {
+		t.Errorf("Importing an unknown version should return ErrUnsupportedManifestVersion. Actual %v", err)
+	}
+
+	err = ds.ImportCollectionManifest([]byte(`{"version": 1, "ipns": "import.com", "items": [{"cid": "QmImport3"}]}`))
+	if err != ErrInvalidManifest {
+		t.Errorf("Importing an item without a name should return ErrInvalidManifest. Actual %v", err)
+	}
+}
+
+func TestImportDraftCollectionManifest(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "draftmanifest.com"
+	err = ds.CreateEmptyCollection(ipns)
+	if err != nil {
+		t.Errorf("Unable to create empty Collection. Error: %s", err)
+	}
+	data, err := ds.ExportCollectionManifest(ipns)
+	if err != nil {
+		t.Fatalf("Unable to export manifest. Error: %s", err)
+	}
+	err = ds.DelCollection(ipns)
+	if err != nil {
+		t.Errorf("Unable to delete Collection. Error: %s", err)
+	}
+
+	err = ds.ImportCollectionManifest(data)
+	if err != nil {
+		t.Fatalf("Unable to import manifest of a draft collection. Error: %s", err)
+	}
+	c, err := ds.ReadCollection(ipns)
+	if err != nil {
+		t.Errorf("Unable to read Collection. Error: %s", err)
+	} else if c.Name != "" {
+		t.Errorf("Draft collection name = %q; want empty", c.Name)
+	}
+}
+
+func TestExportFolderManifest(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "foldermanifest.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Folder Manifest"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	err = ds.CreateFolderAll(ipns, "a/b")
+	if err != nil {
+		t.Errorf("Unable to create folders. Error: %s", err)
+	}
+	err = ds.CreateFolderAll(ipns, "ab")
+	if err != nil {
+		t.Errorf("Unable to create folders. Error: %s", err)
+	}
+
+	items := []*Item{
+		{CID: "QmFolderManifest1", Name: "Folder Manifest 1", Tags: []Tag{{"foldermanifest"}}},
+		{CID: "QmFolderManifest2", Name: "Folder Manifest 2"},
+	}
+	for _, item := range items {
+		err = ds.CreateOrUpdateItem(item)
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+		err = ds.AddItemToCollection(item.CID, ipns)
+		if err != nil {
+			t.Errorf("Unable to add item to collection. Error: %s", err)
+		}
+	}
+	err = ds.AddItemToFolder("QmFolderManifest1", &Folder{IPNSAddress: ipns, Path: "a/b"})
+	if err != nil {
+		t.Errorf("Unable to add item to folder. Error: %s", err)
+	}
+	err = ds.AddItemToFolder("QmFolderManifest2", &Folder{IPNSAddress: ipns, Path: "ab"})
+	if err != nil {
+		t.Errorf("Unable to add item to folder. Error: %s", err)
+	}
+
+	data, err := ds.ExportFolderManifest(&Folder{IPNSAddress: ipns, Path: "a"})
+	if err != nil {
+		t.Fatalf("Unable to export manifest. Error: %s", err)
+	}
+	var m CollectionManifest
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		t.Fatalf("Unable to decode manifest. Error: %s", err)
+	}
+
+	if m.Root != "a" || len(m.Folders) != 2 || m.Folders[0].Path != "" || m.Folders[1].Path != "b" {
+		t.Errorf("Wrong folder manifest: %+v", m)
+	}
+	if len(m.Folders) == 2 && (len(m.Folders[1].Items) != 1 || m.Folders[1].Items[0] != "QmFolderManifest1") {
+		t.Errorf("Items of b = %v; want [QmFolderManifest1]", m.Folders[1].Items)
+	}
+	if len(m.Items) != 1 || m.Items[0].CID != "QmFolderManifest1" || len(m.Items[0].Tags) != 1 {
+		t.Errorf("Wrong manifest items: %+v", m.Items)
+	}
+
+	_, err = ds.ExportFolderManifest(&Folder{IPNSAddress: ipns, Path: "missing"})
+	if err != ErrFolderNotExists {
+		t.Errorf("Exporting a missing folder should return ErrFolderNotExists. Actual %v", err)
+	}
+
+	err = ds.ImportCollectionManifest(data)
+	if err != ErrInvalidManifest {
+		t.Errorf("Importing a folder manifest should return ErrInvalidManifest. Actual %v", err)
+	}
+}
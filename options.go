@@ -0,0 +1,82 @@
+package resource
+
+import "github.com/dgraph-io/badger"
+
+// Options are params for creating a Datastore.
+type Options struct {
+	// CacheSize is the max number of collections and items cached in memory by ReadCollection and ReadItem.
+	// 0 disables the cache.
+	CacheSize int
+
+	// NormalizeTags makes tags case-insensitive and whitespace-insensitive by storing and looking up Tag.Normalized.
+	NormalizeTags bool
+
+	// NativeDropPrefix makes DelCollection drop the keys of the collection's folders by Badger's DropPrefix after
+	// deleting the collection, instead of deleting them one by one in the same transaction.
+	// It's much faster for large collections and not limited by the transaction size, but it blocks all writes while
+	// it runs, and if it fails the collection is already gone while some of its keys are left behind.
+	NativeDropPrefix bool
+
+	// SyncWrites makes Badger sync every write to disk before it returns. Disabling it makes writes much faster, but
+	// the last writes may be lost if the process or the machine crashes.
+	SyncWrites bool
+
+	// Logger receives the logs of Badger. The default logger of Badger writes to stderr. nil discards the logs.
+	Logger badger.Logger
+
+	// Namespace prefixes every key of the Datastore, so Datastores with different namespaces can share one Badger DB
+	// without colliding. Use NewDatastoreWithDB to open them on the same Badger DB at the same time.
+	// The default "" uses no prefix. Backup, Restore and Clone still work on the whole Badger DB.
+	Namespace string
+}
+
+// DefaultOptions returns the default Options for creating a Datastore.
+func DefaultOptions() Options {
+	return Options{
+		CacheSize:     1024,
+		NormalizeTags: true,
+		SyncWrites:    true,
+		Logger:        badger.DefaultOptions("").Logger,
+	}
+}
+
+// WithCacheSize returns a new Options value with CacheSize set to the given value.
+func (o Options) WithCacheSize(val int) Options {
+	o.CacheSize = val
+	return o
+}
+
+// WithNormalizeTags returns a new Options value with NormalizeTags set to the given value.
+func (o Options) WithNormalizeTags(val bool) Options {
+	o.NormalizeTags = val
+	return o
+}
+
+// WithNativeDropPrefix returns a new Options value with NativeDropPrefix set to the given value.
+func (o Options) WithNativeDropPrefix(val bool) Options {
+	o.NativeDropPrefix = val
+	return o
+}
+
+// WithSyncWrites returns a new Options value with SyncWrites set to the given value.
+func (o Options) WithSyncWrites(val bool) Options {
+	o.SyncWrites = val
+	return o
+}
+
+// WithLogger returns a new Options value with Logger set to the given value.
+func (o Options) WithLogger(val badger.Logger) Options {
+	o.Logger = val
+	return o
+}
+
+// WithSilentLogging returns a new Options value which discards the logs of Badger.
+func (o Options) WithSilentLogging() Options {
+	return o.WithLogger(nil)
+}
+
+// WithNamespace returns a new Options value with Namespace set to the given value.
+func (o Options) WithNamespace(val string) Options {
+	o.Namespace = val
+	return o
+}
@@ -0,0 +1,683 @@
+package resource
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/badger"
+)
+
+// ItemQuery is a query of items by tags.
+// Items must have all tags of AllOf, at least one tag of AnyOf and none of the tags of NoneOf.
+// Empty groups are ignored. If IPNS is not empty, only items in that collection are returned.
+type ItemQuery struct {
+	AllOf  []Tag
+	AnyOf  []Tag
+	NoneOf []Tag
+	IPNS   string
+}
+
+// QueryItems returns CIDs of items matching the query, sorted by CID.
+// An empty query returns all items in scope. ErrInvalidTag is returned if any tag of the query is empty.
+func (d *Datastore) QueryItems(q ItemQuery) ([]string, error) {
+	for _, tags := range [][]Tag{q.AllOf, q.AnyOf, q.NoneOf} {
+		for _, t := range tags {
+			if t.IsEmpty() {
+				return nil, ErrInvalidTag
+			}
+		}
+	}
+
+	var cids []string
+	err := d.view(func(txn *badger.Txn) error {
+		// nil means no restriction yet
+		var result map[string]bool
+
+		for _, t := range q.AllOf {
+			result = intersectCIDs(result, d.readTagItemsInTxn(txn, t))
+		}
+
+		if len(q.AnyOf) > 0 {
+			anyOf := make(map[string]bool)
+			for _, t := range q.AnyOf {
+				for cid := range d.readTagItemsInTxn(txn, t) {
+					anyOf[cid] = true
+				}
+			}
+			result = intersectCIDs(result, anyOf)
+		}
+
+		var scope dbKey
+		if q.IPNS != "" {
+			err := d.checkIPNSInTxn(txn, q.IPNS)
+			if err != nil {
+				return err
+			}
+			// collection_item::[ipns]::[cid]
+			scope = dbKey{"collection_item", q.IPNS, ""}
+		} else {
+			// items::[cid]
+			scope = dbKey{"items", ""}
+		}
+		inScope := make(map[string]bool)
+		for _, k := range d.readKeysInTxn(txn, scope) {
+			inScope[k[len(k)-1]] = true
+		}
+		result = intersectCIDs(result, inScope)
+
+		for _, t := range q.NoneOf {
+			for cid := range d.readTagItemsInTxn(txn, t) {
+				delete(result, cid)
+			}
+		}
+
+		for cid := range result {
+			cids = append(cids, cid)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(cids)
+	return cids, nil
+}
+
+// FilterFolderItems returns CIDs of items in a folder or its sub folders which have all the tags, sorted by CID.
+// Linked children are included. Empty tags returns all items in the folder tree.
+func (d *Datastore) FilterFolderItems(folder *Folder, tags []Tag) ([]string, error) {
+	folder, err := normalizeFolder(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range tags {
+		if t.IsEmpty() {
+			return nil, ErrInvalidTag
+		}
+	}
+
+	cids := []string{}
+	err = d.view(func(txn *badger.Txn) error {
+		exists, err := d.isFolderPathExistsInTxn(txn, folder.IPNSAddress, folder.Path)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrFolderNotExists
+		}
+
+		result, err := d.readFolderTreeItemsInTxn(txn, folder.IPNSAddress, folder.Path, make(map[string]bool))
+		if err != nil {
+			return err
+		}
+		for _, t := range tags {
+			result = intersectCIDs(result, d.readTagItemsInTxn(txn, t))
+		}
+
+		for cid := range result {
+			cids = append(cids, cid)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(cids)
+	return cids, nil
+}
+
+// readFolderTreeItemsInTxn returns the set of CIDs of items in a folder and its children, including linked ones.
+// visited are paths of folders already read, so cycles are read once.
+func (d *Datastore) readFolderTreeItemsInTxn(txn *badger.Txn, ipns, path string, visited map[string]bool) (map[string]bool, error) {
+	cids := make(map[string]bool)
+	visited[path] = true
+
+	// folder_item::[ipns]::[folderPath]::[cid]
+	for _, k := range d.readKeysInTxn(txn, dbKey{"folder_item", ipns, path, ""}) {
+		cids[k[3]] = true
+	}
+
+	children, err := d.readPathListInTxn(txn, dbKey{"folder", ipns, path, "children"})
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range children {
+		if visited[c] {
+			continue
+		}
+		childCIDs, err := d.readFolderTreeItemsInTxn(txn, ipns, c, visited)
+		if err != nil {
+			return nil, err
+		}
+		for cid := range childCIDs {
+			cids[cid] = true
+		}
+	}
+
+	return cids, nil
+}
+
+// SearchItemsInCollection returns items in a collection whose names contain query, case-insensitively.
+func (d *Datastore) SearchItemsInCollection(ipns, query string) ([]*Item, error) {
+	cids, err := d.ReadCollectionItems(ipns)
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	items := []*Item{}
+	err = d.view(func(txn *badger.Txn) error {
+		for _, cid := range cids {
+			item, err := d.readItemInTxn(txn, cid)
+			if err != nil {
+				return err
+			}
+			if strings.Contains(strings.ToLower(item.Name), query) {
+				items = append(items, item)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// GetTagItems returns CIDs of items having the tag, sorted by CID.
+func (d *Datastore) GetTagItems(t Tag) ([]string, error) {
+	if t.IsEmpty() {
+		return nil, ErrInvalidTag
+	}
+
+	cids := []string{}
+	err := d.view(func(txn *badger.Txn) error {
+		for cid := range d.readTagItemsInTxn(txn, t) {
+			cids = append(cids, cid)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(cids)
+	return cids, nil
+}
+
+// ReadItemsByTag returns items having the tag in one transaction, sorted by CID.
+func (d *Datastore) ReadItemsByTag(t Tag) ([]*Item, error) {
+	if t.IsEmpty() {
+		return nil, ErrInvalidTag
+	}
+
+	items := []*Item{}
+	err := d.view(func(txn *badger.Txn) error {
+		var cids []string
+		for cid := range d.readTagItemsInTxn(txn, t) {
+			cids = append(cids, cid)
+		}
+		sort.Strings(cids)
+
+		for _, cid := range cids {
+			item, err := d.readItemInTxn(txn, cid)
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// ReadItemsByType returns CIDs of items with the content type, sorted by CID.
+func (d *Datastore) ReadItemsByType(ct string) ([]string, error) {
+	if ct == "" {
+		panic("Invalid parameters.")
+	}
+
+	cids := []string{}
+	err := d.view(func(txn *badger.Txn) error {
+		// type_item::[contentType]::[cid]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"type_item", ct, ""}) {
+			cids = append(cids, k[2])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cids, nil
+}
+
+// GetItemsByTagPrefix returns CIDs of items having the tag or any tag under it, sorted by CID.
+// Prefix matches whole segments, so "movie" matches "movie:drama" but not "movies".
+func (d *Datastore) GetItemsByTagPrefix(prefix Tag) ([]string, error) {
+	if prefix.IsEmpty() {
+		return nil, ErrInvalidTag
+	}
+
+	cids := []string{}
+	err := d.view(func(txn *badger.Txn) error {
+		prefix := d.normalizeTag(prefix)
+		found := make(map[string]bool)
+		// tags::[tagStr]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"tags", prefix.String()}) {
+			t := NewTagFromStr(k[1])
+			if !t.HasPrefix(prefix) {
+				continue
+			}
+			for cid := range d.readTagItemsInTxn(txn, t) {
+				found[cid] = true
+			}
+		}
+
+		for cid := range found {
+			cids = append(cids, cid)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(cids)
+	return cids, nil
+}
+
+// FacetCounts returns the immediate child tags of prefix with their item counts, for faceted search.
+// Counts of deeper tags are added to the child they are under, so "movie" gives "movie:drama" the counts of
+// "movie:drama" and "movie:drama:korean". Facets are ordered by count and then by tag string.
+func (d *Datastore) FacetCounts(prefix Tag) ([]TagCount, error) {
+	if prefix.IsEmpty() {
+		return nil, ErrInvalidTag
+	}
+	prefix = d.normalizeTag(prefix)
+
+	counts := make(map[string]uint)
+	err := d.view(func(txn *badger.Txn) error {
+		// tags::[tagStr]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"tags", prefix.String()}) {
+			t := NewTagFromStr(k[1])
+			if !t.HasPrefix(prefix) || len(t) <= len(prefix) {
+				continue
+			}
+
+			c, err := d.readTagItemCountInTxn(txn, t)
+			if err != nil {
+				return err
+			}
+			counts[Tag(t[:len(prefix)+1]).String()] += c
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	facets := []TagCount{}
+	for tagStr, c := range counts {
+		facets = append(facets, TagCount{Tag: NewTagFromStr(tagStr), Count: c})
+	}
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Tag.String() < facets[j].Tag.String()
+	})
+	return facets, nil
+}
+
+// ReadTagTree returns all tags as a hierarchy built by splitting them into segments. The returned root node has an
+// empty Segment and children are sorted by segment.
+func (d *Datastore) ReadTagTree() (*TagNode, error) {
+	root := &TagNode{}
+	err := d.view(func(txn *badger.Txn) error {
+		nodes := make(map[string]*TagNode)
+		// tags::[tagStr]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"tags", ""}) {
+			t := NewTagFromStr(k[1])
+
+			n := root
+			for i, seg := range t {
+				path := Tag(t[:i+1]).String()
+				child, ok := nodes[path]
+				if !ok {
+					child = &TagNode{Segment: seg}
+					nodes[path] = child
+					n.Children = append(n.Children, child)
+				}
+				n = child
+			}
+
+			c, err := d.readTagItemCountInTxn(txn, t)
+			if err != nil {
+				return err
+			}
+			n.Count = c
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortTagNodes(root)
+	return root, nil
+}
+
+func sortTagNodes(n *TagNode) {
+	sort.Slice(n.Children, func(i, j int) bool { return n.Children[i].Segment < n.Children[j].Segment })
+	for _, c := range n.Children {
+		sortTagNodes(c)
+	}
+}
+
+// CollectionsWithTag returns IPNS addresses of collections having any item with the tag, sorted.
+func (d *Datastore) CollectionsWithTag(t Tag) ([]string, error) {
+	if t.IsEmpty() {
+		return nil, ErrInvalidTag
+	}
+
+	addrs := []string{}
+	err := d.view(func(txn *badger.Txn) error {
+		found := make(map[string]bool)
+		for cid := range d.readTagItemsInTxn(txn, t) {
+			// item_collection::[cid]::[ipns]
+			for _, k := range d.readKeysInTxn(txn, dbKey{"item_collection", cid, ""}) {
+				found[k[2]] = true
+			}
+		}
+
+		for ipns := range found {
+			addrs = append(addrs, ipns)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(addrs)
+	return addrs, nil
+}
+
+// SuggestTags returns the tags most often found on items having tag t, with the number of such items.
+// At most limit tags are returned, ordered by count and then by tag string. t itself is not included.
+func (d *Datastore) SuggestTags(t Tag, limit int) ([]TagCount, error) {
+	if t.IsEmpty() {
+		return nil, ErrInvalidTag
+	}
+	if limit <= 0 {
+		panic("Invalid parameters.")
+	}
+	t = d.normalizeTag(t)
+
+	counts := make(map[string]uint)
+	err := d.view(func(txn *badger.Txn) error {
+		for cid := range d.readTagItemsInTxn(txn, t) {
+			// item_tag::[cid]::[tagStr]
+			for _, k := range d.readKeysInTxn(txn, dbKey{"item_tag", cid, ""}) {
+				if k[2] != t.String() {
+					counts[k[2]]++
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := []TagCount{}
+	for tagStr, c := range counts {
+		suggestions = append(suggestions, TagCount{Tag: NewTagFromStr(tagStr), Count: c})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Count != suggestions[j].Count {
+			return suggestions[i].Count > suggestions[j].Count
+		}
+		return suggestions[i].Tag.String() < suggestions[j].Tag.String()
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
+
+// AutocompleteTags searches tags with prefix like SearchTags and returns them with their item counts.
+// At most limit tags are returned, ordered by count and then by tag string.
+func (d *Datastore) AutocompleteTags(prefix string, limit int) ([]TagCount, error) {
+	if prefix == "" || limit <= 0 {
+		panic("Invalid parameters.")
+	}
+	prefix = d.normalizeTag(NewTagFromStr(prefix)).String()
+
+	tags := []TagCount{}
+	err := d.view(func(txn *badger.Txn) error {
+		// tags::[tagStr]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"tags", prefix}) {
+			t := NewTagFromStr(k[1])
+			c, err := d.readTagItemCountInTxn(txn, t)
+			if err != nil {
+				return err
+			}
+			tags = append(tags, TagCount{Tag: t, Count: c})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag.String() < tags[j].Tag.String()
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return tags, nil
+}
+
+// ListCollectionTags returns tags used by items in a collection, sorted by tag string.
+// Counts are the numbers of items in the collection having the tag.
+func (d *Datastore) ListCollectionTags(ipns string) ([]TagCount, error) {
+	counts := make(map[string]uint)
+	err := d.view(func(txn *badger.Txn) error {
+		err := d.checkIPNSInTxn(txn, ipns)
+		if err != nil {
+			return err
+		}
+
+		// collection_item::[ipns]::[cid]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"collection_item", ipns, ""}) {
+			// item_tag::[cid]::[tagStr]
+			for _, tk := range d.readKeysInTxn(txn, dbKey{"item_tag", k[2], ""}) {
+				counts[tk[2]]++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []TagCount{}
+	for tagStr, c := range counts {
+		tags = append(tags, TagCount{Tag: NewTagFromStr(tagStr), Count: c})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Tag.String() < tags[j].Tag.String() })
+	return tags, nil
+}
+
+// UntaggedItems returns CIDs of items without any tag, sorted by CID.
+// If ipns is not empty, only items in that collection are returned.
+func (d *Datastore) UntaggedItems(ipns string) ([]string, error) {
+	cids := []string{}
+	err := d.view(func(txn *badger.Txn) error {
+		var scope dbKey
+		if ipns != "" {
+			err := d.checkIPNSInTxn(txn, ipns)
+			if err != nil {
+				return err
+			}
+			// collection_item::[ipns]::[cid]
+			scope = dbKey{"collection_item", ipns, ""}
+		} else {
+			// items::[cid]
+			scope = dbKey{"items", ""}
+		}
+
+		for _, k := range d.readKeysInTxn(txn, scope) {
+			cid := k[len(k)-1]
+			// item_tag::[cid]::[tagStr]
+			if !d.hasPrefixInTxn(txn, dbKey{"item_tag", cid, ""}) {
+				cids = append(cids, cid)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cids, nil
+}
+
+// RootOnlyItems returns CIDs of items in a collection which are in the root folder and no other folder, sorted by CID.
+func (d *Datastore) RootOnlyItems(ipns string) ([]string, error) {
+	cids := []string{}
+	err := d.view(func(txn *badger.Txn) error {
+		err := d.checkIPNSInTxn(txn, ipns)
+		if err != nil {
+			return err
+		}
+
+		// collection_item::[ipns]::[cid]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"collection_item", ipns, ""}) {
+			cid := k[2]
+			// item_folder::[cid]::[ipns]::[folderPath]
+			fks := d.readKeysInTxn(txn, dbKey{"item_folder", cid, ipns, ""})
+			if len(fks) == 1 && fks[0][3] == "" {
+				cids = append(cids, cid)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cids, nil
+}
+
+// CollectionOrphanItems returns CIDs of items in a collection which are in no folder of the collection, sorted by CID.
+func (d *Datastore) CollectionOrphanItems(ipns string) ([]string, error) {
+	cids := []string{}
+	err := d.view(func(txn *badger.Txn) error {
+		err := d.checkIPNSInTxn(txn, ipns)
+		if err != nil {
+			return err
+		}
+
+		// collection_item::[ipns]::[cid]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"collection_item", ipns, ""}) {
+			cid := k[2]
+			// item_folder::[cid]::[ipns]::[folderPath]
+			if !d.hasPrefixInTxn(txn, dbKey{"item_folder", cid, ipns, ""}) {
+				cids = append(cids, cid)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cids, nil
+}
+
+// FindDuplicateNames returns names shared by more than one item in a collection, mapped to the CIDs of those items.
+func (d *Datastore) FindDuplicateNames(ipns string) (map[string][]string, error) {
+	byName := make(map[string][]string)
+	err := d.view(func(txn *badger.Txn) error {
+		err := d.checkIPNSInTxn(txn, ipns)
+		if err != nil {
+			return err
+		}
+
+		// collection_item::[ipns]::[cid]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"collection_item", ipns, ""}) {
+			item, err := d.readItemInTxn(txn, k[2])
+			if err != nil {
+				return err
+			}
+			byName[item.Name] = append(byName[item.Name], item.CID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for name, cids := range byName {
+		if len(cids) < 2 {
+			delete(byName, name)
+		}
+	}
+	return byName, nil
+}
+
+// hasPrefixInTxn checks if any key has the prefix.
+func (d *Datastore) hasPrefixInTxn(txn *badger.Txn, prefix dbKey) bool {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	it.Seek(d.key(prefix))
+	return it.ValidForPrefix(d.key(prefix))
+}
+
+// readTagItemsInTxn returns the set of CIDs of items having the tag.
+func (d *Datastore) readTagItemsInTxn(txn *badger.Txn, t Tag) map[string]bool {
+	if t.IsEmpty() {
+		panic("Invalid tag.")
+	}
+	t = d.normalizeTag(t)
+
+	cids := make(map[string]bool)
+	// tag_item::[tagStr]::[cid]
+	for _, k := range d.readKeysInTxn(txn, dbKey{"tag_item", t.String(), ""}) {
+		cids[k[2]] = true
+	}
+	return cids
+}
+
+// intersectCIDs returns the intersection of two CID sets. A nil set a means no restriction.
+func intersectCIDs(a, b map[string]bool) map[string]bool {
+	if a == nil {
+		return b
+	}
+
+	result := make(map[string]bool)
+	for cid := range a {
+		if b[cid] {
+			result[cid] = true
+		}
+	}
+	return result
+}
@@ -0,0 +1,871 @@
+package resource
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/thoas/go-funk"
+)
+
+func TestQueryItems(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "query.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Query Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+
+	movie := Tag{"query", "movie"}
+	drama := Tag{"query", "drama"}
+	comedy := Tag{"query", "comedy"}
+	items := []*Item{
+		{CID: "QmQueryItem1", Name: "Query Item1", Tags: []Tag{movie, drama}},
+		{CID: "QmQueryItem2", Name: "Query Item2", Tags: []Tag{movie, comedy}},
+		{CID: "QmQueryItem3", Name: "Query Item3", Tags: []Tag{movie, drama, comedy}},
+		{CID: "QmQueryItem4", Name: "Query Item4", Tags: []Tag{drama}},
+	}
+	for _, item := range items {
+		err = ds.CreateOrUpdateItem(item)
+		if err != nil {
+			t.Errorf("Unable to create Item. Error: %s", err)
+		}
+	}
+	for _, item := range items[:3] {
+		err = ds.AddItemToCollection(item.CID, ipns)
+		if err != nil {
+			t.Errorf("Unable to add Item to Collection. Error: %s", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		q    ItemQuery
+		want []string
+	}{
+		{"AllOf", ItemQuery{AllOf: []Tag{movie, drama}}, []string{"QmQueryItem1", "QmQueryItem3"}},
+		{"AnyOf", ItemQuery{AnyOf: []Tag{comedy, drama}}, []string{"QmQueryItem1", "QmQueryItem2", "QmQueryItem3", "QmQueryItem4"}},
+		{"NoneOf", ItemQuery{AllOf: []Tag{movie}, NoneOf: []Tag{comedy}}, []string{"QmQueryItem1"}},
+		{"Scoped", ItemQuery{AnyOf: []Tag{drama}, IPNS: ipns}, []string{"QmQueryItem1", "QmQueryItem3"}},
+		{"EmptyScoped", ItemQuery{IPNS: ipns}, []string{"QmQueryItem1", "QmQueryItem2", "QmQueryItem3"}},
+	}
+	for _, tt := range tests {
+		cids, err := ds.QueryItems(tt.q)
+		if err != nil {
+			t.Errorf("%s: Unable to query items. Error: %s", tt.name, err)
+		}
+		if !reflect.DeepEqual(cids, tt.want) {
+			t.Errorf("%s: QueryItems = %v; want %v", tt.name, cids, tt.want)
+		}
+	}
+
+	_, err = ds.QueryItems(ItemQuery{IPNS: "notexists.com"})
+	if err != ErrIPNSNotFound {
+		t.Errorf("Query of a missing collection should fail with ErrIPNSNotFound. Actual %v", err)
+	}
+
+	for _, q := range []ItemQuery{{AllOf: []Tag{movie, {}}}, {AnyOf: []Tag{{}}}, {NoneOf: []Tag{{}}}} {
+		_, err = ds.QueryItems(q)
+		if err != ErrInvalidTag {
+			t.Errorf("Query with an empty tag should fail with ErrInvalidTag. Actual %v", err)
+		}
+	}
+}
+
+func TestSearchItemsInCollection(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "searchitems.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Search Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+
+	items := []*Item{
+		{CID: "QmSearchItem1", Name: "The Godfather"},
+		{CID: "QmSearchItem2", Name: "Godzilla"},
+		{CID: "QmSearchItem3", Name: "Casablanca"},
+	}
+	for _, item := range items {
+		err = ds.CreateOrUpdateItem(item)
+		if err != nil {
+			t.Errorf("Unable to create Item. Error: %s", err)
+		}
+		err = ds.AddItemToCollection(item.CID, ipns)
+		if err != nil {
+			t.Errorf("Unable to add Item to Collection. Error: %s", err)
+		}
+	}
+
+	found, err := ds.SearchItemsInCollection(ipns, "GOD")
+	if err != nil {
+		t.Errorf("Unable to search items. Error: %s", err)
+	}
+	if len(found) != 2 {
+		t.Errorf("Expect 2 result. Actual %d", len(found))
+	}
+
+	found, err = ds.SearchItemsInCollection(ipns, "nothing")
+	if err != nil {
+		t.Errorf("Unable to search items. Error: %s", err)
+	}
+	if found == nil || len(found) != 0 {
+		t.Errorf("Expect an empty result. Actual %v", found)
+	}
+
+	_, err = ds.SearchItemsInCollection("notexists.com", "god")
+	if err != ErrIPNSNotFound {
+		t.Errorf("Search in a missing collection should fail with ErrIPNSNotFound. Actual %v", err)
+	}
+}
+
+func TestCollectionItemsPrefixIPNS(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	// Items of searchprefix.com.evil must not show up in searchprefix.com
+	for _, ipns := range []string{"searchprefix.com", "searchprefix.com.evil"} {
+		err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: ipns})
+		if err != nil {
+			t.Errorf("Unable to create Collection. Error: %s", err)
+		}
+	}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmSearchPrefix1", Name: "Prefix Item"})
+	if err != nil {
+		t.Errorf("Unable to create Item. Error: %s", err)
+	}
+	err = ds.AddItemToCollection("QmSearchPrefix1", "searchprefix.com.evil")
+	if err != nil {
+		t.Errorf("Unable to add Item to Collection. Error: %s", err)
+	}
+
+	cids, err := ds.ReadCollectionItems("searchprefix.com")
+	if err != nil {
+		t.Errorf("Unable to read collection items. Error: %s", err)
+	}
+	if len(cids) != 0 {
+		t.Errorf("Expect an empty result. Actual %v", cids)
+	}
+
+	found, err := ds.SearchItemsInCollection("searchprefix.com", "prefix")
+	if err != nil {
+		t.Errorf("Unable to search items. Error: %s", err)
+	}
+	if len(found) != 0 {
+		t.Errorf("Expect an empty result. Actual %v", found)
+	}
+
+	empty, err := ds.IsCollectionEmpty("searchprefix.com")
+	if err != nil {
+		t.Errorf("Unable to check if Collection is empty. Error: %s", err)
+	}
+	if !empty {
+		t.Error("Collection is empty but false returns.")
+	}
+}
+
+func TestGetTagItems(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	tag := Tag{"gettagitems", "tag"}
+	for _, cid := range []string{"QmGetTagItems2", "QmGetTagItems1"} {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: "GetTagItems Item", Tags: []Tag{tag}})
+		if err != nil {
+			t.Errorf("Unable to create Item. Error: %s", err)
+		}
+	}
+
+	cids, err := ds.GetTagItems(tag)
+	if err != nil {
+		t.Errorf("Unable to get tag items. Error: %s", err)
+	}
+	want := []string{"QmGetTagItems1", "QmGetTagItems2"}
+	if !reflect.DeepEqual(cids, want) {
+		t.Errorf("GetTagItems = %v; want %v", cids, want)
+	}
+
+	cids, err = ds.GetTagItems(Tag{"gettagitems", "unused"})
+	if err != nil {
+		t.Errorf("Unable to get tag items. Error: %s", err)
+	}
+	if cids == nil || len(cids) != 0 {
+		t.Errorf("Expect an empty result. Actual %v", cids)
+	}
+
+	_, err = ds.GetTagItems(Tag{})
+	if err != ErrInvalidTag {
+		t.Errorf("Empty tag should fail with ErrInvalidTag. Actual %v", err)
+	}
+}
+
+func TestReadItemsByTag(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	items := []*Item{
+		{CID: "QmByTag2", Name: "By Tag 2", Tags: []Tag{{"bytag"}}},
+		{CID: "QmByTag1", Name: "By Tag 1", Tags: []Tag{{"bytag"}, {"bytag", "other"}}},
+		{CID: "QmByTag3", Name: "By Tag 3", Tags: []Tag{{"bytag", "other"}}},
+	}
+	for _, item := range items {
+		err = ds.CreateOrUpdateItem(item)
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+	}
+
+	res, err := ds.ReadItemsByTag(Tag{"bytag"})
+	if err != nil {
+		t.Errorf("Unable to read items by tag. Error: %s", err)
+	}
+	if len(res) != 2 || res[0].CID != "QmByTag1" || res[1].CID != "QmByTag2" {
+		t.Errorf("ReadItemsByTag = %v; want [QmByTag1 QmByTag2]", res)
+	}
+	if len(res) == 2 && (res[0].Name != "By Tag 1" || len(res[0].Tags) != 2) {
+		t.Errorf("Wrong item: %+v", res[0])
+	}
+
+	res, err = ds.ReadItemsByTag(Tag{"bytag", "unknown"})
+	if err != nil {
+		t.Errorf("Unable to read items by tag. Error: %s", err)
+	}
+	if res == nil || len(res) != 0 {
+		t.Errorf("ReadItemsByTag of an unused tag = %v; want empty slice", res)
+	}
+}
+
+func TestReadItemsByType(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	items := []*Item{
+		{CID: "QmByType2", Name: "By Type 2", ContentType: "bytype/image"},
+		{CID: "QmByType1", Name: "By Type 1", ContentType: "bytype/image"},
+		{CID: "QmByType3", Name: "By Type 3"},
+	}
+	for _, item := range items {
+		err = ds.CreateOrUpdateItem(item)
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+	}
+
+	item, err := ds.ReadItem("QmByType1")
+	if err != nil {
+		t.Errorf("Unable to read item. Error: %s", err)
+	}
+	if item.ContentType != "bytype/image" {
+		t.Errorf("ContentType = %s; want bytype/image", item.ContentType)
+	}
+
+	// Change types
+	err = ds.SetItemContentType("QmByType2", "bytype/video")
+	if err != nil {
+		t.Errorf("Unable to set content type. Error: %s", err)
+	}
+	err = ds.SetItemContentType("QmByType3", "bytype/image")
+	if err != nil {
+		t.Errorf("Unable to set content type. Error: %s", err)
+	}
+	err = ds.SetItemContentType("QmByType404", "bytype/image")
+	if err != ErrCIDNotFound {
+		t.Errorf("Setting content type of a missing item should return ErrCIDNotFound. Actual %v", err)
+	}
+
+	cids, err := ds.ReadItemsByType("bytype/image")
+	if err != nil {
+		t.Errorf("Unable to read items by type. Error: %s", err)
+	}
+	if !reflect.DeepEqual(cids, []string{"QmByType1", "QmByType3"}) {
+		t.Errorf("ReadItemsByType = %v; want [QmByType1 QmByType3]", cids)
+	}
+
+	// Deleting an item removes it from the index
+	err = ds.DelItem("QmByType1")
+	if err != nil {
+		t.Errorf("Unable to delete item. Error: %s", err)
+	}
+	cids, err = ds.ReadItemsByType("bytype/image")
+	if err != nil {
+		t.Errorf("Unable to read items by type. Error: %s", err)
+	}
+	if !reflect.DeepEqual(cids, []string{"QmByType3"}) {
+		t.Errorf("ReadItemsByType = %v; want [QmByType3]", cids)
+	}
+}
+
+func TestGetItemsByTagPrefix(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	items := []*Item{
+		{CID: "QmTagPrefix1", Name: "TagPrefix Item1", Tags: []Tag{{"tagprefix", "genres", "drama"}}},
+		{CID: "QmTagPrefix2", Name: "TagPrefix Item2", Tags: []Tag{{"tagprefix", "year", "1999"}, {"tagprefix", "genres", "drama"}}},
+		{CID: "QmTagPrefix3", Name: "TagPrefix Item3", Tags: []Tag{{"tagprefixes", "other"}}},
+		{CID: "QmTagPrefix4", Name: "TagPrefix Item4", Tags: []Tag{{"tagprefix"}}},
+	}
+	for _, item := range items {
+		err = ds.CreateOrUpdateItem(item)
+		if err != nil {
+			t.Errorf("Unable to create Item. Error: %s", err)
+		}
+	}
+
+	cids, err := ds.GetItemsByTagPrefix(Tag{"tagprefix"})
+	if err != nil {
+		t.Errorf("Unable to get items by tag prefix. Error: %s", err)
+	}
+	want := []string{"QmTagPrefix1", "QmTagPrefix2", "QmTagPrefix4"}
+	if !reflect.DeepEqual(cids, want) {
+		t.Errorf("GetItemsByTagPrefix = %v; want %v", cids, want)
+	}
+
+	cids, err = ds.GetItemsByTagPrefix(Tag{"tagprefix", "year"})
+	if err != nil {
+		t.Errorf("Unable to get items by tag prefix. Error: %s", err)
+	}
+	want = []string{"QmTagPrefix2"}
+	if !reflect.DeepEqual(cids, want) {
+		t.Errorf("GetItemsByTagPrefix = %v; want %v", cids, want)
+	}
+}
+
+func TestCollectionsWithTag(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	for _, ipns := range []string{"withtag2.com", "withtag1.com", "withtag3.com"} {
+		err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: ipns})
+		if err != nil {
+			t.Errorf("Unable to create Collection. Error: %s", err)
+		}
+	}
+	items := []*Item{
+		{CID: "QmWithTag1", Name: "With Tag 1", Tags: []Tag{{"withtag"}}},
+		{CID: "QmWithTag2", Name: "With Tag 2", Tags: []Tag{{"withtag"}}},
+		{CID: "QmWithTag3", Name: "With Tag 3", Tags: []Tag{{"withtag", "other"}}},
+	}
+	for _, item := range items {
+		err = ds.CreateOrUpdateItem(item)
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+	}
+	for _, ci := range [][2]string{
+		{"QmWithTag1", "withtag1.com"},
+		{"QmWithTag1", "withtag2.com"},
+		{"QmWithTag2", "withtag2.com"},
+		{"QmWithTag3", "withtag3.com"},
+	} {
+		err = ds.AddItemToCollection(ci[0], ci[1])
+		if err != nil {
+			t.Errorf("Unable to add item to collection. Error: %s", err)
+		}
+	}
+
+	addrs, err := ds.CollectionsWithTag(Tag{"withtag"})
+	if err != nil {
+		t.Errorf("Unable to read collections with tag. Error: %s", err)
+	}
+	if !reflect.DeepEqual(addrs, []string{"withtag1.com", "withtag2.com"}) {
+		t.Errorf("CollectionsWithTag = %v; want [withtag1.com withtag2.com]", addrs)
+	}
+
+	addrs, err = ds.CollectionsWithTag(Tag{"withtag", "unknown"})
+	if err != nil {
+		t.Errorf("Unable to read collections with tag. Error: %s", err)
+	}
+	if len(addrs) != 0 {
+		t.Errorf("CollectionsWithTag of an unused tag = %v; want []", addrs)
+	}
+}
+
+func TestSuggestTags(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	items := []*Item{
+		{CID: "QmSuggest1", Name: "Suggest 1", Tags: []Tag{{"suggest", "drama"}, {"suggest", "b"}, {"suggest", "a"}}},
+		{CID: "QmSuggest2", Name: "Suggest 2", Tags: []Tag{{"suggest", "drama"}, {"suggest", "a"}}},
+		{CID: "QmSuggest3", Name: "Suggest 3", Tags: []Tag{{"suggest", "drama"}, {"suggest", "c"}}},
+		{CID: "QmSuggest4", Name: "Suggest 4", Tags: []Tag{{"suggest", "d"}}},
+	}
+	for _, item := range items {
+		err = ds.CreateOrUpdateItem(item)
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+	}
+
+	suggestions, err := ds.SuggestTags(Tag{"suggest", "drama"}, 2)
+	if err != nil {
+		t.Errorf("Unable to suggest tags. Error: %s", err)
+	}
+	expected := []TagCount{{Tag{"suggest", "a"}, 2}, {Tag{"suggest", "b"}, 1}}
+	if !reflect.DeepEqual(suggestions, expected) {
+		t.Errorf("SuggestTags = %v; want %v", suggestions, expected)
+	}
+
+	suggestions, err = ds.SuggestTags(Tag{"suggest", "unknown"}, 2)
+	if err != nil {
+		t.Errorf("Unable to suggest tags. Error: %s", err)
+	}
+	if len(suggestions) != 0 {
+		t.Errorf("SuggestTags of an unused tag = %v; want []", suggestions)
+	}
+}
+
+func TestAutocompleteTags(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	items := []*Item{
+		{CID: "QmAutocomplete1", Name: "Autocomplete 1", Tags: []Tag{{"autocomplete", "b"}, {"autocomplete", "c"}}},
+		{CID: "QmAutocomplete2", Name: "Autocomplete 2", Tags: []Tag{{"autocomplete", "c"}, {"autocomplete", "a"}}},
+		{CID: "QmAutocomplete3", Name: "Autocomplete 3", Tags: []Tag{{"autocomplete", "c"}}},
+	}
+	for _, item := range items {
+		err = ds.CreateOrUpdateItem(item)
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+	}
+
+	tags, err := ds.AutocompleteTags("autocomplete", 2)
+	if err != nil {
+		t.Errorf("Unable to autocomplete tags. Error: %s", err)
+	}
+	expected := []TagCount{{Tag{"autocomplete", "c"}, 3}, {Tag{"autocomplete", "a"}, 1}}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("AutocompleteTags = %v; want %v", tags, expected)
+	}
+
+	tags, err = ds.AutocompleteTags("autocompleteunknown", 2)
+	if err != nil {
+		t.Errorf("Unable to autocomplete tags. Error: %s", err)
+	}
+	if len(tags) != 0 {
+		t.Errorf("AutocompleteTags of an unknown prefix = %v; want []", tags)
+	}
+}
+
+func TestFacetCounts(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	items := []*Item{
+		{CID: "QmFacet1", Name: "Facet 1", Tags: []Tag{{"facet", "drama"}, {"facet", "comedy"}}},
+		{CID: "QmFacet2", Name: "Facet 2", Tags: []Tag{{"facet", "drama", "korean"}}},
+		{CID: "QmFacet3", Name: "Facet 3", Tags: []Tag{{"facet", "drama"}, {"facet"}}},
+		// Not under "facet" although the tag string starts with it
+		{CID: "QmFacet4", Name: "Facet 4", Tags: []Tag{{"facets", "drama"}}},
+	}
+	for _, item := range items {
+		err = ds.CreateOrUpdateItem(item)
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+	}
+
+	facets, err := ds.FacetCounts(Tag{"facet"})
+	if err != nil {
+		t.Errorf("Unable to count facets. Error: %s", err)
+	}
+	expected := []TagCount{{Tag{"facet", "drama"}, 3}, {Tag{"facet", "comedy"}, 1}}
+	if !reflect.DeepEqual(facets, expected) {
+		t.Errorf("FacetCounts = %v; want %v", facets, expected)
+	}
+
+	_, err = ds.FacetCounts(Tag{})
+	if err != ErrInvalidTag {
+		t.Errorf("FacetCounts of an empty tag should return ErrInvalidTag. Actual %v", err)
+	}
+}
+
+func TestListCollectionTags(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "listtags.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "List Tags"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	items := []*Item{
+		{CID: "QmListTags1", Name: "List Tags 1", Tags: []Tag{{"listtags", "b"}, {"listtags", "a"}}},
+		{CID: "QmListTags2", Name: "List Tags 2", Tags: []Tag{{"listtags", "a"}}},
+		// Not in the collection
+		{CID: "QmListTags3", Name: "List Tags 3", Tags: []Tag{{"listtags", "a"}, {"listtags", "c"}}},
+	}
+	for _, item := range items {
+		err = ds.CreateOrUpdateItem(item)
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+	}
+	for _, cid := range []string{"QmListTags1", "QmListTags2"} {
+		err = ds.AddItemToCollection(cid, ipns)
+		if err != nil {
+			t.Errorf("Unable to add item to collection. Error: %s", err)
+		}
+	}
+
+	tags, err := ds.ListCollectionTags(ipns)
+	if err != nil {
+		t.Errorf("Unable to list collection tags. Error: %s", err)
+	}
+	expected := []TagCount{{Tag{"listtags", "a"}, 2}, {Tag{"listtags", "b"}, 1}}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("ListCollectionTags = %v; want %v", tags, expected)
+	}
+
+	_, err = ds.ListCollectionTags("missing.listtags.com")
+	if err != ErrIPNSNotFound {
+		t.Errorf("Listing tags of a missing collection should return ErrIPNSNotFound. Actual %v", err)
+	}
+}
+
+func TestUntaggedItems(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "untagged.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Untagged Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	items := []*Item{
+		{CID: "QmUntagged1", Name: "Untagged 1"},
+		{CID: "QmUntagged2", Name: "Untagged 2", Tags: []Tag{{"untagged", "not"}}},
+	}
+	for _, item := range items {
+		err = ds.CreateOrUpdateItem(item)
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+		err = ds.AddItemToCollection(item.CID, ipns)
+		if err != nil {
+			t.Errorf("Unable to add item to collection. Error: %s", err)
+		}
+	}
+	// Not in the collection
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmUntagged3", Name: "Untagged 3"})
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+
+	cids, err := ds.UntaggedItems(ipns)
+	if err != nil {
+		t.Errorf("Unable to find untagged items. Error: %s", err)
+	}
+	if !reflect.DeepEqual(cids, []string{"QmUntagged1"}) {
+		t.Errorf("UntaggedItems = %v; want [QmUntagged1]", cids)
+	}
+
+	cids, err = ds.UntaggedItems("")
+	if err != nil {
+		t.Errorf("Unable to find untagged items. Error: %s", err)
+	}
+	if !funk.ContainsString(cids, "QmUntagged3") || funk.ContainsString(cids, "QmUntagged2") {
+		t.Errorf("Store-wide UntaggedItems = %v", cids)
+	}
+}
+
+func TestRootOnlyItems(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "rootonly.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Root Only Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	err = ds.CreateOrUpdateFolder(&Folder{IPNSAddress: ipns, Path: "filed"})
+	if err != nil {
+		t.Errorf("Unable to create folder. Error: %s", err)
+	}
+	for _, cid := range []string{"QmRootOnly1", "QmRootOnly2"} {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid})
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+		err = ds.AddItemToCollection(cid, ipns)
+		if err != nil {
+			t.Errorf("Unable to add item to collection. Error: %s", err)
+		}
+	}
+	err = ds.AddItemToFolder("QmRootOnly2", &Folder{IPNSAddress: ipns, Path: "filed"})
+	if err != nil {
+		t.Errorf("Unable to add item to folder. Error: %s", err)
+	}
+
+	cids, err := ds.RootOnlyItems(ipns)
+	if err != nil {
+		t.Errorf("Unable to find root only items. Error: %s", err)
+	}
+	if !reflect.DeepEqual(cids, []string{"QmRootOnly1"}) {
+		t.Errorf("RootOnlyItems = %v; want [QmRootOnly1]", cids)
+	}
+
+	_, err = ds.RootOnlyItems("missing.rootonly.com")
+	if err != ErrIPNSNotFound {
+		t.Errorf("RootOnlyItems of a missing collection should return ErrIPNSNotFound. Actual %v", err)
+	}
+}
+
+func TestCollectionOrphanItems(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	for _, ipns := range []string{"orphan1.com", "orphan2.com"} {
+		err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: ipns})
+		if err != nil {
+			t.Errorf("Unable to create Collection. Error: %s", err)
+		}
+	}
+	for _, cid := range []string{"QmOrphan1", "QmOrphan2", "QmOrphan3"} {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid})
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+	}
+	// QmOrphan1 is in a folder of another collection only
+	for _, cid := range []string{"QmOrphan1", "QmOrphan2"} {
+		err = ds.AddItemToCollectionOpts(cid, "orphan1.com", false)
+		if err != nil {
+			t.Errorf("Unable to add item to collection. Error: %s", err)
+		}
+	}
+	err = ds.AddItemToFolder("QmOrphan1", &Folder{IPNSAddress: "orphan2.com"})
+	if err != nil {
+		t.Errorf("Unable to add item to folder. Error: %s", err)
+	}
+	err = ds.AddItemToCollection("QmOrphan3", "orphan1.com")
+	if err != nil {
+		t.Errorf("Unable to add item to collection. Error: %s", err)
+	}
+
+	cids, err := ds.CollectionOrphanItems("orphan1.com")
+	if err != nil {
+		t.Errorf("Unable to find orphan items. Error: %s", err)
+	}
+	if !reflect.DeepEqual(cids, []string{"QmOrphan1", "QmOrphan2"}) {
+		t.Errorf("CollectionOrphanItems = %v; want [QmOrphan1 QmOrphan2]", cids)
+	}
+
+	_, err = ds.CollectionOrphanItems("missing.orphan.com")
+	if err != ErrIPNSNotFound {
+		t.Errorf("CollectionOrphanItems of a missing collection should return ErrIPNSNotFound. Actual %v", err)
+	}
+}
+
+func TestFindDuplicateNames(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "duplicates.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Duplicates Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+
+	dups, err := ds.FindDuplicateNames(ipns)
+	if err != nil {
+		t.Errorf("Unable to find duplicate names. Error: %s", err)
+	}
+	if dups == nil || len(dups) != 0 {
+		t.Errorf("FindDuplicateNames of an empty collection = %v; want empty map", dups)
+	}
+
+	items := []*Item{
+		{CID: "QmDuplicate1", Name: "Same"},
+		{CID: "QmDuplicate2", Name: "Same"},
+		{CID: "QmDuplicate3", Name: "Unique"},
+	}
+	for _, item := range items {
+		err = ds.CreateOrUpdateItem(item)
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+		err = ds.AddItemToCollection(item.CID, ipns)
+		if err != nil {
+			t.Errorf("Unable to add item to collection. Error: %s", err)
+		}
+	}
+
+	dups, err = ds.FindDuplicateNames(ipns)
+	if err != nil {
+		t.Errorf("Unable to find duplicate names. Error: %s", err)
+	}
+	expected := map[string][]string{"Same": {"QmDuplicate1", "QmDuplicate2"}}
+	if !reflect.DeepEqual(dups, expected) {
+		t.Errorf("FindDuplicateNames = %v; want %v", dups, expected)
+	}
+}
+
+func TestFilterFolderItems(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "filterfolder.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Filter Folder"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	for _, p := range []string{"a/b", "l"} {
+		err = ds.CreateFolderAll(ipns, p)
+		if err != nil {
+			t.Errorf("Unable to create folders. Error: %s", err)
+		}
+	}
+	err = ds.LinkFolder(ipns, "l", "a")
+	if err != nil {
+		t.Errorf("Unable to link folder. Error: %s", err)
+	}
+
+	x, y := Tag{"filterfolder", "x"}, Tag{"filterfolder", "y"}
+	items := map[string]struct {
+		tags []Tag
+		path string
+	}{
+		"QmFilterFolder1": {[]Tag{x, y}, "a"},
+		"QmFilterFolder2": {[]Tag{x}, "a/b"},
+		"QmFilterFolder3": {[]Tag{x, y}, ""},
+		"QmFilterFolder4": {[]Tag{x, y}, "l"},
+	}
+	for cid, item := range items {
+		err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: cid, Tags: item.tags})
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+		err = ds.AddItemToFolder(cid, &Folder{IPNSAddress: ipns, Path: item.path})
+		if err != nil {
+			t.Errorf("Unable to add item to folder. Error: %s", err)
+		}
+	}
+
+	folder := &Folder{IPNSAddress: ipns, Path: "a"}
+	tests := []struct {
+		tags []Tag
+		want []string
+	}{
+		{nil, []string{"QmFilterFolder1", "QmFilterFolder2", "QmFilterFolder4"}},
+		{[]Tag{x, y}, []string{"QmFilterFolder1", "QmFilterFolder4"}},
+		{[]Tag{{"filterfolder", "unknown"}}, []string{}},
+	}
+	for _, test := range tests {
+		cids, err := ds.FilterFolderItems(folder, test.tags)
+		if err != nil {
+			t.Errorf("Unable to filter folder items. Error: %s", err)
+		}
+		if !reflect.DeepEqual(cids, test.want) {
+			t.Errorf("FilterFolderItems(%v) = %v; want %v", test.tags, cids, test.want)
+		}
+	}
+
+	_, err = ds.FilterFolderItems(&Folder{IPNSAddress: ipns, Path: "missing"}, nil)
+	if err != ErrFolderNotExists {
+		t.Errorf("Filtering a missing folder should return ErrFolderNotExists. Actual %v", err)
+	}
+	_, err = ds.FilterFolderItems(folder, []Tag{{}})
+	if err != ErrInvalidTag {
+		t.Errorf("Filtering by an empty tag should return ErrInvalidTag. Actual %v", err)
+	}
+}
+
+func TestReadTagTree(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	items := []*Item{
+		{CID: "QmTagTree1", Name: "Tag Tree 1", Tags: []Tag{{"tagtree", "movie", "drama"}, {"tagtree", "book"}}},
+		{CID: "QmTagTree2", Name: "Tag Tree 2", Tags: []Tag{{"tagtree", "movie", "drama"}, {"tagtree", "movie"}}},
+	}
+	for _, item := range items {
+		err = ds.CreateOrUpdateItem(item)
+		if err != nil {
+			t.Errorf("Unable to create item. Error: %s", err)
+		}
+	}
+
+	root, err := ds.ReadTagTree()
+	if err != nil {
+		t.Errorf("Unable to read tag tree. Error: %s", err)
+	}
+	if root.Segment != "" {
+		t.Errorf("Root segment = %q; want empty", root.Segment)
+	}
+
+	var node *TagNode
+	for _, c := range root.Children {
+		if c.Segment == "tagtree" {
+			node = c
+		}
+	}
+	expected := &TagNode{Segment: "tagtree", Children: []*TagNode{
+		{Segment: "book", Count: 1},
+		{Segment: "movie", Count: 1, Children: []*TagNode{
+			{Segment: "drama", Count: 2},
+		}},
+	}}
+	if !reflect.DeepEqual(node, expected) {
+		t.Errorf("Tag tree node = %+v; want %+v", node, expected)
+	}
+}
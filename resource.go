@@ -3,15 +3,47 @@ package resource
 import (
 	"reflect"
 	"strings"
+	"time"
 )
 
 // Collection is a collection of resource Items.
-// TODO: Last update timestamp
 type Collection struct {
 	IPNSAddress string // Can be either a IPNS hash or a DNSLink domain
 	Name        string
 	Description string
 	IsMine      bool
+	// Published tells whether the collection has been published to IPNS. PublishedCID is the root CID of the last
+	// published manifest. Both are set by SetCollectionPublished, and cleared by it with an empty root CID.
+	// CreateOrUpdateCollection keeps the stored ones if both are zero.
+	Published    bool
+	PublishedCID string
+	// UpdatedAt is the last time the collection, its folders, its items or their tags changed. It's maintained by Datastore.
+	UpdatedAt time.Time
+}
+
+// AddressKind is the kind of an IPNS address.
+type AddressKind int
+
+const (
+	// AddressIPNSHash is an IPNS name which is a hash of a public key. It's resolved via the IPNS DHT.
+	AddressIPNSHash AddressKind = iota
+	// AddressDNSLink is a domain name with a DNSLink TXT record.
+	AddressDNSLink
+)
+
+// ClassifyAddress tells whether an IPNS address is a DNSLink domain or an IPNS hash.
+// Hashes never contain dots while domain names always do.
+func ClassifyAddress(addr string) AddressKind {
+	addr = strings.TrimPrefix(addr, "/ipns/")
+	if strings.Contains(addr, ".") {
+		return AddressDNSLink
+	}
+	return AddressIPNSHash
+}
+
+// AddressKind returns the kind of IPNSAddress of the collection.
+func (c *Collection) AddressKind() AddressKind {
+	return ClassifyAddress(c.IPNSAddress)
 }
 
 // Folder belongs to only one collection. It may have a parent folder and multiple sub folders.
@@ -22,6 +54,10 @@ type Collection struct {
 type Folder struct {
 	IPNSAddress string
 	Path        string
+
+	// ItemCount and ChildCount are computed by ReadFolder and never stored.
+	ItemCount  int
+	ChildCount int
 }
 
 // ParentPath return parent paths of the folder
@@ -41,12 +77,74 @@ func (f *Folder) Basename() string {
 	return parts[len(parts)-1]
 }
 
+// NormalizeFolderPath collapses repeated slashes and strips the trailing slash of a folder path.
+// ErrInvalidFolderPath is returned if the path starts with "/" or contains "..".
+func NormalizeFolderPath(path string) (string, error) {
+	if strings.HasPrefix(path, "/") {
+		return "", ErrInvalidFolderPath
+	}
+
+	var parts []string
+	for _, part := range strings.Split(path, "/") {
+		if part == "" {
+			continue
+		}
+		if part == ".." {
+			return "", ErrInvalidFolderPath
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+// CollectionStats is a summary of a Collection.
+type CollectionStats struct {
+	ItemCount int
+	TotalSize int64
+	// TagCounts maps tagStr to the number of items in the collection having the tag.
+	TagCounts map[string]uint
+}
+
+// DeletionPreview describes what DelFolder would delete.
+type DeletionPreview struct {
+	// Folders are paths of the folder and its descendant folders which would be deleted, sorted by path.
+	Folders []string
+	// EvictedCIDs are CIDs of items which would be removed from the collection, sorted by CID.
+	EvictedCIDs []string
+}
+
+// TagCount is a Tag with a number of items.
+type TagCount struct {
+	Tag   Tag
+	Count uint
+}
+
+// ItemLocation is a folder an item is in.
+type ItemLocation struct {
+	IPNS string
+	Path string
+}
+
+// TagNode is a node of the tag hierarchy returned by ReadTagTree.
+type TagNode struct {
+	// Segment is the last segment of the tag of the node. It's empty for the root node.
+	Segment string
+	// Count is the number of items having the tag of the node. It's 0 for nodes which are only parents of other tags.
+	Count    uint
+	Children []*TagNode
+}
+
 // Item is one item of any kind of resource.
-// TODO: File size
 type Item struct {
-	CID  string
-	Name string
-	Tags []Tag
+	CID        string
+	Name       string
+	Tags       []Tag
+	PreviewCID string // CID of a thumbnail or preview of the item. Optional.
+	Size       int64  // Size of the item in bytes. Optional.
+	// ContentType classifies the item, like "image", "video" or "document". Optional.
+	ContentType string
+	// CreatedAt is the time the item is first saved. It's set by CreateOrUpdateItem if zero and never changed by updates.
+	CreatedAt time.Time
 }
 
 // Tag is for tagging Items.
@@ -71,3 +169,45 @@ func (t Tag) Equals(t2 Tag) bool {
 func (t Tag) IsEmpty() bool {
 	return len(t) == 0
 }
+
+// Normalized returns a copy of the Tag with every segment lowercased, trimmed and its inner whitespace collapsed to one space.
+func (t Tag) Normalized() Tag {
+	n := make(Tag, len(t))
+	for i, seg := range t {
+		n[i] = strings.ToLower(strings.Join(strings.Fields(seg), " "))
+	}
+	return n
+}
+
+// Depth returns the number of segments of a Tag.
+func (t Tag) Depth() int {
+	return len(t)
+}
+
+// Parent returns the Tag without its last segment. The parent of a top level Tag is empty.
+func (t Tag) Parent() Tag {
+	if len(t) == 0 {
+		return Tag{}
+	}
+	return append(Tag{}, t[:len(t)-1]...)
+}
+
+// Child returns a new Tag with seg appended.
+func (t Tag) Child(seg string) Tag {
+	c := make(Tag, len(t), len(t)+1)
+	copy(c, t)
+	return append(c, seg)
+}
+
+// HasPrefix checks if the Tag is p or is under p. It matches whole segments, so "movie" is not a prefix of "movies".
+func (t Tag) HasPrefix(p Tag) bool {
+	if len(p) > len(t) {
+		return false
+	}
+	for i := range p {
+		if t[i] != p[i] {
+			return false
+		}
+	}
+	return true
+}
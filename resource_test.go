@@ -34,3 +34,94 @@ func TestTagEquality(t *testing.T) {
 		t.Error("Tag should not equal tag4.")
 	}
 }
+
+func TestTagNormalized(t *testing.T) {
+	tag := Tag{" Movie ", "Science  Fiction"}
+	want := Tag{"movie", "science fiction"}
+	if !tag.Normalized().Equals(want) {
+		t.Errorf("Normalized tag = %s; want %s", tag.Normalized(), want)
+	}
+	if tag[0] != " Movie " {
+		t.Error("Normalized should not modify the tag.")
+	}
+}
+
+func TestTagSegments(t *testing.T) {
+	tag := Tag{"movie", "genres", "drama"}
+
+	if tag.Depth() != 3 {
+		t.Errorf("Tag depth = %d; want 3", tag.Depth())
+	}
+	if !tag.Parent().Equals(Tag{"movie", "genres"}) {
+		t.Errorf("Tag parent = %s; want movie:genres", tag.Parent())
+	}
+	if !(Tag{"movie"}).Parent().IsEmpty() {
+		t.Error("Parent of a top level tag should be empty.")
+	}
+
+	child := tag.Parent().Child("comedy")
+	if !child.Equals(Tag{"movie", "genres", "comedy"}) {
+		t.Errorf("Tag child = %s; want movie:genres:comedy", child)
+	}
+	if !tag.Equals(Tag{"movie", "genres", "drama"}) {
+		t.Errorf("Child should not modify the tag. Actual %s", tag)
+	}
+
+	if !tag.HasPrefix(Tag{"movie", "genres"}) || !tag.HasPrefix(tag) {
+		t.Error("Tag should have prefix movie:genres and itself.")
+	}
+	if tag.HasPrefix(Tag{"movie", "genre"}) || tag.HasPrefix(Tag{"movie", "genres", "drama", "old"}) {
+		t.Error("Tag should not have prefix movie:genre or movie:genres:drama:old.")
+	}
+}
+
+func TestNormalizeFolderPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+		err  error
+	}{
+		{"", "", nil},
+		{"a", "a", nil},
+		{"a//b", "a/b", nil},
+		{"a/", "a", nil},
+		{"a///b//", "a/b", nil},
+		{"../a", "", ErrInvalidFolderPath},
+		{"a/../b", "", ErrInvalidFolderPath},
+		{"/a", "", ErrInvalidFolderPath},
+	}
+
+	for _, tt := range tests {
+		path, err := NormalizeFolderPath(tt.path)
+		if err != tt.err {
+			t.Errorf("NormalizeFolderPath(%q) error = %v; want %v", tt.path, err, tt.err)
+		}
+		if path != tt.want {
+			t.Errorf("NormalizeFolderPath(%q) = %q; want %q", tt.path, path, tt.want)
+		}
+	}
+}
+
+func TestClassifyAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want AddressKind
+	}{
+		{"test.com", AddressDNSLink},
+		{"docs.ipfs.io", AddressDNSLink},
+		{"/ipns/test.com", AddressDNSLink},
+		{"QmcNfsZ9j9ZUZtkG9JpQ2GpHVcAnSdCvQ2GgGUB2qwTP7R", AddressIPNSHash},
+		{"k51qzi5uqu5dlvj2baxnqndepeb86cbk3ng7n3i46uzyxzyqj2xjonzllnv0v8", AddressIPNSHash},
+	}
+
+	for _, tt := range tests {
+		if kind := ClassifyAddress(tt.addr); kind != tt.want {
+			t.Errorf("ClassifyAddress(%q) = %d; want %d", tt.addr, kind, tt.want)
+		}
+	}
+
+	c := &Collection{IPNSAddress: "test.com"}
+	if c.AddressKind() != AddressDNSLink {
+		t.Error("Collection address should be a DNSLink.")
+	}
+}
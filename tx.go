@@ -0,0 +1,147 @@
+package resource
+
+import (
+	"github.com/dgraph-io/badger"
+)
+
+// Tx is a read-write transaction of Datastore. Mutations made through a Tx are committed together, or not at all.
+// A Tx is only valid inside the function passed to Datastore.Update.
+type Tx struct {
+	d   *Datastore
+	txn *badger.Txn
+
+	// Cache keys to invalidate after the transaction
+	invalidated []string
+}
+
+// Update runs fn in a single read-write transaction. If fn returns an error, no mutation made through tx is saved.
+// The transaction is retried if it conflicts with a concurrent one, so fn may run more than once and must not keep
+// state between runs.
+func (d *Datastore) Update(fn func(tx *Tx) error) error {
+	tx := &Tx{d: d}
+	err := d.update(func(txn *badger.Txn) error {
+		tx.txn = txn
+		tx.invalidated = nil
+		return fn(tx)
+	})
+	d.cache.remove(tx.invalidated...)
+	return err
+}
+
+// CreateOrUpdateCollection update collection information
+func (tx *Tx) CreateOrUpdateCollection(c *Collection) error {
+	tx.invalidated = append(tx.invalidated, collectionCacheKey(c.IPNSAddress))
+	return tx.d.createOrUpdateCollectionInTxn(tx.txn, c)
+}
+
+// CreateOrUpdateItem update item information
+func (tx *Tx) CreateOrUpdateItem(i *Item) error {
+	tx.invalidated = append(tx.invalidated, itemCacheKey(i.CID))
+	return tx.d.createOrUpdateItemInTxn(tx.txn, i)
+}
+
+// ReadItem reads Item from database. Changes made in tx are visible.
+func (tx *Tx) ReadItem(cid string) (*Item, error) {
+	return tx.d.readItemInTxn(tx.txn, cid)
+}
+
+// AddItemTag adds a Tag to an Item. If the tag doesn't exist in database, it will be created.
+func (tx *Tx) AddItemTag(cid string, t Tag) error {
+	if t.IsEmpty() || cid == "" {
+		panic("Invalid parameters.")
+	}
+
+	tx.invalidated = append(tx.invalidated, itemCacheKey(cid))
+	err := tx.d.checkCIDInTxn(tx.txn, cid)
+	if err != nil {
+		return err
+	}
+	return tx.d.addItemTagInTxn(tx.txn, cid, t)
+}
+
+// RemoveItemTag removes a Tag from an Item.
+func (tx *Tx) RemoveItemTag(cid string, t Tag) error {
+	tx.invalidated = append(tx.invalidated, itemCacheKey(cid))
+	return tx.d.removeItemTagInTxn(tx.txn, cid, t)
+}
+
+// AddItemToCollection adds an Item to a Collection.
+func (tx *Tx) AddItemToCollection(cid string, ipns string) error {
+	return tx.d.addItemToCollectionInTxn(tx.txn, cid, ipns, true)
+}
+
+// RemoveItemFromCollection removes an Item from a Collection.
+func (tx *Tx) RemoveItemFromCollection(cid string, ipns string) error {
+	err := tx.d.checkCIDInTxn(tx.txn, cid)
+	if err != nil {
+		return err
+	}
+
+	err = tx.d.checkIPNSInTxn(tx.txn, ipns)
+	if err != nil {
+		return err
+	}
+
+	return tx.d.removeItemFromCollectionInTxn(tx.txn, cid, ipns)
+}
+
+// CreateOrUpdateFolder creates a new folder or updates a folder
+func (tx *Tx) CreateOrUpdateFolder(folder *Folder) error {
+	if folder.IPNSAddress == "" {
+		panic("Invalid folder.")
+	}
+
+	err := tx.d.checkIPNSInTxn(tx.txn, folder.IPNSAddress)
+	if err != nil {
+		return err
+	}
+	return tx.d.createOrUpdateFolderInTxn(tx.txn, folder)
+}
+
+// AddItemToFolder adds an item to a folder, and to the collection of the folder if it's not in it yet.
+func (tx *Tx) AddItemToFolder(cid string, folder *Folder) error {
+	folder, err := normalizeFolder(folder)
+	if err != nil {
+		return err
+	}
+	return tx.d.addItemToFolderInCollectionInTxn(tx.txn, cid, folder)
+}
+
+// RemoveItemFromFolder removes item from a folder
+func (tx *Tx) RemoveItemFromFolder(cid string, folder *Folder) error {
+	folder, err := normalizeFolder(folder)
+	if err != nil {
+		return err
+	}
+	return tx.d.removeItemFromFolderInTxn(tx.txn, cid, folder)
+}
+
+// MoveOrCopyItem moves or copies an item from a folder to another folder
+func (tx *Tx) MoveOrCopyItem(cid string, folderFrom, folderTo *Folder, copy bool) error {
+	folderFrom, err := normalizeFolder(folderFrom)
+	if err != nil {
+		return err
+	}
+	folderTo, err = normalizeFolder(folderTo)
+	if err != nil {
+		return err
+	}
+
+	exists, err := tx.d.isItemInFolderInTxn(tx.txn, cid, folderFrom)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrItemNotInFolder
+	}
+
+	exists, err = tx.d.isFolderPathExistsInTxn(tx.txn, folderTo.IPNSAddress, folderTo.Path)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrFolderNotExists
+	}
+
+	return tx.d.moveOrCopyItemInTxn(tx.txn, cid, folderFrom, folderTo, copy)
+}
@@ -0,0 +1,124 @@
+package resource
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestUpdate(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	c := &Collection{IPNSAddress: "tx.com", Name: "Tx Collection"}
+	item := &Item{CID: "QmTxItem1", Name: "Tx Item", Tags: []Tag{{"tx", "tag"}}}
+	folder := &Folder{IPNSAddress: c.IPNSAddress, Path: "folder1"}
+
+	err = ds.Update(func(tx *Tx) error {
+		err := tx.CreateOrUpdateCollection(c)
+		if err != nil {
+			return err
+		}
+		err = tx.CreateOrUpdateFolder(folder)
+		if err != nil {
+			return err
+		}
+		err = tx.CreateOrUpdateItem(item)
+		if err != nil {
+			return err
+		}
+		err = tx.AddItemToCollection(item.CID, c.IPNSAddress)
+		if err != nil {
+			return err
+		}
+		return tx.AddItemToFolder(item.CID, folder)
+	})
+	if err != nil {
+		t.Errorf("Unable to run transaction. Error: %s", err)
+	}
+
+	isIn, err := ds.IsItemInFolder(item.CID, folder)
+	if err != nil {
+		t.Errorf("Unable to check if item is in folder. Error: %s", err)
+	}
+	if !isIn {
+		t.Error("Item should be in folder1.")
+	}
+
+	// A failed transaction saves nothing
+	errAbort := errors.New("abort")
+	item2 := &Item{CID: "QmTxItem2", Name: "Tx Item2"}
+	err = ds.Update(func(tx *Tx) error {
+		err := tx.CreateOrUpdateItem(item2)
+		if err != nil {
+			return err
+		}
+		err = tx.AddItemToCollection(item2.CID, c.IPNSAddress)
+		if err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if err != errAbort {
+		t.Errorf("Transaction should fail with errAbort. Actual %v", err)
+	}
+
+	_, err = ds.ReadItem(item2.CID)
+	if err != ErrCIDNotFound {
+		t.Errorf("Item2 should not be saved.")
+	}
+}
+
+func TestUpdateConcurrent(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	// Every transaction reads and writes the same item, so concurrent ones conflict and must be retried
+	cid := "QmTxConcurrent1"
+	err = ds.CreateOrUpdateItem(&Item{CID: cid, Name: "0"})
+	if err != nil {
+		t.Fatalf("Unable to create item. Error: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := 0; k < 10; k++ {
+				err := ds.Update(func(tx *Tx) error {
+					item, err := tx.ReadItem(cid)
+					if err != nil {
+						return err
+					}
+					count, err := strconv.Atoi(item.Name)
+					if err != nil {
+						return err
+					}
+					item.Name = strconv.Itoa(count + 1)
+					return tx.CreateOrUpdateItem(item)
+				})
+				if err != nil {
+					t.Errorf("Unable to update in transaction. Error: %s", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	item, err := ds.ReadItem(cid)
+	if err != nil {
+		t.Fatalf("Unable to read item. Error: %s", err)
+	}
+	if item.Name != "80" {
+		t.Errorf("Item name = %s; want 80", item.Name)
+	}
+}
@@ -0,0 +1,570 @@
+package resource
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger"
+)
+
+// InconsistencyKind is the kind of an Inconsistency found by Verify.
+type InconsistencyKind int
+
+const (
+	// InconsistencyOrphanKey means a relationship key exists but its reverse key doesn't.
+	InconsistencyOrphanKey InconsistencyKind = iota
+	// InconsistencyTagCount means tag::[tagStr]::count doesn't match the number of tag_item entries.
+	InconsistencyTagCount
+	// InconsistencyFolderCycle means a folder is listed as a child of its own descendant.
+	InconsistencyFolderCycle
+)
+
+// Inconsistency describes a mismatch between the indexes of Datastore.
+type Inconsistency struct {
+	Kind InconsistencyKind
+	// Key is the key which is inconsistent.
+	Key string
+	// Counterpart is the key that should exist but doesn't for InconsistencyOrphanKey.
+	// For InconsistencyFolderCycle, Key is the children list of a folder and Counterpart is the child folder closing the cycle.
+	Counterpart string
+	// Expected and Actual are the real and the stored tag item counts. Only for InconsistencyTagCount.
+	Expected uint
+	Actual   uint
+
+	key dbKey
+	// ipns and link are the linked child which closes a cycle. Only for InconsistencyFolderCycle.
+	ipns string
+	link folderLink
+}
+
+// String implements Stringer interface.
+func (i Inconsistency) String() string {
+	switch i.Kind {
+	case InconsistencyTagCount:
+		return fmt.Sprintf("%s is %d, want %d", i.Key, i.Actual, i.Expected)
+	case InconsistencyFolderCycle:
+		return fmt.Sprintf("%s lists its ancestor %s", i.Key, i.Counterpart)
+	default:
+		return fmt.Sprintf("%s has no counterpart %s", i.Key, i.Counterpart)
+	}
+}
+
+// reverseIndexes maps a relationship key prefix to a function building the reverse key of a relationship key.
+// The function returns nil if the key is malformed.
+var reverseIndexes = []struct {
+	prefix  string
+	reverse func(k dbKey) dbKey
+}{
+	// collection_item::[ipns]::[cid] <-> item_collection::[cid]::[ipns]
+	{"collection_item", func(k dbKey) dbKey {
+		if len(k) != 3 {
+			return nil
+		}
+		return dbKey{"item_collection", k[2], k[1]}
+	}},
+	{"item_collection", func(k dbKey) dbKey {
+		if len(k) != 3 {
+			return nil
+		}
+		return dbKey{"collection_item", k[2], k[1]}
+	}},
+	// item_folder::[cid]::[ipns]::[folderPath] <-> folder_item::[ipns]::[folderPath]::[cid]
+	{"item_folder", func(k dbKey) dbKey {
+		if len(k) != 4 {
+			return nil
+		}
+		return dbKey{"folder_item", k[2], k[3], k[1]}
+	}},
+	{"folder_item", func(k dbKey) dbKey {
+		if len(k) != 4 {
+			return nil
+		}
+		return dbKey{"item_folder", k[3], k[1], k[2]}
+	}},
+	// item_tag::[cid]::[tagStr] <-> tag_item::[tagStr]::[cid]
+	{"item_tag", func(k dbKey) dbKey {
+		if len(k) != 3 {
+			return nil
+		}
+		return dbKey{"tag_item", k[2], k[1]}
+	}},
+	{"tag_item", func(k dbKey) dbKey {
+		if len(k) != 3 {
+			return nil
+		}
+		return dbKey{"item_tag", k[2], k[1]}
+	}},
+}
+
+// Verify cross-checks the forward and reverse indexes of Datastore and reports mismatches.
+func (d *Datastore) Verify() ([]Inconsistency, error) {
+	var incs []Inconsistency
+	err := d.view(func(txn *badger.Txn) error {
+		var err error
+		incs, err = d.verifyInTxn(txn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return incs, nil
+}
+
+// Repair prunes orphaned relationship keys, breaks folder cycles and recomputes tag counts.
+// A folder cycle is broken by unlinking a linked child folder in the cycle.
+func (d *Datastore) Repair() error {
+	// Pruned item_tag keys change items' tags
+	defer d.cache.purge()
+
+	return d.update(func(txn *badger.Txn) error {
+		incs, err := d.verifyInTxn(txn)
+		if err != nil {
+			return err
+		}
+
+		for _, inc := range incs {
+			if inc.Kind != InconsistencyOrphanKey {
+				continue
+			}
+			err = txn.Delete(d.key(inc.key))
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, k := range d.readKeysInTxn(txn, dbKey{"collections_all", ""}) {
+			ipns := k[1]
+			// Unlinking may leave other cycles, so look again until there is none
+			for {
+				links, err := d.findFolderCyclesInTxn(txn, ipns)
+				if err != nil {
+					return err
+				}
+				if len(links) == 0 {
+					break
+				}
+				for _, l := range links {
+					err = d.breakFolderLinkInTxn(txn, ipns, l)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		return d.recalculateTagCountsInTxn(txn)
+	})
+}
+
+// RecalculateTagCounts recomputes the item count of every tag from its tag_item entries.
+// Tags which are not referred by any item are deleted.
+func (d *Datastore) RecalculateTagCounts() error {
+	return d.update(func(txn *badger.Txn) error {
+		return d.recalculateTagCountsInTxn(txn)
+	})
+}
+
+// Reindex rebuilds all reverse and derived indexes from the primary records in one transaction.
+// items, collections_all, collection::[ipns]::ismine, folders, item::[cid] fields, collection_item, folder_item and
+// item_tag are the source of truth. Membership and tag keys referring to missing items, collections or folders are
+// deleted. Then collections_mine, collections_others, item_collection, item_folder, tag_item, tags, tag counts,
+// type_item and recent are rewritten from scratch.
+func (d *Datastore) Reindex() error {
+	defer d.cache.purge()
+
+	return d.update(func(txn *badger.Txn) error {
+		return d.reindexInTxn(txn)
+	})
+}
+
+func (d *Datastore) reindexInTxn(txn *badger.Txn) error {
+	items := make(map[string]bool)
+	// items::[cid]
+	for _, k := range d.readKeysInTxn(txn, dbKey{"items", ""}) {
+		items[k[1]] = true
+	}
+	collections := make(map[string]bool)
+	// collections_all::[ipns]
+	for _, k := range d.readKeysInTxn(txn, dbKey{"collections_all", ""}) {
+		collections[k[1]] = true
+	}
+	folders := make(map[string]bool)
+	// folders::[ipns]::[folderPath]
+	for _, k := range d.readKeysInTxn(txn, dbKey{"folders", ""}) {
+		if collections[k[1]] {
+			folders[dbKey{k[1], k[2]}.String()] = true
+		}
+	}
+
+	for _, p := range []string{"collections_mine", "collections_others", "item_collection", "item_folder", "tag_item", "type_item", "recent"} {
+		err := d.dropPrefix(txn, dbKey{p, ""})
+		if err != nil {
+			return err
+		}
+	}
+
+	for ipns := range collections {
+		var mine bool
+		item, err := txn.Get(d.key(dbKey{"collection", ipns, "ismine"}))
+		if err != nil && err != badger.ErrKeyNotFound {
+			return err
+		}
+		if item != nil {
+			err = item.Value(func(val []byte) error {
+				mine = string(val) == "1"
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		err = d.setCollectionMineInTxn(txn, ipns, mine)
+		if err != nil {
+			return err
+		}
+	}
+
+	// collection_item::[ipns]::[cid] -> item_collection::[cid]::[ipns]
+	for _, k := range d.readKeysInTxn(txn, dbKey{"collection_item", ""}) {
+		var err error
+		if len(k) != 3 || !collections[k[1]] || !items[k[2]] {
+			err = txn.Delete(d.key(k))
+		} else {
+			err = txn.Set(d.key(dbKey{"item_collection", k[2], k[1]}), []byte(k[1]))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// folder_item::[ipns]::[folderPath]::[cid] -> item_folder::[cid]::[ipns]::[folderPath]
+	for _, k := range d.readKeysInTxn(txn, dbKey{"folder_item", ""}) {
+		var err error
+		if len(k) != 4 || !folders[dbKey{k[1], k[2]}.String()] || !items[k[3]] {
+			err = txn.Delete(d.key(k))
+		} else {
+			err = txn.Set(d.key(dbKey{"item_folder", k[3], k[1], k[2]}), []byte(k[2]))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// item_tag::[cid]::[tagStr] -> tag_item::[tagStr]::[cid] and tags::[tagStr]
+	for _, k := range d.readKeysInTxn(txn, dbKey{"item_tag", ""}) {
+		if len(k) != 3 || !items[k[1]] || k[2] == "" {
+			err := txn.Delete(d.key(k))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err := txn.Set(d.key(dbKey{"tag_item", k[2], k[1]}), []byte(k[1]))
+		if err != nil {
+			return err
+		}
+		err = txn.Set(d.key(dbKey{"tags", k[2]}), []byte(k[2]))
+		if err != nil {
+			return err
+		}
+	}
+
+	for cid := range items {
+		err := d.reindexItemInTxn(txn, cid)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Counts every tag, and deletes the tags no item has anymore
+	return d.recalculateTagCountsInTxn(txn)
+}
+
+// reindexItemInTxn writes the type_item and recent keys of an item from its fields.
+func (d *Datastore) reindexItemInTxn(txn *badger.Txn, cid string) error {
+	item, err := txn.Get(d.key(dbKey{"item", cid, "content_type"}))
+	if err != nil && err != badger.ErrKeyNotFound {
+		return err
+	}
+	if item != nil {
+		ct, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		// type_item::[contentType]::[cid]
+		err = txn.Set(d.key(dbKey{"type_item", string(ct), cid}), []byte(cid))
+		if err != nil {
+			return err
+		}
+	}
+
+	item, err = txn.Get(d.key(dbKey{"item", cid, "created_at"}))
+	if err == badger.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var createdAt time.Time
+	err = item.Value(func(val []byte) error {
+		createdAt = time.Unix(0, int64(binary.BigEndian.Uint64(val)))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return txn.Set(d.key(recentKey(createdAt, cid)), []byte(cid))
+}
+
+// PruneEmptyTags deletes tags which are not referred by any item, and returns the number of deleted tags.
+// Unlike RecalculateTagCounts, counts of other tags are not changed.
+func (d *Datastore) PruneEmptyTags() (int, error) {
+	var n int
+	err := d.update(func(txn *badger.Txn) error {
+		n = 0
+		// tags::[tagStr]
+		for _, k := range d.readKeysInTxn(txn, dbKey{"tags", ""}) {
+			tagStr := k[1]
+			// tag_item::[tagStr]::[cid]
+			if d.hasPrefixInTxn(txn, dbKey{"tag_item", tagStr, ""}) {
+				continue
+			}
+
+			err := txn.Delete(d.key(k))
+			if err != nil {
+				return err
+			}
+			err = d.dropPrefix(txn, dbKey{"tag", tagStr, ""})
+			if err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+func (d *Datastore) verifyInTxn(txn *badger.Txn) ([]Inconsistency, error) {
+	var incs []Inconsistency
+
+	for _, idx := range reverseIndexes {
+		keys := d.readKeysInTxn(txn, dbKey{idx.prefix, ""})
+		for _, k := range keys {
+			rk := idx.reverse(k)
+			if rk == nil {
+				incs = append(incs, Inconsistency{Kind: InconsistencyOrphanKey, Key: k.String(), key: k})
+				continue
+			}
+
+			_, err := txn.Get(d.key(rk))
+			if err == badger.ErrKeyNotFound {
+				incs = append(incs, Inconsistency{Kind: InconsistencyOrphanKey, Key: k.String(), Counterpart: rk.String(), key: k})
+			} else if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, k := range d.readKeysInTxn(txn, dbKey{"collections_all", ""}) {
+		ipns := k[1]
+		links, err := d.findFolderCyclesInTxn(txn, ipns)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range links {
+			ck := dbKey{"folder", ipns, l.parent, "children"}
+			incs = append(incs, Inconsistency{
+				Kind:        InconsistencyFolderCycle,
+				Key:         ck.String(),
+				Counterpart: dbKey{"folders", ipns, l.child}.String(),
+				key:         ck,
+				ipns:        ipns,
+				link:        l,
+			})
+		}
+	}
+
+	counts, err := d.countTagItemsInTxn(txn)
+	if err != nil {
+		return nil, err
+	}
+	for tagStr, c := range counts {
+		k := dbKey{"tag", tagStr, "count"}
+		var stored uint
+		item, err := txn.Get(d.key(k))
+		if err != nil && err != badger.ErrKeyNotFound {
+			return nil, err
+		}
+		if item != nil {
+			err = item.Value(func(val []byte) error {
+				stored = uint(binary.BigEndian.Uint32(val))
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if stored != c {
+			incs = append(incs, Inconsistency{Kind: InconsistencyTagCount, Key: k.String(), Expected: c, Actual: stored, key: k})
+		}
+	}
+
+	return incs, nil
+}
+
+// findFolderCyclesInTxn returns links which close cycles in the folder tree of a collection.
+// For every cycle found, the last linked child in the cycle is returned, so unlinking it never removes a real child folder.
+func (d *Datastore) findFolderCyclesInTxn(txn *badger.Txn, ipns string) ([]folderLink, error) {
+	children := make(map[string][]string)
+	var paths []string
+	// folders::[ipns]::[folderPath]
+	for _, k := range d.readKeysInTxn(txn, dbKey{"folders", ipns, ""}) {
+		p := k[2]
+		paths = append(paths, p)
+
+		var err error
+		children[p], err = d.readPathListInTxn(txn, dbKey{"folder", ipns, p, "children"})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	found := make(map[folderLink]bool)
+	var stack []string
+	var links []folderLink
+
+	var visit func(p string)
+	visit = func(p string) {
+		state[p] = visiting
+		stack = append(stack, p)
+
+		for _, c := range children[p] {
+			switch state[c] {
+			case unvisited:
+				visit(c)
+			case visiting:
+				// stack from c to p, plus p -> c, is a cycle
+				var cycle []string
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == c {
+						cycle = append(append(cycle, stack[i:]...), c)
+						break
+					}
+				}
+				for i := len(cycle) - 2; i >= 0; i-- {
+					child := &Folder{IPNSAddress: ipns, Path: cycle[i+1]}
+					if child.ParentPath() != cycle[i] {
+						l := folderLink{parent: cycle[i], child: cycle[i+1]}
+						if !found[l] {
+							found[l] = true
+							links = append(links, l)
+						}
+						break
+					}
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[p] = visited
+	}
+
+	for _, p := range paths {
+		if state[p] == unvisited {
+			visit(p)
+		}
+	}
+
+	return links, nil
+}
+
+// breakFolderLinkInTxn removes a linked child from the children list of its parent, and the parent from the links of the child.
+func (d *Datastore) breakFolderLinkInTxn(txn *badger.Txn, ipns string, l folderLink) error {
+	err := d.removeFromPathListInTxn(txn, dbKey{"folder", ipns, l.parent, "children"}, l.child)
+	if err != nil {
+		return err
+	}
+	return d.removeFromPathListInTxn(txn, dbKey{"folder", ipns, l.child, "links"}, l.parent)
+}
+
+// countTagItemsInTxn returns the real item count of every tag under tags:: by counting tag_item entries.
+func (d *Datastore) countTagItemsInTxn(txn *badger.Txn) (map[string]uint, error) {
+	counts := make(map[string]uint)
+	for _, k := range d.readKeysInTxn(txn, dbKey{"tags", ""}) {
+		tagStr := k[1]
+		counts[tagStr] = uint(len(d.readKeysInTxn(txn, dbKey{"tag_item", tagStr, ""})))
+	}
+	return counts, nil
+}
+
+// recalculateTagCountsInTxn overwrites tag::[tagStr]::count with the real item count. Tags without items are deleted.
+func (d *Datastore) recalculateTagCountsInTxn(txn *badger.Txn) error {
+	counts, err := d.countTagItemsInTxn(txn)
+	if err != nil {
+		return err
+	}
+
+	for tagStr, c := range counts {
+		if c == 0 {
+			err = txn.Delete(d.key(dbKey{"tags", tagStr}))
+			if err != nil {
+				return err
+			}
+			err = d.dropPrefix(txn, dbKey{"tag", tagStr, ""})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		cBytes := make([]byte, 4)
+		binary.BigEndian.PutUint32(cBytes, uint32(c))
+		err = txn.Set(d.key(dbKey{"tag", tagStr, "count"}), cBytes)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readKeysInTxn returns all keys with prefix.
+func (d *Datastore) readKeysInTxn(txn *badger.Txn, prefix dbKey) []dbKey {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var keys []dbKey
+	for it.Seek(d.key(prefix)); it.ValidForPrefix(d.key(prefix)); it.Next() {
+		keys = append(keys, d.parseKey(it.Item().Key()))
+	}
+	return keys
+}
+
+// countKeysInTxn returns the number of keys with prefix without decoding them.
+func (d *Datastore) countKeysInTxn(txn *badger.Txn, prefix dbKey) int {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	n := 0
+	for it.Seek(d.key(prefix)); it.ValidForPrefix(d.key(prefix)); it.Next() {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,394 @@
+package resource
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+)
+
+func TestVerifyAndRepair(t *testing.T) {
+	verifyDbPath := filepath.Join(testdataDir, "verify.db")
+	_ = os.RemoveAll(verifyDbPath)
+	defer os.RemoveAll(verifyDbPath)
+
+	ds, err := NewDatastore(verifyDbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	c := &Collection{IPNSAddress: "verify.com", Name: "Verify Collection"}
+	err = ds.CreateOrUpdateCollection(c)
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+
+	tag := Tag{"verify", "tag"}
+	item := &Item{CID: "QmVerifyItem1", Name: "Verify Item", Tags: []Tag{tag}}
+	err = ds.CreateOrUpdateItem(item)
+	if err != nil {
+		t.Errorf("Unable to create Item. Error: %s", err)
+	}
+
+	err = ds.AddItemToCollection(item.CID, c.IPNSAddress)
+	if err != nil {
+		t.Errorf("Unable to add Item to Collection. Error: %s", err)
+	}
+
+	incs, err := ds.Verify()
+	if err != nil {
+		t.Errorf("Unable to verify Datastore. Error: %s", err)
+	}
+	if len(incs) != 0 {
+		t.Errorf("Datastore should be consistent but got %v", incs)
+	}
+
+	// Simulate a leaked item_folder key and a drifted tag count
+	err = ds.db.Update(func(txn *badger.Txn) error {
+		err := txn.Set(dbKey{"item_folder", item.CID, c.IPNSAddress, "leaked"}.Bytes(), []byte("leaked"))
+		if err != nil {
+			return err
+		}
+		cBytes := make([]byte, 4)
+		binary.BigEndian.PutUint32(cBytes, 5)
+		return txn.Set(dbKey{"tag", tag.String(), "count"}.Bytes(), cBytes)
+	})
+	if err != nil {
+		t.Fatalf("Unable to corrupt Datastore. Error: %s", err)
+	}
+
+	incs, err = ds.Verify()
+	if err != nil {
+		t.Errorf("Unable to verify Datastore. Error: %s", err)
+	}
+	if len(incs) != 2 {
+		t.Errorf("Expect 2 inconsistencies. Actual %v", incs)
+	}
+
+	err = ds.Repair()
+	if err != nil {
+		t.Errorf("Unable to repair Datastore. Error: %s", err)
+	}
+
+	incs, err = ds.Verify()
+	if err != nil {
+		t.Errorf("Unable to verify Datastore. Error: %s", err)
+	}
+	if len(incs) != 0 {
+		t.Errorf("Datastore should be repaired but got %v", incs)
+	}
+
+	counts, err := ds.ReadTagItemCount([]Tag{tag})
+	if err != nil {
+		t.Errorf("Unable to read tag item count. Error: %s", err)
+	}
+	if counts[0] != 1 {
+		t.Errorf("Tag item count should be 1 but get %d", counts[0])
+	}
+}
+
+func TestRecalculateTagCounts(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	tag := Tag{"recalc", "tag"}
+	orphanTag := Tag{"recalc", "orphan"}
+	item := &Item{CID: "QmRecalcItem1", Name: "Recalc Item", Tags: []Tag{tag}}
+	err = ds.CreateOrUpdateItem(item)
+	if err != nil {
+		t.Errorf("Unable to create Item. Error: %s", err)
+	}
+
+	// Simulate a drifted count and a tag without any item
+	err = ds.db.Update(func(txn *badger.Txn) error {
+		cBytes := make([]byte, 4)
+		binary.BigEndian.PutUint32(cBytes, 0)
+		err := txn.Set(dbKey{"tag", tag.String(), "count"}.Bytes(), cBytes)
+		if err != nil {
+			return err
+		}
+		err = txn.Set(dbKey{"tags", orphanTag.String()}.Bytes(), []byte(orphanTag.String()))
+		if err != nil {
+			return err
+		}
+		binary.BigEndian.PutUint32(cBytes, 3)
+		return txn.Set(dbKey{"tag", orphanTag.String(), "count"}.Bytes(), cBytes)
+	})
+	if err != nil {
+		t.Fatalf("Unable to corrupt Datastore. Error: %s", err)
+	}
+
+	err = ds.RecalculateTagCounts()
+	if err != nil {
+		t.Errorf("Unable to recalculate tag counts. Error: %s", err)
+	}
+
+	counts, err := ds.ReadTagItemCount([]Tag{tag, orphanTag})
+	if err != nil {
+		t.Errorf("Unable to read tag item count. Error: %s", err)
+	}
+	if counts[0] != 1 {
+		t.Errorf("Tag item count should be 1 but get %d", counts[0])
+	}
+	if counts[1] != 0 {
+		t.Errorf("Orphan tag item count should be 0 but get %d", counts[1])
+	}
+
+	tags, err := ds.SearchTags("recalc:orphan")
+	if err != nil {
+		t.Errorf("Unable to search tags. Error: %s", err)
+	}
+	if len(tags) != 0 {
+		t.Errorf("Orphan tag should be deleted.")
+	}
+
+	// Removing the tag works again after recalculation
+	err = ds.RemoveItemTag(item.CID, tag)
+	if err != nil {
+		t.Errorf("Unable to remove Tag from Item. Error: %s", err)
+	}
+}
+
+func TestFolderCycle(t *testing.T) {
+	ds, err := NewDatastore(dbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	ipns := "cycle.com"
+	err = ds.CreateOrUpdateCollection(&Collection{IPNSAddress: ipns, Name: "Cycle Collection"})
+	if err != nil {
+		t.Errorf("Unable to create Collection. Error: %s", err)
+	}
+	err = ds.CreateFolderAll(ipns, "a/b")
+	if err != nil {
+		t.Errorf("Unable to create folders. Error: %s", err)
+	}
+
+	err = ds.MoveOrCopyFolder(&Folder{IPNSAddress: ipns, Path: "a"}, &Folder{IPNSAddress: ipns, Path: "a/b/a"}, true)
+	if err != ErrFolderCycle {
+		t.Errorf("Copying a folder into its descendant should return ErrFolderCycle. Actual %v", err)
+	}
+
+	// Simulate a cycle: a is listed as a linked child of a/b
+	err = ds.db.Update(func(txn *badger.Txn) error {
+		err := ds.writePathListInTxn(txn, dbKey{"folder", ipns, "a/b", "children"}, []string{"a"})
+		if err != nil {
+			return err
+		}
+		return ds.writePathListInTxn(txn, dbKey{"folder", ipns, "a", "links"}, []string{"a/b"})
+	})
+	if err != nil {
+		t.Fatalf("Unable to corrupt Datastore. Error: %s", err)
+	}
+
+	cycles := func() []Inconsistency {
+		incs, err := ds.Verify()
+		if err != nil {
+			t.Errorf("Unable to verify Datastore. Error: %s", err)
+		}
+		var cycles []Inconsistency
+		for _, inc := range incs {
+			if inc.Kind == InconsistencyFolderCycle && inc.ipns == ipns {
+				cycles = append(cycles, inc)
+			}
+		}
+		return cycles
+	}
+
+	incs := cycles()
+	if len(incs) != 1 || incs[0].Key != "folder::cycle.com::a/b::children" || incs[0].Counterpart != "folders::cycle.com::a" {
+		t.Errorf("Expect a cycle closed by a/b -> a. Actual %v", incs)
+	}
+
+	err = ds.Repair()
+	if err != nil {
+		t.Errorf("Unable to repair Datastore. Error: %s", err)
+	}
+	if incs := cycles(); len(incs) != 0 {
+		t.Errorf("Cycles should be broken by Repair. Actual %v", incs)
+	}
+
+	children, err := ds.ReadFolderChildren(&Folder{IPNSAddress: ipns, Path: "a"})
+	if err != nil {
+		t.Errorf("Unable to read folder children. Error: %s", err)
+	}
+	if len(children) != 1 || children[0] != "a/b" {
+		t.Errorf("Repair should keep real children. Children of a = %v", children)
+	}
+}
+
+func TestReindex(t *testing.T) {
+	reindexDbPath := filepath.Join(testdataDir, "reindex.db")
+	_ = os.RemoveAll(reindexDbPath)
+	defer os.RemoveAll(reindexDbPath)
+
+	ds, err := NewDatastore(reindexDbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	mine := &Collection{IPNSAddress: "reindex.com", Name: "Reindex", IsMine: true}
+	other := &Collection{IPNSAddress: "reindex2.com", Name: "Reindex 2"}
+	for _, c := range []*Collection{mine, other} {
+		err = ds.CreateOrUpdateCollection(c)
+		if err != nil {
+			t.Errorf("Unable to create Collection. Error: %s", err)
+		}
+	}
+	folder := &Folder{IPNSAddress: mine.IPNSAddress, Path: "f"}
+	err = ds.CreateOrUpdateFolder(folder)
+	if err != nil {
+		t.Errorf("Unable to create folder. Error: %s", err)
+	}
+	tag := Tag{"reindex", "tag"}
+	item := &Item{CID: "QmReindex1", Name: "Reindex", Tags: []Tag{tag}, ContentType: "video/mp4"}
+	err = ds.CreateOrUpdateItem(item)
+	if err != nil {
+		t.Errorf("Unable to create item. Error: %s", err)
+	}
+	err = ds.AddItemToFolder(item.CID, folder)
+	if err != nil {
+		t.Errorf("Unable to add item to folder. Error: %s", err)
+	}
+
+	// Break every kind of index
+	err = ds.db.Update(func(txn *badger.Txn) error {
+		for _, k := range []dbKey{
+			{"item_collection", item.CID, mine.IPNSAddress},
+			{"item_folder", item.CID, mine.IPNSAddress, "f"},
+			{"tag_item", tag.String(), item.CID},
+			{"type_item", item.ContentType, item.CID},
+			{"collections_mine", mine.IPNSAddress},
+		} {
+			err := txn.Delete(k.Bytes())
+			if err != nil {
+				return err
+			}
+		}
+		err := txn.Set(dbKey{"collections_mine", other.IPNSAddress}.Bytes(), []byte(other.IPNSAddress))
+		if err != nil {
+			return err
+		}
+		err = txn.Set(dbKey{"folder_item", mine.IPNSAddress, "missing", item.CID}.Bytes(), []byte(item.CID))
+		if err != nil {
+			return err
+		}
+		cBytes := make([]byte, 4)
+		binary.BigEndian.PutUint32(cBytes, 5)
+		return txn.Set(dbKey{"tag", tag.String(), "count"}.Bytes(), cBytes)
+	})
+	if err != nil {
+		t.Fatalf("Unable to corrupt Datastore. Error: %s", err)
+	}
+
+	err = ds.Reindex()
+	if err != nil {
+		t.Errorf("Unable to reindex Datastore. Error: %s", err)
+	}
+
+	incs, err := ds.Verify()
+	if err != nil {
+		t.Errorf("Unable to verify Datastore. Error: %s", err)
+	}
+	if len(incs) != 0 {
+		t.Errorf("Datastore should be consistent after reindexing but got %v", incs)
+	}
+
+	cs, err := ds.ListMyCollections()
+	if err != nil {
+		t.Errorf("Unable to list my collections. Error: %s", err)
+	}
+	if len(cs) != 1 || cs[0].IPNSAddress != mine.IPNSAddress {
+		t.Errorf("My collections = %v; want only %s", cs, mine.IPNSAddress)
+	}
+
+	cids, err := ds.ReadItemsByType(item.ContentType)
+	if err != nil {
+		t.Errorf("Unable to read items by type. Error: %s", err)
+	}
+	if len(cids) != 1 || cids[0] != item.CID {
+		t.Errorf("ReadItemsByType = %v; want [%s]", cids, item.CID)
+	}
+
+	isIn, err := ds.IsItemInFolder(item.CID, folder)
+	if err != nil {
+		t.Errorf("Unable to check if item is in folder. Error: %s", err)
+	}
+	if !isIn {
+		t.Error("Item should be in the folder.")
+	}
+
+	i, err := ds.ReadItem(item.CID)
+	if err != nil {
+		t.Errorf("Unable to read item. Error: %s", err)
+	} else if i.Name != item.Name || len(i.Tags) != 1 || !i.Tags[0].Equals(tag) {
+		t.Errorf("Item should be untouched. Actual %+v", i)
+	}
+}
+
+func TestPruneEmptyTags(t *testing.T) {
+	pruneDbPath := filepath.Join(testdataDir, "prune.db")
+	_ = os.RemoveAll(pruneDbPath)
+	defer os.RemoveAll(pruneDbPath)
+
+	ds, err := NewDatastore(pruneDbPath)
+	if err != nil {
+		t.Fatalf("Unable to create Datastore. Error: %s", err)
+	}
+	defer ds.Close()
+
+	tag := Tag{"prune", "tag"}
+	orphanTags := []Tag{{"prune", "orphan"}, {"prune", "nocount"}}
+	err = ds.CreateOrUpdateItem(&Item{CID: "QmPruneItem1", Name: "Prune Item", Tags: []Tag{tag}})
+	if err != nil {
+		t.Errorf("Unable to create Item. Error: %s", err)
+	}
+
+	// Simulate tags without any item, with and without a count
+	err = ds.db.Update(func(txn *badger.Txn) error {
+		for _, ot := range orphanTags {
+			err := txn.Set(dbKey{"tags", ot.String()}.Bytes(), []byte(ot.String()))
+			if err != nil {
+				return err
+			}
+		}
+		cBytes := make([]byte, 4)
+		binary.BigEndian.PutUint32(cBytes, 0)
+		return txn.Set(dbKey{"tag", orphanTags[0].String(), "count"}.Bytes(), cBytes)
+	})
+	if err != nil {
+		t.Fatalf("Unable to corrupt Datastore. Error: %s", err)
+	}
+
+	n, err := ds.PruneEmptyTags()
+	if err != nil {
+		t.Errorf("Unable to prune empty tags. Error: %s", err)
+	}
+	if n != 2 {
+		t.Errorf("PruneEmptyTags = %d; want 2", n)
+	}
+
+	tags, err := ds.SearchTags("prune")
+	if err != nil {
+		t.Errorf("Unable to search tags. Error: %s", err)
+	}
+	if len(tags) != 1 || !tags[0].Equals(tag) {
+		t.Errorf("Tags = %v; want [%v]", tags, tag)
+	}
+	incs, err := ds.Verify()
+	if err != nil {
+		t.Errorf("Unable to verify Datastore. Error: %s", err)
+	}
+	if len(incs) != 0 {
+		t.Errorf("Datastore should be consistent after pruning but got %v", incs)
+	}
+}